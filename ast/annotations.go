@@ -0,0 +1,63 @@
+package ast
+
+// Annotations is a side table that lets analysis passes attach results to
+// nodes, such as an inferred type or a resolved scope, without a field on
+// every node struct. Entries are keyed by node identity, so they do not
+// carry over to the copies made by Rewrite and Clone. The zero value is an
+// empty table ready to use.
+type Annotations struct {
+	values map[Node]map[any]any
+}
+
+// Remove drops every annotation on node.
+func (a *Annotations) Remove(node Node) {
+	delete(a.values, node)
+}
+
+// Len returns the number of nodes with at least one annotation.
+func (a *Annotations) Len() int {
+	return len(a.values)
+}
+
+// An AnnotationKey names one kind of annotation and the type of its values.
+// Keys are compared by pointer, so passes that create their own keys never
+// overwrite each other's annotations, even under the same name.
+type AnnotationKey[T any] struct {
+	name string
+}
+
+func NewAnnotationKey[T any](name string) *AnnotationKey[T] {
+	return &AnnotationKey[T]{name: name}
+}
+
+func (k *AnnotationKey[T]) String() string { return k.name }
+
+// Set annotates node with value, replacing any earlier value for k.
+func (k *AnnotationKey[T]) Set(a *Annotations, node Node, value T) {
+	if a.values == nil {
+		a.values = make(map[Node]map[any]any)
+	}
+	values := a.values[node]
+	if values == nil {
+		values = make(map[any]any)
+		a.values[node] = values
+	}
+	values[k] = value
+}
+
+// Get returns the value node is annotated with for k, and whether it has
+// one.
+func (k *AnnotationKey[T]) Get(a *Annotations, node Node) (T, bool) {
+	stored, ok := a.values[node][k]
+	value, _ := stored.(T) // a nil interface value is stored as nil
+	return value, ok
+}
+
+// Delete removes the annotation for k from node.
+func (k *AnnotationKey[T]) Delete(a *Annotations, node Node) {
+	values := a.values[node]
+	delete(values, k)
+	if len(values) == 0 {
+		delete(a.values, node)
+	}
+}
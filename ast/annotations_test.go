@@ -0,0 +1,41 @@
+package ast
+
+import "testing"
+
+func TestAnnotations(t *testing.T) {
+	typeOf := NewAnnotationKey[string]("type")
+	constant := NewAnnotationKey[int64]("constant")
+	shadow := NewAnnotationKey[string]("type")
+
+	x, sum := ident("x"), &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)}
+	var notes Annotations
+
+	typeOf.Set(&notes, x, "INTEGER")
+	typeOf.Set(&notes, sum, "INTEGER")
+	constant.Set(&notes, sum, 3)
+
+	if got, ok := typeOf.Get(&notes, x); !ok || got != "INTEGER" {
+		t.Errorf("type of x = %q, %t; want INTEGER", got, ok)
+	}
+	if got, ok := constant.Get(&notes, sum); !ok || got != 3 {
+		t.Errorf("constant of sum = %d, %t; want 3", got, ok)
+	}
+	if _, ok := constant.Get(&notes, x); ok {
+		t.Errorf("x should have no constant annotation")
+	}
+	if _, ok := shadow.Get(&notes, x); ok {
+		t.Errorf("a key with the same name should not see another key's annotations")
+	}
+	if _, ok := typeOf.Get(&notes, ident("x")); ok {
+		t.Errorf("annotations should be keyed by node identity, not structure")
+	}
+
+	typeOf.Delete(&notes, x)
+	if _, ok := typeOf.Get(&notes, x); ok || notes.Len() != 1 {
+		t.Errorf("after Delete: found=%t, Len=%d; want false, 1", ok, notes.Len())
+	}
+	notes.Remove(sum)
+	if _, ok := constant.Get(&notes, sum); ok || notes.Len() != 0 {
+		t.Errorf("after Remove: found=%t, Len=%d; want false, 0", ok, notes.Len())
+	}
+}
@@ -0,0 +1,152 @@
+package ast
+
+const (
+	arenaMinChunkSize = 16
+	arenaChunkSize    = 256
+)
+
+type slab[T any] struct {
+	chunk []T
+}
+
+func (s *slab[T]) alloc(v T) *T {
+	if len(s.chunk) == cap(s.chunk) {
+		size := min(max(2*cap(s.chunk), arenaMinChunkSize), arenaChunkSize)
+		s.chunk = make([]T, 0, size)
+	}
+	s.chunk = append(s.chunk, v)
+	return &s.chunk[len(s.chunk)-1]
+}
+
+// Arena hands out nodes from chunked slabs so a parse allocates in bulk and
+// the whole tree becomes garbage together. A nil *Arena falls back to
+// ordinary heap allocation.
+type Arena struct {
+	identifiers slab[Identifier]
+	integers    slab[IntegerLiteral]
+	strings     slab[StringLiteral]
+	booleans    slab[Boolean]
+	prefixes    slab[PrefixExpression]
+	infixes     slab[InfixExpression]
+	calls       slab[CallExpression]
+	indexes     slab[IndexExpression]
+	arrays      slab[ArrayLiteral]
+	blocks      slab[BlockStatement]
+	exprStmts   slab[ExpressionStatement]
+	lets        slab[LetStatement]
+	returns     slab[ReturnStatement]
+}
+
+func NewArena() *Arena {
+	return &Arena{}
+}
+
+// Reset detaches the arena from previously allocated chunks. Nodes handed out
+// before the reset stay valid for as long as they are referenced.
+func (a *Arena) Reset() {
+	*a = Arena{}
+}
+
+func (a *Arena) Identifier(v Identifier) *Identifier {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.identifiers.alloc(v)
+}
+
+func (a *Arena) IntegerLiteral(v IntegerLiteral) *IntegerLiteral {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.integers.alloc(v)
+}
+
+func (a *Arena) StringLiteral(v StringLiteral) *StringLiteral {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.strings.alloc(v)
+}
+
+func (a *Arena) Boolean(v Boolean) *Boolean {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.booleans.alloc(v)
+}
+
+func (a *Arena) PrefixExpression(v PrefixExpression) *PrefixExpression {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.prefixes.alloc(v)
+}
+
+func (a *Arena) InfixExpression(v InfixExpression) *InfixExpression {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.infixes.alloc(v)
+}
+
+func (a *Arena) CallExpression(v CallExpression) *CallExpression {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.calls.alloc(v)
+}
+
+func (a *Arena) IndexExpression(v IndexExpression) *IndexExpression {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.indexes.alloc(v)
+}
+
+func (a *Arena) ArrayLiteral(v ArrayLiteral) *ArrayLiteral {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.arrays.alloc(v)
+}
+
+func (a *Arena) BlockStatement(v BlockStatement) *BlockStatement {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.blocks.alloc(v)
+}
+
+func (a *Arena) ExpressionStatement(v ExpressionStatement) *ExpressionStatement {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.exprStmts.alloc(v)
+}
+
+func (a *Arena) LetStatement(v LetStatement) *LetStatement {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.lets.alloc(v)
+}
+
+func (a *Arena) ReturnStatement(v ReturnStatement) *ReturnStatement {
+	if a == nil {
+		n := v
+		return &n
+	}
+	return a.returns.alloc(v)
+}
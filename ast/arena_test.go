@@ -0,0 +1,47 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestArenaAllocatesDistinctNodes(t *testing.T) {
+	a := NewArena()
+
+	idents := []*Identifier{}
+	for i := 0; i < arenaChunkSize*2+1; i++ {
+		name := string(rune('a' + i%26))
+		idents = append(idents, a.Identifier(Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: name},
+			Value: name,
+		}))
+	}
+
+	for i, ident := range idents {
+		expected := string(rune('a' + i%26))
+		if ident.Value != expected {
+			t.Fatalf("idents[%d] was overwritten. got=%q, want=%q", i, ident.Value, expected)
+		}
+		if i > 0 && ident == idents[i-1] {
+			t.Fatalf("idents[%d] aliases the previous node", i)
+		}
+	}
+
+	a.Reset()
+	fresh := a.Identifier(Identifier{Value: "fresh"})
+	if idents[0].Value != "a" || fresh.Value != "fresh" {
+		t.Errorf("Reset invalidated previously allocated nodes")
+	}
+}
+
+func TestNilArenaUsesHeap(t *testing.T) {
+	var a *Arena
+
+	left := a.IntegerLiteral(IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1})
+	right := a.IntegerLiteral(IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2})
+	infix := a.InfixExpression(InfixExpression{Left: left, Operator: "+", Right: right})
+
+	if infix.String() != "(1 + 2)" {
+		t.Errorf("infix.String() wrong. got=%q", infix.String())
+	}
+}
@@ -9,6 +9,11 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is the position of the node's first character and End the
+	// position just past its last one.
+	Pos() token.Position
+	End() token.Position
+	Kind() NodeKind
 }
 
 type Statement interface {
@@ -28,6 +33,17 @@ type Program struct {
 type Identifier struct {
 	Token token.Token
 	Value string
+	// Type is the annotation on a declared name, as in "let x: int = 5" or
+	// "fn(a: int)", and nil everywhere else.
+	Type *TypeAnnotation
+}
+
+// TypeAnnotation is a type named after a colon, on a let or parameter name
+// or after a function's parameter list. Annotations are recorded but not
+// yet checked.
+type TypeAnnotation struct {
+	Token token.Token
+	Name  string
 }
 
 type LetStatement struct {
@@ -36,6 +52,23 @@ type LetStatement struct {
 	Value Expression
 }
 
+// LetGroup is "let x = 1, y = 2;". The bindings share the let token and are
+// made in order, so later values can refer to earlier names.
+type LetGroup struct {
+	Token    token.Token
+	Bindings []*LetStatement
+}
+
+// DestructuringLet is "let [a, b] = value;" or "let {x, y} = value;". Pattern
+// is the opening bracket or brace, which says whether the names take the
+// elements of an array or the values of a hash under the same keys.
+type DestructuringLet struct {
+	Token   token.Token
+	Pattern token.Token
+	Names   []*Identifier
+	Value   Expression
+}
+
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
@@ -51,11 +84,51 @@ type IntegerLiteral struct {
 	Value int64
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+// BadExpression stands in for an expression that failed to parse, covering
+// the tokens from From to To, so that a program with syntax errors is still
+// a complete tree.
+type BadExpression struct {
+	From token.Token
+	To   token.Token
+}
+
+// BadStatement stands in for a statement that failed to parse.
+type BadStatement struct {
+	From token.Token
+	To   token.Token
+}
+
+// NullLiteral is the literal null, the absence of a value.
+type NullLiteral struct {
+	Token token.Token
+}
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
 }
 
+// InterpolatedString is a string literal with embedded ${...} expressions.
+// Parts holds the literal segments as StringLiterals and the embedded
+// expressions in source order; it evaluates to their concatenation.
+type InterpolatedString struct {
+	Token token.Token // the first STRING_SEGMENT
+	Parts []Expression
+	Close token.Token // the STRING_END
+}
+
+// CharLiteral is a single-quoted character. It evaluates to the character's
+// code point as an integer.
+type CharLiteral struct {
+	Token token.Token
+	Value rune
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -81,14 +154,102 @@ type IfExpression struct {
 	Alternative *BlockStatement
 }
 
+// ForStatement is a C-style loop. Init, Condition and Post are nil when
+// their clause is omitted; without a condition the loop only ends through
+// a return or an error.
+type ForStatement struct {
+	Token     token.Token
+	Init      Statement
+	Condition Expression
+	Post      Expression
+	Body      *BlockStatement
+}
+
+// WhileStatement repeats its body for as long as Condition is truthy.
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+// ForInStatement is "for (value in iterable)" or "for (key, value in
+// iterable)". Key is nil in the first form.
+type ForInStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+// BreakStatement leaves the innermost loop.
+type BreakStatement struct {
+	Token token.Token
+}
+
+// ContinueStatement skips to the next iteration of the innermost loop.
+type ContinueStatement struct {
+	Token token.Token
+}
+
+// AssignExpression rebinds an existing variable and evaluates to the new
+// value, so assignments can be chained.
+type AssignExpression struct {
+	Token token.Token
+	Name  *Identifier
+	Value Expression
+}
+
+// MemberExpression is obj.name. On its own it reads the "name" key of a
+// hash; as the function of a call, obj.name(args) calls the hash's "name"
+// value if there is one and otherwise name(obj, args).
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+// PostfixExpression is i++ or i--. Target is an Identifier or an
+// IndexExpression; the expression updates it and, as in C, evaluates to the
+// value it had before.
+type PostfixExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+}
+
+// ComparisonChain is a relational comparison with more than two operands,
+// such as 0 <= i < n. It holds when every adjacent pair compares true, as
+// if the pairs were joined with &&, but each operand is evaluated at most
+// once. Operators[i] sits between Operands[i] and Operands[i+1].
+type ComparisonChain struct {
+	Token     token.Token
+	Operands  []Expression
+	Operators []string
+}
+
+// RangeExpression is from..to, or from..=to when Inclusive, and evaluates
+// to the array of integers it spans.
+type RangeExpression struct {
+	Token     token.Token
+	From      Expression
+	To        Expression
+	Inclusive bool
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
+// FunctionLiteral is fn(a, b, ...rest) { ... }. Rest is nil unless the
+// last parameter collects the remaining arguments into an array.
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Rest       *Identifier
+	ReturnType *TypeAnnotation
 	Body       *BlockStatement
 }
 
@@ -96,22 +257,29 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Rbracket token.Token
 }
 
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
 }
 
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Keys holds the keys of Pairs in source order. Keys that print the
+	// same, as in {f(): 1, f(): 2}, are told apart only by it.
+	Keys   []Expression
+	Rbrace token.Token
 }
 
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
@@ -151,11 +319,22 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
+// declaration prints a declared name with its type annotation, if any.
+func (i *Identifier) declaration() string {
+	if i.Type == nil {
+		return i.Value
+	}
+	return i.Value + ": " + i.Type.Name
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string       { return ta.Name }
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.Name.declaration())
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -165,6 +344,51 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+func (lg *LetGroup) String() string {
+	var out bytes.Buffer
+
+	bindings := []string{}
+	for _, b := range lg.Bindings {
+		binding := b.Name.declaration() + " = "
+		if b.Value != nil {
+			binding += b.Value.String()
+		}
+		bindings = append(bindings, binding)
+	}
+
+	out.WriteString(lg.TokenLiteral() + " ")
+	out.WriteString(strings.Join(bindings, ", "))
+	out.WriteString(";")
+	return out.String()
+}
+func (lg *LetGroup) TokenLiteral() string { return lg.Token.Literal }
+func (lg *LetGroup) statementNode()       {}
+
+func (dl *DestructuringLet) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+	for _, n := range dl.Names {
+		names = append(names, n.String())
+	}
+
+	out.WriteString(dl.TokenLiteral() + " ")
+	if dl.Pattern.Type == token.LBRACE {
+		out.WriteString("{" + strings.Join(names, ", ") + "}")
+	} else {
+		out.WriteString("[" + strings.Join(names, ", ") + "]")
+	}
+	out.WriteString(" = ")
+
+	if dl.Value != nil {
+		out.WriteString(dl.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+func (dl *DestructuringLet) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DestructuringLet) statementNode()       {}
+
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -189,6 +413,22 @@ func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) expressionNode()      {}
 
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) expressionNode()      {}
+
+func (be *BadExpression) String() string       { return "<bad expression>" }
+func (be *BadExpression) TokenLiteral() string { return be.From.Literal }
+func (be *BadExpression) expressionNode()      {}
+
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+func (bs *BadStatement) TokenLiteral() string { return bs.From.Literal }
+func (bs *BadStatement) statementNode()       {}
+
+func (nl *NullLiteral) String() string       { return "null" }
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) expressionNode()      {}
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -236,6 +476,102 @@ func (ifExp *IfExpression) String() string {
 func (ifExp *IfExpression) TokenLiteral() string { return ifExp.Token.Literal }
 func (ifExp *IfExpression) expressionNode()      {}
 
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(fs.Post.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) statementNode()       {}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	if ws.Condition != nil {
+		out.WriteString(ws.Condition.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) statementNode()       {}
+
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String() + " in ")
+	if fs.Iterable != nil {
+		out.WriteString(fs.Iterable.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) statementNode()       {}
+
+func (bs *BreakStatement) String() string       { return "break;" }
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) statementNode()       {}
+
+func (cs *ContinueStatement) String() string       { return "continue;" }
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) statementNode()       {}
+
+func (ae *AssignExpression) String() string {
+	return ae.Name.String() + " = " + ae.Value.String()
+}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) expressionNode()      {}
+
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) expressionNode()      {}
+
+func (pe *PostfixExpression) String() string {
+	return "(" + pe.Target.String() + pe.Operator + ")"
+}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) expressionNode()      {}
+
+func (cc *ComparisonChain) String() string {
+	pairs := make([]string, len(cc.Operators))
+	for i, op := range cc.Operators {
+		pairs[i] = "(" + cc.Operands[i].String() + " " + op + " " + cc.Operands[i+1].String() + ")"
+	}
+	return "(" + strings.Join(pairs, " && ") + ")"
+}
+func (cc *ComparisonChain) TokenLiteral() string { return cc.Token.Literal }
+func (cc *ComparisonChain) expressionNode()      {}
+
+func (re *RangeExpression) String() string {
+	return "(" + re.From.String() + re.Token.Literal + re.To.String() + ")"
+}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) expressionNode()      {}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -252,13 +588,19 @@ func (fl *FunctionLiteral) String() string {
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, p.declaration())
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.declaration())
 	}
 
 	out.WriteString(fl.Token.Literal)
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.Name)
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -274,7 +616,7 @@ func (ce *CallExpression) String() string {
 		args = append(args, a.String())
 	}
 
-	out.WriteString(ce.Token.Literal)
+	out.WriteString(ce.Function.String())
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -288,6 +630,44 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) expressionNode()      {}
 
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for _, part := range is.Parts {
+		if segment, ok := part.(*StringLiteral); ok {
+			out.WriteString(segment.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) expressionNode()      {}
+
+func (cl *CharLiteral) String() string {
+	switch cl.Value {
+	case '\n':
+		return `'\n'`
+	case '\t':
+		return `'\t'`
+	case '\r':
+		return `'\r'`
+	case '\'':
+		return `'\''`
+	case '\\':
+		return `'\\'`
+	}
+	return "'" + string(cl.Value) + "'"
+}
+func (cl *CharLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *CharLiteral) expressionNode()      {}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -323,8 +703,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
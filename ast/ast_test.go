@@ -26,3 +26,25 @@ func TestString(t *testing.T) {
 		t.Errorf("orgram.String() wrong. got = %q", program.String())
 	}
 }
+
+func TestLoopStrings(t *testing.T) {
+	body := &BlockStatement{Statements: []Statement{&BreakStatement{}, &ContinueStatement{}}}
+	tests := []struct {
+		node     Statement
+		expected string
+	}{
+		{&WhileStatement{Condition: ident("running"), Body: body}, "while (running) break;continue;"},
+		{&ForInStatement{Value: ident("x"), Iterable: ident("xs"), Body: body}, "for (x in xs) break;continue;"},
+		{&ForInStatement{Key: ident("k"), Value: ident("v"), Iterable: ident("h"), Body: &BlockStatement{}}, "for (k, v in h) "},
+	}
+	for _, tt := range tests {
+		if got := tt.node.String(); got != tt.expected {
+			t.Errorf("String() wrong. want=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	formatted := Format(&ForInStatement{Key: ident("k"), Value: ident("v"), Iterable: ident("h"), Body: body}, FormatOptions{Indent: "  "})
+	if want := "for (k, v in h) {\n  break;\n  continue;\n}"; formatted != want {
+		t.Errorf("Format wrong. want=%q, got=%q", want, formatted)
+	}
+}
@@ -0,0 +1,129 @@
+package ast
+
+import (
+	"reflect"
+	"simple-interpreter/token"
+	"strconv"
+)
+
+// The New functions build nodes for code generators and tests, filling in
+// the tokens the parser would have recorded, without source positions.
+
+func synthetic(typ token.TokenType, literal string) token.Token {
+	return token.Token{Type: typ, Literal: literal}
+}
+
+func NewProgram(stmts ...Statement) *Program {
+	return &Program{Statements: stmts}
+}
+
+func NewIdent(name string) *Identifier {
+	return &Identifier{Token: synthetic(token.IDENT, name), Value: name}
+}
+
+func NewInt(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: synthetic(token.INT, strconv.FormatInt(value, 10)), Value: value}
+}
+
+func NewString(value string) *StringLiteral {
+	return &StringLiteral{Token: synthetic(token.STRING, value), Value: value}
+}
+
+func NewBool(value bool) *Boolean {
+	if value {
+		return &Boolean{Token: synthetic(token.TRUE, "true"), Value: true}
+	}
+	return &Boolean{Token: synthetic(token.FALSE, "false"), Value: false}
+}
+
+func NewNull() *NullLiteral {
+	return &NullLiteral{Token: synthetic(token.IDENT, "null")}
+}
+
+func NewLet(name string, value Expression) *LetStatement {
+	return &LetStatement{Token: synthetic(token.LET, "let"), Name: NewIdent(name), Value: value}
+}
+
+// NewReturn builds a return statement; value may be nil.
+func NewReturn(value Expression) *ReturnStatement {
+	return &ReturnStatement{Token: synthetic(token.RETURN, "return"), ReturnValue: value}
+}
+
+// NewExpressionStatement takes its token from the start of exp, as the
+// parser does.
+func NewExpressionStatement(exp Expression) *ExpressionStatement {
+	return &ExpressionStatement{Token: firstToken(exp), Expression: exp}
+}
+
+func firstToken(exp Expression) token.Token {
+	for {
+		switch e := exp.(type) {
+		case *InfixExpression:
+			exp = e.Left
+		case *CallExpression:
+			exp = e.Function
+		case *IndexExpression:
+			exp = e.Left
+		case *AssignExpression:
+			return e.Name.Token
+		default:
+			if v := reflect.ValueOf(exp); v.Kind() == reflect.Ptr && !v.IsNil() {
+				if tok, ok := v.Elem().FieldByName("Token").Interface().(token.Token); ok {
+					return tok
+				}
+			}
+			return token.Token{}
+		}
+	}
+}
+
+func NewBlock(stmts ...Statement) *BlockStatement {
+	if stmts == nil {
+		stmts = []Statement{}
+	}
+	return &BlockStatement{Token: synthetic(token.LBRACE, "{"), Statements: stmts, Rbrace: synthetic(token.RBRACE, "}")}
+}
+
+// NewPrefix and NewInfix take the operator as written, such as "-" or "==".
+func NewPrefix(operator string, right Expression) *PrefixExpression {
+	return &PrefixExpression{Token: synthetic(token.TokenType(operator), operator), Operator: operator, Right: right}
+}
+
+func NewInfix(left Expression, operator string, right Expression) *InfixExpression {
+	return &InfixExpression{Token: synthetic(token.TokenType(operator), operator), Left: left, Operator: operator, Right: right}
+}
+
+// NewIf builds an if expression; alternative may be nil.
+func NewIf(condition Expression, consequence, alternative *BlockStatement) *IfExpression {
+	return &IfExpression{Token: synthetic(token.IF, "if"), Condition: condition, Consequence: consequence, Alternative: alternative}
+}
+
+func NewAssign(name string, value Expression) *AssignExpression {
+	return &AssignExpression{Token: synthetic(token.ASSIGN, "="), Name: NewIdent(name), Value: value}
+}
+
+func NewFunction(params []string, body *BlockStatement) *FunctionLiteral {
+	idents := make([]*Identifier, len(params))
+	for i, param := range params {
+		idents[i] = NewIdent(param)
+	}
+	return &FunctionLiteral{Token: synthetic(token.FUNCTION, "fn"), Parameters: idents, Body: body}
+}
+
+func NewCall(function Expression, args ...Expression) *CallExpression {
+	if args == nil {
+		args = []Expression{}
+	}
+	return &CallExpression{Token: synthetic(token.LPAREN, "("), Function: function, Arguments: args, Rparen: synthetic(token.RPAREN, ")")}
+}
+
+func NewArray(elements ...Expression) *ArrayLiteral {
+	if elements == nil {
+		elements = []Expression{}
+	}
+	return &ArrayLiteral{Token: synthetic(token.LBRACKET, "["), Elements: elements, Rbracket: synthetic(token.RBRACKET, "]")}
+}
+
+func NewIndex(left, index Expression) *IndexExpression {
+	return &IndexExpression{Token: synthetic(token.LBRACKET, "["), Left: left, Index: index, Rbracket: synthetic(token.RBRACKET, "]")}
+}
@@ -0,0 +1,43 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestBuilders(t *testing.T) {
+	program := NewProgram(
+		NewLet("add", NewFunction([]string{"a", "b"}, NewBlock(
+			NewReturn(NewInfix(NewIdent("a"), "+", NewIdent("b"))),
+		))),
+		NewExpressionStatement(NewIf(
+			NewPrefix("!", NewBool(false)),
+			NewBlock(NewExpressionStatement(NewCall(NewIdent("add"), NewInt(1), NewIndex(NewArray(NewString("x")), NewInt(0))))),
+			nil,
+		)),
+		NewExpressionStatement(NewAssign("n", NewNull())),
+	)
+
+	if err := Check(program); err != nil {
+		t.Errorf("built program fails Check:\n%s", err)
+	}
+	expected := `let add = fn(a, b) {
+	return a + b;
+};
+if (!false) {
+	add(1, ["x"][0]);
+};
+n = null;
+`
+	if got := Format(program, FormatOptions{}); got != expected {
+		t.Errorf("Format wrong.\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+
+	stmt := NewExpressionStatement(NewInfix(NewCall(NewIdent("f")), "*", NewInt(2)))
+	if stmt.Token.Type != token.IDENT || stmt.Token.Literal != "f" {
+		t.Errorf("expression statement token wrong. got=%+v", stmt.Token)
+	}
+	if tok := NewInfix(NewInt(1), "<=", NewInt(2)).Token; tok.Type != token.LT_EQ {
+		t.Errorf("infix token wrong. got=%+v", tok)
+	}
+}
@@ -0,0 +1,142 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A CheckError is a broken invariant found by Check. Path locates the
+// offending node as in Change.Path.
+type CheckError struct {
+	Path    string
+	Node    Node
+	Message string
+}
+
+func (e *CheckError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// CheckErrors is the list of problems returned by Check.
+type CheckErrors []*CheckError
+
+func (l CheckErrors) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// optional lists the fields that may be nil, by node type and field name.
+var optional = map[string]bool{
+	"*ast.ReturnStatement.ReturnValue": true,
+	"*ast.ForStatement.Init":           true,
+	"*ast.ForStatement.Condition":      true,
+	"*ast.ForStatement.Post":           true,
+	"*ast.ForInStatement.Key":          true,
+	"*ast.IfExpression.Alternative":    true,
+	"*ast.FunctionLiteral.Rest":        true,
+	"*ast.FunctionLiteral.ReturnType":  true,
+	"*ast.Identifier.Type":             true,
+}
+
+// Check verifies the invariants the evaluator and printers rely on, for
+// trees built by hand or by other tools: required children are present,
+// lists hold no nil elements, names and operators are not empty, blocks
+// have their opening token, and no part failed to parse. It returns nil or
+// a CheckErrors holding every problem found.
+func Check(node Node) error {
+	var errs CheckErrors
+	check(&errs, "", node)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func check(errs *CheckErrors, path string, node Node) {
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, &CheckError{Path: path, Node: node, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch n := node.(type) {
+	case *Identifier:
+		if n.Value == "" {
+			report("identifier has no name")
+		}
+	case *TypeAnnotation:
+		if n.Name == "" {
+			report("type annotation has no name")
+		}
+	case *BlockStatement:
+		if n.Token.Type == "" {
+			report("block has no token")
+		}
+	case *PrefixExpression:
+		if n.Operator == "" {
+			report("prefix expression has no operator")
+		}
+	case *InfixExpression:
+		if n.Operator == "" {
+			report("infix expression has no operator")
+		}
+	case *PostfixExpression:
+		if n.Operator == "" {
+			report("postfix expression has no operator")
+		}
+	case *ComparisonChain:
+		if len(n.Operands) < 2 || len(n.Operands) != len(n.Operators)+1 {
+			report("comparison chain has %d operands for %d operators", len(n.Operands), len(n.Operators))
+		}
+	case *LetGroup:
+		if len(n.Bindings) == 0 {
+			report("let group has no bindings")
+		}
+	case *DestructuringLet:
+		if len(n.Names) == 0 {
+			report("destructuring let has no names")
+		}
+	case *HashLiteral:
+		for key := range n.Pairs {
+			if isNil(key) {
+				report("hash literal has a nil key")
+			}
+		}
+		if n.Keys != nil && !n.keysMatch() {
+			report("hash literal keys do not match its pairs")
+		}
+	case *BadExpression, *BadStatement:
+		report("code failed to parse")
+	}
+
+	typ := fmt.Sprintf("%T", node)
+	for _, f := range children(node) {
+		p := f.name
+		if path != "" {
+			p = path + "." + p
+		}
+		if !f.list {
+			child := f.nodes[0]
+			if isNil(child) {
+				if !optional[typ+"."+f.name] {
+					*errs = append(*errs, &CheckError{Path: p, Node: node, Message: fmt.Sprintf("%s has no %s", typ[len("*ast."):], f.name)})
+				}
+				continue
+			}
+			check(errs, p, child)
+			continue
+		}
+		for i, child := range f.nodes {
+			elem := fmt.Sprintf("%s[%d]", p, i)
+			if isNil(child) {
+				*errs = append(*errs, &CheckError{Path: elem, Node: node, Message: "list element is nil"})
+				continue
+			}
+			check(errs, elem, child)
+		}
+	}
+}
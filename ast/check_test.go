@@ -0,0 +1,54 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	brace := token.Token{Type: token.LBRACE, Literal: "{"}
+	valid := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition:   &InfixExpression{Left: ident("a"), Operator: "<", Right: integer(1)},
+			Consequence: &BlockStatement{Token: brace, Statements: []Statement{&ReturnStatement{}}},
+		}},
+		&ExpressionStatement{Expression: &FunctionLiteral{
+			Parameters: []*Identifier{ident("x")},
+			Body:       &BlockStatement{Token: brace},
+		}},
+	}}
+	if err := Check(valid); err != nil {
+		t.Errorf("valid program failed Check: %s", err)
+	}
+
+	broken := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{}, Value: &InfixExpression{Left: integer(1), Right: nil}},
+		nil,
+		&ExpressionStatement{Expression: &CallExpression{
+			Function:  ident("f"),
+			Arguments: []Expression{&FunctionLiteral{Body: &BlockStatement{}}, &BadExpression{}},
+		}},
+		&ExpressionStatement{Expression: &HashLiteral{
+			Pairs: map[Expression]Expression{str("a"): integer(1)},
+			Keys:  []Expression{str("a")},
+		}},
+	}}
+	expected := `Statements[0].Name: identifier has no name
+Statements[0].Value: infix expression has no operator
+Statements[0].Value.Right: InfixExpression has no Right
+Statements[1]: list element is nil
+Statements[2].Expression.Arguments[0].Body: block has no token
+Statements[2].Expression.Arguments[1]: code failed to parse
+Statements[3].Expression: hash literal keys do not match its pairs`
+
+	err := Check(broken)
+	if err == nil {
+		t.Fatal("expected Check to fail")
+	}
+	if err.Error() != expected {
+		t.Errorf("Check errors wrong.\nwant:\n%s\ngot:\n%s", expected, err)
+	}
+	if errs, ok := err.(CheckErrors); !ok || len(errs) != 7 || errs[1].Node == nil {
+		t.Errorf("expected 7 CheckErrors with nodes, got %#v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type ChangeKind int
+
+const (
+	Inserted ChangeKind = iota
+	Deleted
+	Replaced
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Inserted:
+		return "inserted"
+	case Deleted:
+		return "deleted"
+	default:
+		return "replaced"
+	}
+}
+
+// A Change is one difference found by Diff. Path names the field that
+// changed, starting from the root, as in "Statements[1].Value.Left". An
+// index into a list counts in the new tree, except for deletions, where it
+// counts in the old one.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	// Old is nil for an insertion and New is nil for a deletion.
+	Old, New Node
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "root"
+	}
+	switch c.Kind {
+	case Inserted:
+		return fmt.Sprintf("inserted %s: %s", path, c.New)
+	case Deleted:
+		return fmt.Sprintf("deleted %s: %s", path, c.Old)
+	default:
+		return fmt.Sprintf("replaced %s: %s -> %s", path, c.Old, c.New)
+	}
+}
+
+// Diff lists the changes that turn tree a into tree b, comparing nodes as
+// Equal does, so it returns nothing for trees that differ only in layout.
+// Nodes of the same kind with the same operator or value are compared
+// child by child, and lists such as statements and arguments are aligned
+// so that an inserted element does not show up as a change to every
+// element after it.
+func Diff(a, b Node) []Change {
+	var d differ
+	d.node("", a, b)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(kind ChangeKind, path string, old, new Node) {
+	d.changes = append(d.changes, Change{Kind: kind, Path: path, Old: old, New: new})
+}
+
+func (d *differ) node(path string, a, b Node) {
+	switch {
+	case Equal(a, b):
+	case isNil(a):
+		d.add(Inserted, path, nil, b)
+	case isNil(b):
+		d.add(Deleted, path, a, nil)
+	case !sameShape(a, b):
+		d.add(Replaced, path, a, b)
+	default:
+		fa, fb := children(a), children(b)
+		for i := range fa {
+			p := fa[i].name
+			if path != "" {
+				p = path + "." + p
+			}
+			if fa[i].list {
+				d.list(p, fa[i].nodes, fb[i].nodes)
+			} else {
+				d.node(p, fa[i].nodes[0], fb[i].nodes[0])
+			}
+		}
+	}
+}
+
+// list aligns a and b on their longest common subsequence of equal
+// elements. Within a run of unmatched elements, an insertion is paired with
+// the next deletion of the same node type and the two are diffed in place.
+func (d *differ) list(path string, a, b []Node) {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	at := func(i int) string { return fmt.Sprintf("%s[%d]", path, i) }
+	var deleted, inserted []int
+	flush := func() {
+		next := 0
+		for _, j := range inserted {
+			q := next
+			for q < len(deleted) && reflect.TypeOf(a[deleted[q]]) != reflect.TypeOf(b[j]) {
+				q++
+			}
+			if q == len(deleted) {
+				d.add(Inserted, at(j), nil, b[j])
+				continue
+			}
+			for _, i := range deleted[next:q] {
+				d.add(Deleted, at(i), a[i], nil)
+			}
+			d.node(at(j), a[deleted[q]], b[j])
+			next = q + 1
+		}
+		for _, i := range deleted[next:] {
+			d.add(Deleted, at(i), a[i], nil)
+		}
+		deleted, inserted = deleted[:0], inserted[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && Equal(a[i], b[j]):
+			flush()
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			inserted = append(inserted, j)
+			j++
+		default:
+			deleted = append(deleted, i)
+			i++
+		}
+	}
+	flush()
+}
+
+// sameShape reports whether a and b are the same kind of node with the
+// same operator, name or value, so that their differences lie in their
+// children.
+func sameShape(a, b Node) bool {
+	switch a := a.(type) {
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator
+	case *PostfixExpression:
+		b, ok := b.(*PostfixExpression)
+		return ok && a.Operator == b.Operator
+	case *ComparisonChain:
+		b, ok := b.(*ComparisonChain)
+		return ok && strings.Join(a.Operators, " ") == strings.Join(b.Operators, " ")
+	case *RangeExpression:
+		b, ok := b.(*RangeExpression)
+		return ok && a.Inclusive == b.Inclusive
+	case *DestructuringLet:
+		b, ok := b.(*DestructuringLet)
+		return ok && a.Pattern.Type == b.Pattern.Type
+	}
+	// Leaves are Equal exactly when they have the same value.
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && len(children(a)) > 0
+}
+
+type field struct {
+	name  string
+	list  bool
+	nodes []Node
+}
+
+func one(name string, n Node) field { return field{name: name, nodes: []Node{n}} }
+
+func many[T Node](name string, nodes []T) field {
+	f := field{name: name, list: true, nodes: make([]Node, len(nodes))}
+	for i, n := range nodes {
+		f.nodes[i] = n
+	}
+	return f
+}
+
+// children lists the fields of n that hold nodes, in source order. Nodes
+// of the same type always have the same fields.
+func children(n Node) []field {
+	switch n := n.(type) {
+	case *Program:
+		return []field{many("Statements", n.Statements)}
+	case *LetStatement:
+		return []field{one("Name", n.Name), one("Value", n.Value)}
+	case *LetGroup:
+		return []field{many("Bindings", n.Bindings)}
+	case *DestructuringLet:
+		return []field{many("Names", n.Names), one("Value", n.Value)}
+	case *ReturnStatement:
+		return []field{one("ReturnValue", n.ReturnValue)}
+	case *ExpressionStatement:
+		return []field{one("Expression", n.Expression)}
+	case *ForStatement:
+		return []field{one("Init", n.Init), one("Condition", n.Condition), one("Post", n.Post), one("Body", n.Body)}
+	case *WhileStatement:
+		return []field{one("Condition", n.Condition), one("Body", n.Body)}
+	case *ForInStatement:
+		return []field{one("Key", n.Key), one("Value", n.Value), one("Iterable", n.Iterable), one("Body", n.Body)}
+	case *BlockStatement:
+		return []field{many("Statements", n.Statements)}
+	case *Identifier:
+		return []field{one("Type", n.Type)}
+	case *PrefixExpression:
+		return []field{one("Right", n.Right)}
+	case *InfixExpression:
+		return []field{one("Left", n.Left), one("Right", n.Right)}
+	case *IfExpression:
+		return []field{one("Condition", n.Condition), one("Consequence", n.Consequence), one("Alternative", n.Alternative)}
+	case *AssignExpression:
+		return []field{one("Name", n.Name), one("Value", n.Value)}
+	case *MemberExpression:
+		return []field{one("Object", n.Object), one("Property", n.Property)}
+	case *PostfixExpression:
+		return []field{one("Target", n.Target)}
+	case *ComparisonChain:
+		return []field{many("Operands", n.Operands)}
+	case *RangeExpression:
+		return []field{one("From", n.From), one("To", n.To)}
+	case *FunctionLiteral:
+		return []field{many("Parameters", n.Parameters), one("Rest", n.Rest), one("ReturnType", n.ReturnType), one("Body", n.Body)}
+	case *CallExpression:
+		return []field{one("Function", n.Function), many("Arguments", n.Arguments)}
+	case *ArrayLiteral:
+		return []field{many("Elements", n.Elements)}
+	case *InterpolatedString:
+		return []field{many("Parts", n.Parts)}
+	case *IndexExpression:
+		return []field{one("Left", n.Left), one("Index", n.Index)}
+	case *HashLiteral:
+		keys := n.OrderedKeys()
+		pairs := make([]Node, len(keys))
+		for i, key := range keys {
+			pairs[i] = &hashPair{key, n.Pairs[key]}
+		}
+		return []field{{name: "Pairs", list: true, nodes: pairs}}
+	case *hashPair:
+		return []field{one("Key", n.key), one("Value", n.value)}
+	}
+	return nil
+}
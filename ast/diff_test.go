@@ -0,0 +1,115 @@
+package ast
+
+import (
+	"fmt"
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	let := func(name string, value Expression) Statement {
+		return &LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident(name), Value: value}
+	}
+	expr := func(exp Expression) Statement {
+		return &ExpressionStatement{Expression: exp}
+	}
+	sum := func(left, right Expression) Expression {
+		return &InfixExpression{Left: left, Operator: "+", Right: right}
+	}
+	old := &Program{Statements: []Statement{
+		let("x", integer(1)),
+		let("y", sum(ident("x"), integer(2))),
+		expr(&CallExpression{Function: ident("f"), Arguments: []Expression{ident("x"), ident("y")}}),
+	}}
+
+	tests := []struct {
+		name     string
+		new      *Program
+		expected []string
+	}{
+		{"unchanged", &Program{Statements: old.Statements}, nil},
+		{
+			"changed operand",
+			&Program{Statements: []Statement{
+				let("x", integer(1)),
+				let("y", sum(ident("x"), integer(3))),
+				old.Statements[2],
+			}},
+			[]string{"replaced Statements[1].Value.Right: 2 -> 3"},
+		},
+		{
+			"inserted statement",
+			&Program{Statements: []Statement{
+				old.Statements[0],
+				let("z", integer(0)),
+				old.Statements[1],
+				old.Statements[2],
+			}},
+			[]string{"inserted Statements[1]: let z = 0;"},
+		},
+		{
+			"deleted argument and statement",
+			&Program{Statements: []Statement{
+				old.Statements[0],
+				expr(&CallExpression{Function: ident("f"), Arguments: []Expression{ident("x")}}),
+			}},
+			[]string{"deleted Statements[1]: let y = (x + 2);", "deleted Statements[1].Expression.Arguments[1]: y"},
+		},
+		{
+			"changed operator",
+			&Program{Statements: []Statement{
+				old.Statements[0],
+				let("y", &InfixExpression{Left: ident("x"), Operator: "-", Right: integer(2)}),
+				old.Statements[2],
+			}},
+			[]string{"replaced Statements[1].Value: (x + 2) -> (x - 2)"},
+		},
+		{
+			"added type annotation",
+			&Program{Statements: []Statement{
+				&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: &Identifier{Value: "x", Type: &TypeAnnotation{Name: "int"}}, Value: integer(1)},
+				old.Statements[1],
+				old.Statements[2],
+			}},
+			[]string{"inserted Statements[0].Name.Type: int"},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, c := range Diff(old, tt.new) {
+			got = append(got, c.String())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: Diff wrong.\nwant=%q\ngot =%q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestDiffHashAndOptionalParts(t *testing.T) {
+	a := &IfExpression{
+		Condition:   ident("c"),
+		Consequence: &BlockStatement{Statements: []Statement{}},
+		Alternative: nil,
+	}
+	b := &IfExpression{
+		Condition:   ident("c"),
+		Consequence: &BlockStatement{Statements: []Statement{}},
+		Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{str("k"): integer(1)}}}}},
+	}
+	if changes := Diff(&CallExpression{Function: ident("f"), Arguments: []Expression{ident("x")}},
+		&CallExpression{Function: ident("f"), Arguments: []Expression{ident("y")}}); len(changes) != 1 || changes[0].String() != "replaced Arguments[0]: x -> y" {
+		t.Errorf("unexpected argument diff: %v", changes)
+	}
+
+	changes := Diff(a, b)
+	if len(changes) != 1 || changes[0].Kind != Inserted || changes[0].Path != "Alternative" {
+		t.Fatalf("expected an inserted Alternative, got %v", changes)
+	}
+
+	c := &HashLiteral{Pairs: map[Expression]Expression{str("k"): integer(2)}}
+	changes = Diff(b.Alternative.Statements[0].(*ExpressionStatement).Expression, c)
+	if len(changes) != 1 || changes[0].String() != "replaced Pairs[0].Value: 1 -> 2" {
+		t.Errorf("unexpected hash diff: %v", changes)
+	}
+}
@@ -0,0 +1,220 @@
+package ast
+
+import "reflect"
+
+// Equal reports whether a and b are the same tree. Tokens are not compared,
+// so nodes parsed from different places, or built by hand without tokens,
+// are equal when their operators, names, values and children are. Hash
+// literals are equal when they hold equal pairs, in whatever order.
+func Equal(a, b Node) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && equalStatements(a.Statements, b.Statements)
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *LetGroup:
+		b, ok := b.(*LetGroup)
+		if !ok || len(a.Bindings) != len(b.Bindings) {
+			return false
+		}
+		for i := range a.Bindings {
+			if !Equal(a.Bindings[i], b.Bindings[i]) {
+				return false
+			}
+		}
+		return true
+	case *DestructuringLet:
+		b, ok := b.(*DestructuringLet)
+		return ok && a.Pattern.Type == b.Pattern.Type &&
+			equalIdentifiers(a.Names, b.Names) && Equal(a.Value, b.Value)
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.ReturnValue, b.ReturnValue)
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *ForStatement:
+		b, ok := b.(*ForStatement)
+		return ok && Equal(a.Init, b.Init) && Equal(a.Condition, b.Condition) &&
+			Equal(a.Post, b.Post) && Equal(a.Body, b.Body)
+	case *WhileStatement:
+		b, ok := b.(*WhileStatement)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Body, b.Body)
+	case *ForInStatement:
+		b, ok := b.(*ForInStatement)
+		return ok && Equal(a.Key, b.Key) && Equal(a.Value, b.Value) &&
+			Equal(a.Iterable, b.Iterable) && Equal(a.Body, b.Body)
+	case *BreakStatement:
+		_, ok := b.(*BreakStatement)
+		return ok
+	case *ContinueStatement:
+		_, ok := b.(*ContinueStatement)
+		return ok
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && equalStatements(a.Statements, b.Statements)
+
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value && Equal(a.Type, b.Type)
+	case *TypeAnnotation:
+		b, ok := b.(*TypeAnnotation)
+		return ok && a.Name == b.Name
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *FloatLiteral:
+		b, ok := b.(*FloatLiteral)
+		return ok && a.Value == b.Value
+	case *NullLiteral:
+		_, ok := b.(*NullLiteral)
+		return ok
+	case *BadExpression:
+		_, ok := b.(*BadExpression)
+		return ok
+	case *BadStatement:
+		_, ok := b.(*BadStatement)
+		return ok
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *CharLiteral:
+		b, ok := b.(*CharLiteral)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) &&
+			Equal(a.Consequence, b.Consequence) && Equal(a.Alternative, b.Alternative)
+	case *AssignExpression:
+		b, ok := b.(*AssignExpression)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Value, b.Value)
+	case *MemberExpression:
+		b, ok := b.(*MemberExpression)
+		return ok && Equal(a.Object, b.Object) && Equal(a.Property, b.Property)
+	case *PostfixExpression:
+		b, ok := b.(*PostfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Target, b.Target)
+	case *ComparisonChain:
+		b, ok := b.(*ComparisonChain)
+		if !ok || len(a.Operators) != len(b.Operators) {
+			return false
+		}
+		for i := range a.Operators {
+			if a.Operators[i] != b.Operators[i] {
+				return false
+			}
+		}
+		return equalExpressions(a.Operands, b.Operands)
+	case *RangeExpression:
+		b, ok := b.(*RangeExpression)
+		return ok && a.Inclusive == b.Inclusive && Equal(a.From, b.From) && Equal(a.To, b.To)
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		return ok && equalIdentifiers(a.Parameters, b.Parameters) &&
+			Equal(a.Rest, b.Rest) && Equal(a.ReturnType, b.ReturnType) && Equal(a.Body, b.Body)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		return ok && Equal(a.Function, b.Function) && equalExpressions(a.Arguments, b.Arguments)
+	case *ArrayLiteral:
+		b, ok := b.(*ArrayLiteral)
+		return ok && equalExpressions(a.Elements, b.Elements)
+	case *InterpolatedString:
+		b, ok := b.(*InterpolatedString)
+		return ok && equalExpressions(a.Parts, b.Parts)
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+	case *HashLiteral:
+		b, ok := b.(*HashLiteral)
+		return ok && equalPairs(a.Pairs, b.Pairs)
+	case *hashPair:
+		b, ok := b.(*hashPair)
+		return ok && Equal(a.key, b.key) && Equal(a.value, b.value)
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// isNil reports whether n is nil or a nil pointer, such as the missing
+// alternative of an if passed in as a Node.
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func equalStatements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalExpressions(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalIdentifiers(a, b []*Identifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalPairs matches each pair of a with a distinct equal pair of b. Keys
+// are compared structurally, so the maps cannot be indexed by each other.
+func equalPairs(a, b map[Expression]Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	matched := make(map[Expression]bool, len(b))
+	for keyA, valueA := range a {
+		found := false
+		for keyB, valueB := range b {
+			if !matched[keyB] && Equal(keyA, keyB) && Equal(valueA, valueB) {
+				matched[keyB] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
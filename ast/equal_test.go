@@ -0,0 +1,60 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	at := func(line int, literal string) token.Token {
+		return token.Token{Type: token.IDENT, Literal: literal, Line: line}
+	}
+	sum := func(line int) Expression {
+		return &InfixExpression{
+			Left:     &Identifier{Token: at(line, "a"), Value: "a"},
+			Operator: "+",
+			Right:    &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "0x10", Line: line}, Value: 16},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		a, b     Node
+		expected bool
+	}{
+		{"positions ignored", sum(1), sum(7), true},
+		{"spelling ignored", sum(1), &InfixExpression{Left: ident("a"), Operator: "+", Right: integer(16)}, true},
+		{"different operator", sum(1), &InfixExpression{Left: ident("a"), Operator: "-", Right: integer(16)}, false},
+		{"different type", ident("a"), str("a"), false},
+		{"both nil", nil, nil, true},
+		{"nil pointer", (*BlockStatement)(nil), nil, true},
+		{"missing else", &IfExpression{Condition: ident("x")}, &IfExpression{Condition: ident("x"), Alternative: &BlockStatement{}}, false},
+		{"different length", &ArrayLiteral{Elements: []Expression{integer(1)}}, &ArrayLiteral{}, false},
+		{
+			"hash pairs unordered",
+			&HashLiteral{Pairs: map[Expression]Expression{str("a"): integer(1), str("b"): integer(2)}},
+			&HashLiteral{Pairs: map[Expression]Expression{str("b"): integer(2), str("a"): integer(1)}},
+			true,
+		},
+		{
+			"hash values differ",
+			&HashLiteral{Pairs: map[Expression]Expression{str("a"): integer(1)}},
+			&HashLiteral{Pairs: map[Expression]Expression{str("a"): integer(2)}},
+			false,
+		},
+		{
+			"destructuring pattern",
+			&DestructuringLet{Pattern: token.Token{Type: token.LBRACKET}, Names: []*Identifier{ident("x")}, Value: ident("v")},
+			&DestructuringLet{Pattern: token.Token{Type: token.LBRACE}, Names: []*Identifier{ident("x")}, Value: ident("v")},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("%s: Equal = %t, want %t", tt.name, got, tt.expected)
+		}
+		if got := Equal(tt.b, tt.a); got != tt.expected {
+			t.Errorf("%s: Equal reversed = %t, want %t", tt.name, got, tt.expected)
+		}
+	}
+}
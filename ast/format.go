@@ -0,0 +1,481 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatOptions controls Format. The zero value indents with a tab and never
+// breaks lists.
+type FormatOptions struct {
+	// Indent is written once per nesting level; empty means a tab.
+	Indent string
+	// Width is the line length past which array, hash and argument lists
+	// are broken one element per line. Zero means lists are never broken.
+	Width int
+}
+
+// Format renders node as indented source. Unlike String, the output is
+// meant to be read and parsed back: blocks span several lines, strings are
+// quoted and parentheses appear only where precedence or associativity
+// requires them.
+func Format(node Node, opts FormatOptions) string {
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+	p := &printer{opts: opts}
+	p.node(node)
+	return p.out.String()
+}
+
+type printer struct {
+	opts  FormatOptions
+	out   strings.Builder
+	depth int
+	// col is the width of the current line so far, counting an indent
+	// string as its length in runes.
+	col int
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.col += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.write("\n" + strings.Repeat(p.opts.Indent, p.depth))
+}
+
+func (p *printer) node(node Node) {
+	switch node := node.(type) {
+	case *Program:
+		for _, stmt := range node.Statements {
+			p.statement(stmt)
+			p.write("\n")
+		}
+	case *BlockStatement:
+		p.block(node)
+	case Statement:
+		p.statement(node)
+	case Expression:
+		p.expression(node)
+	}
+}
+
+func (p *printer) statement(stmt Statement) {
+	switch stmt := stmt.(type) {
+	case *LetStatement:
+		p.write("let ")
+		p.binding(stmt)
+		p.write(";")
+	case *LetGroup:
+		p.write("let ")
+		for i, binding := range stmt.Bindings {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.binding(binding)
+		}
+		p.write(";")
+	case *DestructuringLet:
+		names := make([]string, len(stmt.Names))
+		for i, name := range stmt.Names {
+			names[i] = name.Value
+		}
+		if stmt.Pattern.Type == token.LBRACE {
+			p.write("let {" + strings.Join(names, ", ") + "} = ")
+		} else {
+			p.write("let [" + strings.Join(names, ", ") + "] = ")
+		}
+		p.expression(stmt.Value)
+		p.write(";")
+	case *ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expression(stmt.ReturnValue)
+		}
+		p.write(";")
+	case *ExpressionStatement:
+		p.expression(stmt.Expression)
+		p.write(";")
+	case *ForStatement:
+		p.write("for (")
+		if stmt.Init != nil {
+			p.statement(stmt.Init)
+		} else {
+			p.write(";")
+		}
+		if stmt.Condition != nil {
+			p.write(" ")
+			p.expression(stmt.Condition)
+		}
+		p.write(";")
+		if stmt.Post != nil {
+			p.write(" ")
+			p.expression(stmt.Post)
+		}
+		p.write(") ")
+		p.block(stmt.Body)
+	case *WhileStatement:
+		p.write("while (")
+		p.expression(stmt.Condition)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ForInStatement:
+		p.write("for (")
+		if stmt.Key != nil {
+			p.write(stmt.Key.Value + ", ")
+		}
+		p.write(stmt.Value.Value + " in ")
+		p.expression(stmt.Iterable)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *BreakStatement:
+		p.write("break;")
+	case *ContinueStatement:
+		p.write("continue;")
+	default:
+		p.write(stmt.String())
+	}
+}
+
+func (p *printer) binding(stmt *LetStatement) {
+	p.write(stmt.Name.declaration() + " = ")
+	p.expression(stmt.Value)
+}
+
+func (p *printer) block(block *BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.depth++
+	for _, stmt := range block.Statements {
+		p.newline()
+		p.statement(stmt)
+	}
+	p.depth--
+	p.newline()
+	p.write("}")
+}
+
+func (p *printer) expression(exp Expression) {
+	switch exp := exp.(type) {
+	case nil:
+	case *Identifier:
+		p.write(exp.Value)
+	case *IntegerLiteral:
+		if exp.Token.Literal != "" {
+			p.write(exp.Token.Literal)
+		} else {
+			p.write(strconv.FormatInt(exp.Value, 10))
+		}
+	case *FloatLiteral:
+		if exp.Token.Literal != "" {
+			p.write(exp.Token.Literal)
+		} else {
+			p.write(formatFloat(exp.Value))
+		}
+	case *NullLiteral:
+		p.write("null")
+	case *StringLiteral:
+		p.write(quote(exp.Value))
+	case *InterpolatedString:
+		p.write(`"`)
+		for _, part := range exp.Parts {
+			if segment, ok := part.(*StringLiteral); ok {
+				quoted := quote(segment.Value)
+				p.write(quoted[1 : len(quoted)-1])
+				continue
+			}
+			p.write("${")
+			p.expression(part)
+			p.write("}")
+		}
+		p.write(`"`)
+	case *Boolean:
+		p.write(strconv.FormatBool(exp.Value))
+	case *PrefixExpression:
+		p.write(exp.Operator)
+		if right, ok := exp.Right.(*PrefixExpression); exp.Operator == "-" && (ok && right.Operator == "-" || negative(exp.Right)) {
+			// --x would read like a decrement.
+			p.write("(")
+			p.expression(exp.Right)
+			p.write(")")
+		} else {
+			p.operand(exp.Right, precPrefix)
+		}
+	case *InfixExpression:
+		// Infix operators group left to right, so a + b + c needs no
+		// parentheses around a + b, but a - (b - c) does. A comparison
+		// needs them on both sides, as a < b < c would make a chain.
+		prec := infixPrecedence[exp.Operator]
+		left := prec
+		if prec == precCompare {
+			left++
+		}
+		p.operand(exp.Left, left)
+		p.write(" " + exp.Operator + " ")
+		p.operand(exp.Right, prec+1)
+	case *ComparisonChain:
+		for i, operand := range exp.Operands {
+			if i > 0 {
+				p.write(" " + exp.Operators[i-1] + " ")
+			}
+			p.operand(operand, precCompare+1)
+		}
+	case *RangeExpression:
+		p.operand(exp.From, precRange)
+		p.write(rangeOperator(exp))
+		p.operand(exp.To, precRange+1)
+	case *AssignExpression:
+		p.write(exp.Name.Value + " = ")
+		p.expression(exp.Value)
+	case *IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+	case *FunctionLiteral:
+		params := make([]string, 0, len(exp.Parameters)+1)
+		for _, param := range exp.Parameters {
+			params = append(params, param.declaration())
+		}
+		if exp.Rest != nil {
+			params = append(params, "..."+exp.Rest.declaration())
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ")")
+		if exp.ReturnType != nil {
+			p.write(": " + exp.ReturnType.Name)
+		}
+		p.write(" ")
+		p.block(exp.Body)
+	case *CallExpression:
+		p.operand(exp.Function, precPostfix)
+		p.list("(", exp.Arguments, ")")
+	case *ArrayLiteral:
+		p.list("[", exp.Elements, "]")
+	case *IndexExpression:
+		p.operand(exp.Left, precPostfix)
+		p.write("[")
+		p.expression(exp.Index)
+		p.write("]")
+	case *MemberExpression:
+		p.operand(exp.Object, precPostfix)
+		p.write("." + exp.Property.Value)
+	case *PostfixExpression:
+		p.operand(exp.Target, precPostfix)
+		p.write(exp.Operator)
+	case *HashLiteral:
+		keys := exp.OrderedKeys()
+		pairs := make([]Expression, len(keys))
+		for i, key := range keys {
+			pairs[i] = &hashPair{key, exp.Pairs[key]}
+		}
+		p.list("{", pairs, "}")
+	case *hashPair:
+		p.expression(exp.key)
+		p.write(": ")
+		p.expression(exp.value)
+	default:
+		p.write(exp.String())
+	}
+}
+
+// Binding strengths for printing, in the order of the parser's precedence
+// table. Calls, indexes, members and postfix operators share precPostfix.
+const (
+	precLowest = iota
+	precAssign
+	precOr
+	precAnd
+	precEquals
+	precCompare
+	precRange
+	precSum
+	precProduct
+	precPrefix
+	precPostfix
+	precAtom
+)
+
+var infixPrecedence = map[string]int{
+	"||": precOr,
+	"&&": precAnd,
+	"==": precEquals,
+	"!=": precEquals,
+	"<":  precCompare,
+	">":  precCompare,
+	"<=": precCompare,
+	">=": precCompare,
+	"+":  precSum,
+	"-":  precSum,
+	"|":  precSum,
+	"^":  precSum,
+	"*":  precProduct,
+	"/":  precProduct,
+	"%":  precProduct,
+	"&":  precProduct,
+	"<<": precProduct,
+	">>": precProduct,
+}
+
+func precedence(exp Expression) int {
+	switch exp := exp.(type) {
+	case *AssignExpression:
+		return precAssign
+	case *InfixExpression:
+		if prec, ok := infixPrecedence[exp.Operator]; ok {
+			return prec
+		}
+		return precLowest
+	case *ComparisonChain:
+		return precCompare
+	case *RangeExpression:
+		return precRange
+	case *PrefixExpression:
+		return precPrefix
+	case *CallExpression, *IndexExpression, *MemberExpression, *PostfixExpression:
+		return precPostfix
+	case *IntegerLiteral, *FloatLiteral:
+		if negative(exp) {
+			return precPrefix
+		}
+	}
+	return precAtom
+}
+
+// negative reports whether exp is a literal printed with a leading minus,
+// such as one made by folding, which binds like a prefix expression.
+func negative(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *IntegerLiteral:
+		if exp.Token.Literal != "" {
+			return strings.HasPrefix(exp.Token.Literal, "-")
+		}
+		return exp.Value < 0
+	case *FloatLiteral:
+		if exp.Token.Literal != "" {
+			return strings.HasPrefix(exp.Token.Literal, "-")
+		}
+		return strings.HasPrefix(formatFloat(exp.Value), "-")
+	}
+	return false
+}
+
+// operand prints exp, parenthesized if it binds more loosely than min.
+func (p *printer) operand(exp Expression, min int) {
+	if precedence(exp) >= min {
+		p.expression(exp)
+		return
+	}
+	p.write("(")
+	p.expression(exp)
+	p.write(")")
+}
+
+func rangeOperator(exp *RangeExpression) string {
+	if exp.Inclusive {
+		return "..="
+	}
+	return ".."
+}
+
+// list prints elements between open and close, on one line if it fits in
+// the width and otherwise one element per line with a trailing comma.
+func (p *printer) list(open string, elements []Expression, close string) {
+	flat := &printer{opts: FormatOptions{Indent: p.opts.Indent}, depth: p.depth}
+	for i, elem := range elements {
+		if i > 0 {
+			flat.write(", ")
+		}
+		flat.expression(elem)
+	}
+	inline := flat.out.String()
+	if len(elements) == 0 || p.opts.Width == 0 ||
+		!strings.Contains(inline, "\n") && p.col+utf8.RuneCountInString(open+inline+close) <= p.opts.Width {
+		p.write(open + inline + close)
+		return
+	}
+
+	p.write(open)
+	p.depth++
+	for _, elem := range elements {
+		p.newline()
+		p.expression(elem)
+		p.write(",")
+	}
+	p.depth--
+	p.newline()
+	p.write(close)
+}
+
+// hashPair lets the pairs of a hash literal be printed as a list.
+type hashPair struct {
+	key, value Expression
+}
+
+func (hp *hashPair) TokenLiteral() string { return "" }
+func (hp *hashPair) String() string       { return hp.key.String() + ":" + hp.value.String() }
+func (hp *hashPair) expressionNode()      {}
+func (hp *hashPair) Pos() token.Position  { return hp.key.Pos() }
+func (hp *hashPair) End() token.Position  { return hp.value.End() }
+func (hp *hashPair) Kind() NodeKind       { return KindInvalid }
+
+// formatFloat writes f so that it reads back as a float rather than an
+// integer.
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// quote writes s as a string literal the lexer reads back as s.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '$':
+			if strings.HasPrefix(s[i+1:], "{") {
+				b.WriteString(`\$`)
+			} else {
+				b.WriteByte('$')
+			}
+		default:
+			if r < ' ' || r == 0x7f {
+				b.WriteString(`\u`)
+				b.WriteString(strings.Repeat("0", 4-len(strconv.FormatInt(int64(r), 16))))
+				b.WriteString(strconv.FormatInt(int64(r), 16))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package ast
+
+import (
+	"simple-interpreter/token"
+	"testing"
+)
+
+func str(s string) *StringLiteral {
+	return &StringLiteral{Value: s}
+}
+
+func TestFormatWidth(t *testing.T) {
+	call := &ExpressionStatement{Expression: &CallExpression{
+		Function: ident("configure"),
+		Arguments: []Expression{
+			&ArrayLiteral{Elements: []Expression{integer(1), integer(2), integer(3)}},
+			&HashLiteral{Pairs: map[Expression]Expression{str("name"): str("demo")}},
+		},
+	}}
+	block := &BlockStatement{Statements: []Statement{call}}
+
+	tests := []struct {
+		opts     FormatOptions
+		expected string
+	}{
+		{FormatOptions{}, "{\n\tconfigure([1, 2, 3], {\"name\": \"demo\"});\n}"},
+		{FormatOptions{Indent: "  ", Width: 80}, "{\n  configure([1, 2, 3], {\"name\": \"demo\"});\n}"},
+		{FormatOptions{Indent: "  ", Width: 30}, "{\n  configure(\n    [1, 2, 3],\n    {\"name\": \"demo\"},\n  );\n}"},
+		{FormatOptions{Indent: "  ", Width: 12}, "{\n  configure(\n    [\n      1,\n      2,\n      3,\n    ],\n    {\n      \"name\": \"demo\",\n    },\n  );\n}"},
+	}
+	for _, tt := range tests {
+		if got := Format(block, tt.opts); got != tt.expected {
+			t.Errorf("Format(%+v) wrong.\nwant=%q\ngot =%q", tt.opts, tt.expected, got)
+		}
+	}
+}
+
+func TestFormatStrings(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{`plain`, `"plain"`},
+		{"tab\tquote\"back\\slash\n", `"tab\tquote\"back\\slash\n"`},
+		{"cost: $5, ${name}", `"cost: $5, \${name}"`},
+		{"bell\a", `"bell\u0007"`},
+		{"héllo", `"héllo"`},
+	}
+	for _, tt := range tests {
+		if got := Format(str(tt.value), FormatOptions{}); got != tt.expected {
+			t.Errorf("Format(%q) = %s, want %s", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatNegativeLiterals(t *testing.T) {
+	tests := []struct {
+		exp      Expression
+		expected string
+	}{
+		{NewInt(-3), "-3"},
+		{NewPrefix("-", NewInt(-3)), "-(-3)"},
+		{NewIndex(NewInt(-3), NewInt(0)), "(-3)[0]"},
+		{NewCall(&MemberExpression{Object: &IntegerLiteral{Value: -3}, Property: NewIdent("foo")}), "(-3).foo()"},
+		{&PostfixExpression{Target: &FloatLiteral{Value: -1.5}, Operator: "++"}, "(-1.5)++"},
+		{NewInfix(NewInt(1), "-", NewInt(-3)), "1 - -3"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.exp, FormatOptions{}); got != tt.expected {
+			t.Errorf("Format wrong. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestFormatFloatAndNull(t *testing.T) {
+	tests := []struct {
+		exp      Expression
+		expected string
+	}{
+		{&FloatLiteral{Value: 2.5}, "2.5"},
+		{&FloatLiteral{Value: 3}, "3.0"},
+		{&FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: "1.50"}, Value: 1.5}, "1.50"},
+		{&NullLiteral{}, "null"},
+		{&ArrayLiteral{Elements: []Expression{&NullLiteral{}, &FloatLiteral{Value: 0.1}}}, "[null, 0.1]"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.exp, FormatOptions{}); got != tt.expected {
+			t.Errorf("Format wrong. want=%q, got=%q", tt.expected, got)
+		}
+	}
+	if !Equal(&FloatLiteral{Value: 1.5}, &FloatLiteral{Token: token.Token{Literal: "1.50"}, Value: 1.5}) {
+		t.Errorf("float literals with equal values should be Equal")
+	}
+}
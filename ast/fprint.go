@@ -0,0 +1,169 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"simple-interpreter/token"
+	"sort"
+	"strings"
+)
+
+// Fprint writes every field of the tree rooted at node to w, one per line
+// with its type, indented by depth and numbered like the output of
+// go/ast.Fprint. Unlike String, nothing is left out: tokens are shown with
+// their positions, and missing children as nil. A node reached a second
+// time is shown as a reference to the line where it was first printed.
+func Fprint(w io.Writer, node Node) error {
+	d := &dumper{w: w, ptrs: make(map[interface{}]int), atStart: true}
+	d.value(reflect.ValueOf(&node).Elem())
+	d.printf("\n")
+	return d.err
+}
+
+type dumper struct {
+	w       io.Writer
+	err     error
+	indent  int
+	line    int
+	atStart bool
+	ptrs    map[interface{}]int
+}
+
+// printf writes the formatted text, starting each new line with its number
+// and indentation.
+func (d *dumper) printf(format string, args ...interface{}) {
+	text := fmt.Sprintf(format, args...)
+	for text != "" && d.err == nil {
+		if d.atStart {
+			_, d.err = fmt.Fprintf(d.w, "%6d  %s", d.line, strings.Repeat(".  ", d.indent))
+			d.atStart = false
+		}
+		chunk := text
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			chunk = text[:i+1]
+			d.line++
+			d.atStart = true
+		}
+		text = text[len(chunk):]
+		if d.err == nil {
+			_, d.err = io.WriteString(d.w, chunk)
+		}
+	}
+}
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+func (d *dumper) value(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			d.printf("nil")
+			return
+		}
+		d.value(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			d.printf("nil")
+			return
+		}
+		if line, ok := d.ptrs[v.Interface()]; ok {
+			d.printf("(obj @ %d)", line)
+			return
+		}
+		d.ptrs[v.Interface()] = d.line
+		d.printf("*")
+		d.value(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			d.printf("nil")
+			return
+		}
+		d.printf("%s (len = %d) {", v.Type(), v.Len())
+		if v.Len() > 0 {
+			d.indent++
+			d.printf("\n")
+			for i := 0; i < v.Len(); i++ {
+				d.printf("%d: ", i)
+				d.value(v.Index(i))
+				d.printf("\n")
+			}
+			d.indent--
+		}
+		d.printf("}")
+	case reflect.Map:
+		d.printf("%s (len = %d) {", v.Type(), v.Len())
+		if v.Len() > 0 {
+			d.indent++
+			d.printf("\n")
+			for _, key := range sortedMapKeys(v) {
+				d.value(key)
+				d.printf(": ")
+				d.value(v.MapIndex(key))
+				d.printf("\n")
+			}
+			d.indent--
+		}
+		d.printf("}")
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			d.token(v.Interface().(token.Token))
+			return
+		}
+		d.printf("%s {", v.Type())
+		t := v.Type()
+		first := true
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if first {
+				d.indent++
+				d.printf("\n")
+				first = false
+			}
+			d.printf("%s: ", t.Field(i).Name)
+			d.value(v.Field(i))
+			d.printf("\n")
+		}
+		if !first {
+			d.indent--
+		}
+		d.printf("}")
+	case reflect.String:
+		d.printf("%q", v.String())
+	default:
+		d.printf("%v", v.Interface())
+	}
+}
+
+// token prints a token on one line, with its span if it has one.
+func (d *dumper) token(tok token.Token) {
+	d.printf("token.Token {Type: %q, Literal: %q", string(tok.Type), tok.Literal)
+	if pos := tok.Pos(); pos.IsValid() {
+		d.printf(", Pos: ")
+		if pos.File != "" {
+			d.printf("%s:", pos.File)
+		}
+		d.printf("%d:%d-%d:%d", pos.Line, pos.Column, tok.EndLine, tok.EndColumn)
+	}
+	d.printf("}")
+}
+
+// sortedMapKeys orders the keys of a hash literal by their source position,
+// then by their text, so the output does not depend on map iteration.
+func sortedMapKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, aok := keys[i].Interface().(Node)
+		b, bok := keys[j].Interface().(Node)
+		if !aok || !bok {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		}
+		if a.Pos().Offset != b.Pos().Offset {
+			return a.Pos().Offset < b.Pos().Offset
+		}
+		return a.String() < b.String()
+	})
+	return keys
+}
@@ -0,0 +1,51 @@
+package ast
+
+import (
+	"bytes"
+	"simple-interpreter/token"
+	"testing"
+)
+
+func TestFprint(t *testing.T) {
+	x := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Line: 1, Column: 5, EndLine: 1, EndColumn: 6}, Value: "x"}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: x, Value: &HashLiteral{Pairs: map[Expression]Expression{str("k"): x}}},
+		&ReturnStatement{},
+	}}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		t.Fatal(err)
+	}
+	expected := `     0  *ast.Program {
+     1  .  Statements: []ast.Statement (len = 2) {
+     2  .  .  0: *ast.LetStatement {
+     3  .  .  .  Token: token.Token {Type: "", Literal: ""}
+     4  .  .  .  Name: *ast.Identifier {
+     5  .  .  .  .  Token: token.Token {Type: "IDENT", Literal: "x", Pos: 1:5-1:6}
+     6  .  .  .  .  Value: "x"
+     7  .  .  .  .  Type: nil
+     8  .  .  .  }
+     9  .  .  .  Value: *ast.HashLiteral {
+    10  .  .  .  .  Token: token.Token {Type: "", Literal: ""}
+    11  .  .  .  .  Pairs: map[ast.Expression]ast.Expression (len = 1) {
+    12  .  .  .  .  .  *ast.StringLiteral {
+    13  .  .  .  .  .  .  Token: token.Token {Type: "", Literal: ""}
+    14  .  .  .  .  .  .  Value: "k"
+    15  .  .  .  .  .  }: (obj @ 4)
+    16  .  .  .  .  }
+    17  .  .  .  .  Keys: nil
+    18  .  .  .  .  Rbrace: token.Token {Type: "", Literal: ""}
+    19  .  .  .  }
+    20  .  .  }
+    21  .  .  1: *ast.ReturnStatement {
+    22  .  .  .  Token: token.Token {Type: "", Literal: ""}
+    23  .  .  .  ReturnValue: nil
+    24  .  .  }
+    25  .  }
+    26  }
+`
+	if out.String() != expected {
+		t.Errorf("Fprint wrong.\nwant:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
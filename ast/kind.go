@@ -0,0 +1,130 @@
+package ast
+
+// NodeKind identifies the type of a node, so tools can switch on it without
+// a type switch and serialized trees have a stable tag for each node. Kinds
+// are only ever added at the end, keeping the numbers of existing ones.
+type NodeKind int
+
+const (
+	KindInvalid NodeKind = iota
+	KindProgram
+	KindIdentifier
+	KindTypeAnnotation
+	KindLetStatement
+	KindLetGroup
+	KindDestructuringLet
+	KindReturnStatement
+	KindExpressionStatement
+	KindIntegerLiteral
+	KindFloatLiteral
+	KindBadExpression
+	KindBadStatement
+	KindNullLiteral
+	KindStringLiteral
+	KindCharLiteral
+	KindPrefixExpression
+	KindInfixExpression
+	KindBoolean
+	KindIfExpression
+	KindForStatement
+	KindWhileStatement
+	KindForInStatement
+	KindBreakStatement
+	KindContinueStatement
+	KindAssignExpression
+	KindMemberExpression
+	KindPostfixExpression
+	KindComparisonChain
+	KindRangeExpression
+	KindBlockStatement
+	KindFunctionLiteral
+	KindCallExpression
+	KindArrayLiteral
+	KindIndexExpression
+	KindHashLiteral
+	KindInterpolatedString
+)
+
+var kindNames = [...]string{
+	KindInvalid:             "Invalid",
+	KindProgram:             "Program",
+	KindIdentifier:          "Identifier",
+	KindTypeAnnotation:      "TypeAnnotation",
+	KindLetStatement:        "LetStatement",
+	KindLetGroup:            "LetGroup",
+	KindDestructuringLet:    "DestructuringLet",
+	KindReturnStatement:     "ReturnStatement",
+	KindExpressionStatement: "ExpressionStatement",
+	KindIntegerLiteral:      "IntegerLiteral",
+	KindFloatLiteral:        "FloatLiteral",
+	KindBadExpression:       "BadExpression",
+	KindBadStatement:        "BadStatement",
+	KindNullLiteral:         "NullLiteral",
+	KindStringLiteral:       "StringLiteral",
+	KindCharLiteral:         "CharLiteral",
+	KindPrefixExpression:    "PrefixExpression",
+	KindInfixExpression:     "InfixExpression",
+	KindBoolean:             "Boolean",
+	KindIfExpression:        "IfExpression",
+	KindForStatement:        "ForStatement",
+	KindWhileStatement:      "WhileStatement",
+	KindForInStatement:      "ForInStatement",
+	KindBreakStatement:      "BreakStatement",
+	KindContinueStatement:   "ContinueStatement",
+	KindAssignExpression:    "AssignExpression",
+	KindMemberExpression:    "MemberExpression",
+	KindPostfixExpression:   "PostfixExpression",
+	KindComparisonChain:     "ComparisonChain",
+	KindRangeExpression:     "RangeExpression",
+	KindBlockStatement:      "BlockStatement",
+	KindFunctionLiteral:     "FunctionLiteral",
+	KindCallExpression:      "CallExpression",
+	KindArrayLiteral:        "ArrayLiteral",
+	KindIndexExpression:     "IndexExpression",
+	KindHashLiteral:         "HashLiteral",
+	KindInterpolatedString:  "InterpolatedString",
+}
+
+func (k NodeKind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Invalid"
+	}
+	return kindNames[k]
+}
+
+func (p *Program) Kind() NodeKind              { return KindProgram }
+func (i *Identifier) Kind() NodeKind           { return KindIdentifier }
+func (ta *TypeAnnotation) Kind() NodeKind      { return KindTypeAnnotation }
+func (ls *LetStatement) Kind() NodeKind        { return KindLetStatement }
+func (lg *LetGroup) Kind() NodeKind            { return KindLetGroup }
+func (dl *DestructuringLet) Kind() NodeKind    { return KindDestructuringLet }
+func (rs *ReturnStatement) Kind() NodeKind     { return KindReturnStatement }
+func (es *ExpressionStatement) Kind() NodeKind { return KindExpressionStatement }
+func (il *IntegerLiteral) Kind() NodeKind      { return KindIntegerLiteral }
+func (fl *FloatLiteral) Kind() NodeKind        { return KindFloatLiteral }
+func (be *BadExpression) Kind() NodeKind       { return KindBadExpression }
+func (bs *BadStatement) Kind() NodeKind        { return KindBadStatement }
+func (nl *NullLiteral) Kind() NodeKind         { return KindNullLiteral }
+func (sl *StringLiteral) Kind() NodeKind       { return KindStringLiteral }
+func (cl *CharLiteral) Kind() NodeKind         { return KindCharLiteral }
+func (pe *PrefixExpression) Kind() NodeKind    { return KindPrefixExpression }
+func (ie *InfixExpression) Kind() NodeKind     { return KindInfixExpression }
+func (b *Boolean) Kind() NodeKind              { return KindBoolean }
+func (ifExp *IfExpression) Kind() NodeKind     { return KindIfExpression }
+func (fs *ForStatement) Kind() NodeKind        { return KindForStatement }
+func (ws *WhileStatement) Kind() NodeKind      { return KindWhileStatement }
+func (fs *ForInStatement) Kind() NodeKind      { return KindForInStatement }
+func (bs *BreakStatement) Kind() NodeKind      { return KindBreakStatement }
+func (cs *ContinueStatement) Kind() NodeKind   { return KindContinueStatement }
+func (ae *AssignExpression) Kind() NodeKind    { return KindAssignExpression }
+func (me *MemberExpression) Kind() NodeKind    { return KindMemberExpression }
+func (pe *PostfixExpression) Kind() NodeKind   { return KindPostfixExpression }
+func (cc *ComparisonChain) Kind() NodeKind     { return KindComparisonChain }
+func (re *RangeExpression) Kind() NodeKind     { return KindRangeExpression }
+func (bs *BlockStatement) Kind() NodeKind      { return KindBlockStatement }
+func (fl *FunctionLiteral) Kind() NodeKind     { return KindFunctionLiteral }
+func (ce *CallExpression) Kind() NodeKind      { return KindCallExpression }
+func (al *ArrayLiteral) Kind() NodeKind        { return KindArrayLiteral }
+func (ie *IndexExpression) Kind() NodeKind     { return KindIndexExpression }
+func (hl *HashLiteral) Kind() NodeKind         { return KindHashLiteral }
+func (is *InterpolatedString) Kind() NodeKind  { return KindInterpolatedString }
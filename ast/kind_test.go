@@ -0,0 +1,66 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeKinds(t *testing.T) {
+	nodes := []Node{
+		&Program{},
+		&Identifier{},
+		&TypeAnnotation{},
+		&LetStatement{},
+		&LetGroup{},
+		&DestructuringLet{},
+		&ReturnStatement{},
+		&ExpressionStatement{},
+		&IntegerLiteral{},
+		&FloatLiteral{},
+		&BadExpression{},
+		&BadStatement{},
+		&NullLiteral{},
+		&StringLiteral{},
+		&CharLiteral{},
+		&PrefixExpression{},
+		&InfixExpression{},
+		&Boolean{},
+		&IfExpression{},
+		&ForStatement{},
+		&WhileStatement{},
+		&ForInStatement{},
+		&BreakStatement{},
+		&ContinueStatement{},
+		&AssignExpression{},
+		&MemberExpression{},
+		&PostfixExpression{},
+		&ComparisonChain{},
+		&RangeExpression{},
+		&BlockStatement{},
+		&FunctionLiteral{},
+		&CallExpression{},
+		&ArrayLiteral{},
+		&IndexExpression{},
+		&HashLiteral{},
+		&InterpolatedString{},
+	}
+
+	seen := make(map[NodeKind]bool)
+	for _, node := range nodes {
+		kind := node.Kind()
+		if name := reflect.TypeOf(node).Elem().Name(); kind.String() != name {
+			t.Errorf("%T.Kind() = %s, want %s", node, kind, name)
+		}
+		if kind == KindInvalid || seen[kind] {
+			t.Errorf("%T has invalid or repeated kind %d", node, kind)
+		}
+		seen[kind] = true
+	}
+
+	if KindProgram != 1 || KindHashLiteral != 35 {
+		t.Errorf("kind numbers changed: Program=%d, HashLiteral=%d", KindProgram, KindHashLiteral)
+	}
+	if got := NodeKind(-1).String(); got != "Invalid" {
+		t.Errorf("out of range kind String() = %q", got)
+	}
+}
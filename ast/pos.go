@@ -0,0 +1,224 @@
+package ast
+
+import "simple-interpreter/token"
+
+// Nodes built by a parser that hit errors can be missing children, and
+// nodes built by hand can lack closing tokens, so each End falls back to
+// the nearest part of the node that is present.
+
+func startOf(n Node, fallback token.Token) token.Position {
+	if n == nil {
+		return fallback.Pos()
+	}
+	return n.Pos()
+}
+
+func endOf(n Node, fallback token.Token) token.Position {
+	if n == nil {
+		return fallback.End()
+	}
+	return n.End()
+}
+
+// closingEnd is the end of a closing delimiter the parser recorded, or of
+// fallback if it did not.
+func closingEnd(closing token.Token, fallback token.Position) token.Position {
+	if closing.Type == "" {
+		return fallback
+	}
+	return closing.End()
+}
+
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
+
+func (i *Identifier) Pos() token.Position      { return i.Token.Pos() }
+func (i *Identifier) End() token.Position      { return i.Token.End() }
+func (ta *TypeAnnotation) Pos() token.Position { return ta.Token.Pos() }
+func (ta *TypeAnnotation) End() token.Position { return ta.Token.End() }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos() }
+func (il *IntegerLiteral) End() token.Position { return il.Token.End() }
+func (fl *FloatLiteral) Pos() token.Position   { return fl.Token.Pos() }
+func (fl *FloatLiteral) End() token.Position   { return fl.Token.End() }
+func (nl *NullLiteral) Pos() token.Position    { return nl.Token.Pos() }
+func (nl *NullLiteral) End() token.Position    { return nl.Token.End() }
+func (be *BadExpression) Pos() token.Position  { return be.From.Pos() }
+func (be *BadExpression) End() token.Position  { return be.To.End() }
+func (bs *BadStatement) Pos() token.Position   { return bs.From.Pos() }
+func (bs *BadStatement) End() token.Position   { return bs.To.End() }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos() }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End() }
+func (cl *CharLiteral) Pos() token.Position    { return cl.Token.Pos() }
+func (cl *CharLiteral) End() token.Position    { return cl.Token.End() }
+func (b *Boolean) Pos() token.Position         { return b.Token.Pos() }
+func (b *Boolean) End() token.Position         { return b.Token.End() }
+
+func (is *InterpolatedString) Pos() token.Position { return is.Token.Pos() }
+func (is *InterpolatedString) End() token.Position {
+	fallback := is.Token.End()
+	if len(is.Parts) > 0 {
+		fallback = endOf(is.Parts[len(is.Parts)-1], is.Token)
+	}
+	return closingEnd(is.Close, fallback)
+}
+
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos() }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value == nil && ls.Name != nil {
+		return ls.Name.End()
+	}
+	return endOf(ls.Value, ls.Token)
+}
+
+func (lg *LetGroup) Pos() token.Position { return lg.Token.Pos() }
+func (lg *LetGroup) End() token.Position {
+	if len(lg.Bindings) == 0 {
+		return lg.Token.End()
+	}
+	return lg.Bindings[len(lg.Bindings)-1].End()
+}
+
+func (dl *DestructuringLet) Pos() token.Position { return dl.Token.Pos() }
+func (dl *DestructuringLet) End() token.Position { return endOf(dl.Value, dl.Pattern) }
+
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos() }
+func (rs *ReturnStatement) End() token.Position { return endOf(rs.ReturnValue, rs.Token) }
+
+func (es *ExpressionStatement) Pos() token.Position { return startOf(es.Expression, es.Token) }
+func (es *ExpressionStatement) End() token.Position { return endOf(es.Expression, es.Token) }
+
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos() }
+func (fs *ForStatement) End() token.Position {
+	if fs.Body == nil {
+		return fs.Token.End()
+	}
+	return fs.Body.End()
+}
+
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos() }
+func (ws *WhileStatement) End() token.Position {
+	if ws.Body == nil {
+		return endOf(ws.Condition, ws.Token)
+	}
+	return ws.Body.End()
+}
+
+func (fs *ForInStatement) Pos() token.Position { return fs.Token.Pos() }
+func (fs *ForInStatement) End() token.Position {
+	if fs.Body == nil {
+		return endOf(fs.Iterable, fs.Token)
+	}
+	return fs.Body.End()
+}
+
+func (bs *BreakStatement) Pos() token.Position    { return bs.Token.Pos() }
+func (bs *BreakStatement) End() token.Position    { return bs.Token.End() }
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos() }
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End() }
+
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos() }
+func (bs *BlockStatement) End() token.Position {
+	fallback := bs.Token.End()
+	if len(bs.Statements) > 0 {
+		fallback = bs.Statements[len(bs.Statements)-1].End()
+	}
+	return closingEnd(bs.Rbrace, fallback)
+}
+
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos() }
+func (pe *PrefixExpression) End() token.Position { return endOf(pe.Right, pe.Token) }
+
+func (ie *InfixExpression) Pos() token.Position { return startOf(ie.Left, ie.Token) }
+func (ie *InfixExpression) End() token.Position { return endOf(ie.Right, ie.Token) }
+
+func (ifExp *IfExpression) Pos() token.Position { return ifExp.Token.Pos() }
+func (ifExp *IfExpression) End() token.Position {
+	switch {
+	case ifExp.Alternative != nil:
+		return ifExp.Alternative.End()
+	case ifExp.Consequence != nil:
+		return ifExp.Consequence.End()
+	default:
+		return endOf(ifExp.Condition, ifExp.Token)
+	}
+}
+
+func (ae *AssignExpression) Pos() token.Position {
+	if ae.Name == nil {
+		return ae.Token.Pos()
+	}
+	return ae.Name.Pos()
+}
+func (ae *AssignExpression) End() token.Position { return endOf(ae.Value, ae.Token) }
+
+func (me *MemberExpression) Pos() token.Position { return startOf(me.Object, me.Token) }
+func (me *MemberExpression) End() token.Position {
+	if me.Property == nil {
+		return me.Token.End()
+	}
+	return me.Property.End()
+}
+
+func (pe *PostfixExpression) Pos() token.Position { return startOf(pe.Target, pe.Token) }
+func (pe *PostfixExpression) End() token.Position { return pe.Token.End() }
+
+func (cc *ComparisonChain) Pos() token.Position {
+	if len(cc.Operands) == 0 {
+		return cc.Token.Pos()
+	}
+	return startOf(cc.Operands[0], cc.Token)
+}
+func (cc *ComparisonChain) End() token.Position {
+	if len(cc.Operands) == 0 {
+		return cc.Token.End()
+	}
+	return endOf(cc.Operands[len(cc.Operands)-1], cc.Token)
+}
+
+func (re *RangeExpression) Pos() token.Position { return startOf(re.From, re.Token) }
+func (re *RangeExpression) End() token.Position { return endOf(re.To, re.Token) }
+
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos() }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body == nil {
+		return fl.Token.End()
+	}
+	return fl.Body.End()
+}
+
+func (ce *CallExpression) Pos() token.Position { return startOf(ce.Function, ce.Token) }
+func (ce *CallExpression) End() token.Position {
+	fallback := ce.Token.End()
+	if len(ce.Arguments) > 0 {
+		fallback = endOf(ce.Arguments[len(ce.Arguments)-1], ce.Token)
+	}
+	return closingEnd(ce.Rparen, fallback)
+}
+
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos() }
+func (al *ArrayLiteral) End() token.Position {
+	fallback := al.Token.End()
+	if len(al.Elements) > 0 {
+		fallback = endOf(al.Elements[len(al.Elements)-1], al.Token)
+	}
+	return closingEnd(al.Rbracket, fallback)
+}
+
+func (ie *IndexExpression) Pos() token.Position { return startOf(ie.Left, ie.Token) }
+func (ie *IndexExpression) End() token.Position {
+	return closingEnd(ie.Rbracket, endOf(ie.Index, ie.Token))
+}
+
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos() }
+func (hl *HashLiteral) End() token.Position { return closingEnd(hl.Rbrace, hl.Token.End()) }
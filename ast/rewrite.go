@@ -0,0 +1,310 @@
+package ast
+
+import "fmt"
+
+// Rewrite rebuilds the tree rooted at node from the bottom up. The children
+// of each node are rewritten first; f is then called with a copy of the node
+// holding the new children, and its result takes the node's place. The
+// original tree is not modified, though f may return parts of it.
+//
+// f must return a node that fits the place of the one it was given, such as
+// an Expression for an operand or an *Identifier for a parameter, and
+// Rewrite panics if it does not. Returning nil for a statement removes it
+// from its program or block.
+func Rewrite(node Node, f func(Node) Node) Node {
+	r := rewriter(f)
+	return r.node(node)
+}
+
+type rewriter func(Node) Node
+
+func (r rewriter) node(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		c := *n
+		c.Statements = r.statements(n.Statements)
+		return r(&c)
+	case *LetStatement:
+		c := *n
+		c.Name = r.identifier(n.Name)
+		c.Value = r.expression(n.Value)
+		return r(&c)
+	case *LetGroup:
+		c := *n
+		c.Bindings = make([]*LetStatement, 0, len(n.Bindings))
+		for _, binding := range n.Bindings {
+			if rewritten, ok := r.node(binding).(*LetStatement); ok {
+				c.Bindings = append(c.Bindings, rewritten)
+			} else if rewritten != nil {
+				panic(fmt.Sprintf("ast.Rewrite: %T cannot replace a let binding", rewritten))
+			}
+		}
+		return r(&c)
+	case *DestructuringLet:
+		c := *n
+		c.Names = r.identifiers(n.Names)
+		c.Value = r.expression(n.Value)
+		return r(&c)
+	case *ReturnStatement:
+		c := *n
+		c.ReturnValue = r.expression(n.ReturnValue)
+		return r(&c)
+	case *ExpressionStatement:
+		c := *n
+		c.Expression = r.expression(n.Expression)
+		return r(&c)
+	case *ForStatement:
+		c := *n
+		if n.Init != nil {
+			c.Init = r.statement(n.Init)
+		}
+		c.Condition = r.expression(n.Condition)
+		c.Post = r.expression(n.Post)
+		c.Body = r.block(n.Body)
+		return r(&c)
+	case *WhileStatement:
+		c := *n
+		c.Condition = r.expression(n.Condition)
+		c.Body = r.block(n.Body)
+		return r(&c)
+	case *ForInStatement:
+		c := *n
+		c.Key = r.identifier(n.Key)
+		c.Value = r.identifier(n.Value)
+		c.Iterable = r.expression(n.Iterable)
+		c.Body = r.block(n.Body)
+		return r(&c)
+	case *BreakStatement:
+		c := *n
+		return r(&c)
+	case *ContinueStatement:
+		c := *n
+		return r(&c)
+	case *BlockStatement:
+		c := *n
+		c.Statements = r.statements(n.Statements)
+		return r(&c)
+
+	case *Identifier:
+		c := *n
+		c.Type = r.typeAnnotation(n.Type)
+		return r(&c)
+	case *TypeAnnotation:
+		c := *n
+		return r(&c)
+	case *IntegerLiteral:
+		c := *n
+		return r(&c)
+	case *FloatLiteral:
+		c := *n
+		return r(&c)
+	case *NullLiteral:
+		c := *n
+		return r(&c)
+	case *BadExpression:
+		c := *n
+		return r(&c)
+	case *BadStatement:
+		c := *n
+		return r(&c)
+	case *StringLiteral:
+		c := *n
+		return r(&c)
+	case *CharLiteral:
+		c := *n
+		return r(&c)
+	case *Boolean:
+		c := *n
+		return r(&c)
+	case *PrefixExpression:
+		c := *n
+		c.Right = r.expression(n.Right)
+		return r(&c)
+	case *InfixExpression:
+		c := *n
+		c.Left = r.expression(n.Left)
+		c.Right = r.expression(n.Right)
+		return r(&c)
+	case *IfExpression:
+		c := *n
+		c.Condition = r.expression(n.Condition)
+		c.Consequence = r.block(n.Consequence)
+		c.Alternative = r.block(n.Alternative)
+		return r(&c)
+	case *AssignExpression:
+		c := *n
+		c.Name = r.identifier(n.Name)
+		c.Value = r.expression(n.Value)
+		return r(&c)
+	case *MemberExpression:
+		c := *n
+		c.Object = r.expression(n.Object)
+		c.Property = r.identifier(n.Property)
+		return r(&c)
+	case *PostfixExpression:
+		c := *n
+		c.Target = r.expression(n.Target)
+		return r(&c)
+	case *ComparisonChain:
+		c := *n
+		c.Operands = r.expressions(n.Operands)
+		return r(&c)
+	case *RangeExpression:
+		c := *n
+		c.From = r.expression(n.From)
+		c.To = r.expression(n.To)
+		return r(&c)
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = r.identifiers(n.Parameters)
+		c.Rest = r.identifier(n.Rest)
+		c.ReturnType = r.typeAnnotation(n.ReturnType)
+		c.Body = r.block(n.Body)
+		return r(&c)
+	case *CallExpression:
+		c := *n
+		c.Function = r.expression(n.Function)
+		c.Arguments = r.expressions(n.Arguments)
+		return r(&c)
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = r.expressions(n.Elements)
+		return r(&c)
+	case *InterpolatedString:
+		c := *n
+		c.Parts = r.expressions(n.Parts)
+		return r(&c)
+	case *IndexExpression:
+		c := *n
+		c.Left = r.expression(n.Left)
+		c.Index = r.expression(n.Index)
+		return r(&c)
+	case *HashLiteral:
+		c := *n
+		c.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		c.Keys = make([]Expression, 0, len(n.Pairs))
+		for _, key := range n.OrderedKeys() {
+			k := r.expression(key)
+			c.Pairs[k] = r.expression(n.Pairs[key])
+			c.Keys = append(c.Keys, k)
+		}
+		return r(&c)
+	default:
+		return r(node)
+	}
+}
+
+func (r rewriter) statement(stmt Statement) Statement {
+	rewritten := r.node(stmt)
+	if rewritten == nil {
+		return nil
+	}
+	s, ok := rewritten.(Statement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace a statement", rewritten))
+	}
+	return s
+}
+
+func (r rewriter) statements(stmts []Statement) []Statement {
+	result := make([]Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if s := r.statement(stmt); s != nil {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (r rewriter) expression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	rewritten := r.node(exp)
+	if rewritten == nil {
+		return nil
+	}
+	e, ok := rewritten.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace an expression", rewritten))
+	}
+	return e
+}
+
+func (r rewriter) expressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	result := make([]Expression, len(exps))
+	for i, exp := range exps {
+		result[i] = r.expression(exp)
+	}
+	return result
+}
+
+func (r rewriter) identifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	rewritten := r.node(ident)
+	if rewritten == nil {
+		return nil
+	}
+	id, ok := rewritten.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace an identifier", rewritten))
+	}
+	return id
+}
+
+func (r rewriter) identifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	result := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		result[i] = r.identifier(ident)
+	}
+	return result
+}
+
+func (r rewriter) typeAnnotation(typ *TypeAnnotation) *TypeAnnotation {
+	if typ == nil {
+		return nil
+	}
+	rewritten := r.node(typ)
+	if rewritten == nil {
+		return nil
+	}
+	t, ok := rewritten.(*TypeAnnotation)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace a type annotation", rewritten))
+	}
+	return t
+}
+
+func (r rewriter) block(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	rewritten := r.node(block)
+	if rewritten == nil {
+		return nil
+	}
+	b, ok := rewritten.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot replace a block", rewritten))
+	}
+	return b
+}
+
+// Clone returns a deep copy of the tree rooted at node, sharing no nodes or
+// slices with the original.
+func Clone(node Node) Node {
+	return Rewrite(node, func(n Node) Node {
+		if chain, ok := n.(*ComparisonChain); ok {
+			chain.Operators = append([]string(nil), chain.Operators...)
+		}
+		return n
+	})
+}
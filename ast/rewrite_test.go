@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func foldConstants(node Node) Node {
+	infix, ok := node.(*InfixExpression)
+	if !ok || infix.Operator != "+" {
+		return node
+	}
+	left, ok := infix.Left.(*IntegerLiteral)
+	if !ok {
+		return node
+	}
+	right, ok := infix.Right.(*IntegerLiteral)
+	if !ok {
+		return node
+	}
+	return integer(left.Value + right.Value)
+}
+
+func TestRewrite(t *testing.T) {
+	sum := &InfixExpression{
+		Left:     &InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+		Operator: "+",
+		Right:    integer(3),
+	}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("x"), Value: sum},
+		&ExpressionStatement{Expression: &CallExpression{
+			Function:  ident("f"),
+			Arguments: []Expression{&InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}},
+		}},
+	}}
+
+	rewritten := Rewrite(program, foldConstants)
+	if got, want := rewritten.String(), " x = 6;f((x + 1))"; got != want {
+		t.Errorf("rewritten program wrong. want=%q, got=%q", want, got)
+	}
+	if got, want := program.String(), " x = ((1 + 2) + 3);f((x + 1))"; got != want {
+		t.Errorf("original program was modified. want=%q, got=%q", want, got)
+	}
+}
+
+func TestRewriteOrder(t *testing.T) {
+	exp := &InfixExpression{
+		Left:     &PrefixExpression{Operator: "-", Right: ident("a")},
+		Operator: "*",
+		Right:    ident("b"),
+	}
+
+	var visited []string
+	Rewrite(exp, func(node Node) Node {
+		visited = append(visited, node.String())
+		return node
+	})
+
+	want := []string{"a", "(-a)", "b", "((-a) * b)"}
+	if strings.Join(visited, " ") != strings.Join(want, " ") {
+		t.Errorf("nodes rewritten in wrong order. want=%q, got=%q", want, visited)
+	}
+}
+
+func TestRewriteRemovesStatements(t *testing.T) {
+	block := &BlockStatement{Statements: []Statement{
+		&ExpressionStatement{Expression: ident("keep")},
+		&ReturnStatement{ReturnValue: ident("drop")},
+		&ExpressionStatement{Expression: ident("also")},
+	}}
+
+	rewritten := Rewrite(block, func(node Node) Node {
+		if _, ok := node.(*ReturnStatement); ok {
+			return nil
+		}
+		return node
+	})
+	if got, want := rewritten.String(), "keepalso"; got != want {
+		t.Errorf("rewritten block wrong. want=%q, got=%q", want, got)
+	}
+}
+
+func TestRewriteMismatchPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected a panic when an expression is replaced by a statement")
+		}
+	}()
+	exp := &PrefixExpression{Operator: "!", Right: ident("ok")}
+	Rewrite(exp, func(node Node) Node {
+		if id, ok := node.(*Identifier); ok {
+			return &ReturnStatement{ReturnValue: id}
+		}
+		return node
+	})
+}
+
+func TestClone(t *testing.T) {
+	chain := &ComparisonChain{Operands: []Expression{integer(0), ident("x"), integer(10)}, Operators: []string{"<", "<="}}
+	original := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{chain}}},
+	}}
+
+	clone := Clone(original).(*Program)
+	if !Equal(clone, original) {
+		t.Fatalf("clone differs from original. got=%q", clone.String())
+	}
+
+	call := clone.Statements[0].(*ExpressionStatement).Expression.(*CallExpression)
+	call.Function.(*Identifier).Value = "g"
+	clonedChain := call.Arguments[0].(*ComparisonChain)
+	clonedChain.Operators[0] = ">"
+	clonedChain.Operands[1] = ident("y")
+
+	if got, want := original.String(), "f(((0 < x) && (x <= 10)))"; got != want {
+		t.Errorf("changing the clone modified the original. want=%q, got=%q", want, got)
+	}
+}
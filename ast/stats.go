@@ -0,0 +1,50 @@
+package ast
+
+// Statistics summarizes the size and shape of a tree, as returned by Stats.
+type Statistics struct {
+	// Nodes counts the nodes of each kind, and Total all of them.
+	Nodes map[NodeKind]int
+	Total int
+	// MaxDepth is the number of nodes on the longest path down from the
+	// root, counting the root itself.
+	MaxDepth  int
+	Functions int
+	// Literals counts the integer, float, string, character, boolean, null,
+	// array and hash literals.
+	Literals int
+}
+
+// Stats counts the nodes in the tree rooted at node, as Walk visits them.
+func Stats(node Node) Statistics {
+	s := &statsVisitor{stats: Statistics{Nodes: make(map[NodeKind]int)}}
+	if !isNil(node) {
+		Walk(s, node)
+	}
+	return s.stats
+}
+
+type statsVisitor struct {
+	stats Statistics
+	depth int
+}
+
+func (s *statsVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		s.depth--
+		return nil
+	}
+	s.depth++
+	s.stats.MaxDepth = max(s.stats.MaxDepth, s.depth)
+	kind := node.Kind()
+	s.stats.Nodes[kind]++
+	s.stats.Total++
+	switch kind {
+	case KindFunctionLiteral:
+		s.stats.Functions++
+	case KindIntegerLiteral, KindFloatLiteral, KindStringLiteral, KindCharLiteral,
+		KindBoolean, KindNullLiteral, KindArrayLiteral, KindHashLiteral,
+		KindInterpolatedString:
+		s.stats.Literals++
+	}
+	return s
+}
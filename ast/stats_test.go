@@ -0,0 +1,37 @@
+package ast
+
+import "testing"
+
+func TestStats(t *testing.T) {
+	fn := &FunctionLiteral{
+		Parameters: []*Identifier{ident("x")},
+		Body: &BlockStatement{Statements: []Statement{
+			&ReturnStatement{ReturnValue: &InfixExpression{Left: ident("x"), Operator: "+", Right: integer(1)}},
+		}},
+	}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: fn},
+		&ExpressionStatement{Expression: &CallExpression{
+			Function:  ident("f"),
+			Arguments: []Expression{&ArrayLiteral{Elements: []Expression{str("a")}}},
+		}},
+	}}
+
+	stats := Stats(program)
+	if stats.Total != 15 {
+		t.Errorf("Total wrong. want=15, got=%d", stats.Total)
+	}
+	if stats.Nodes[KindIdentifier] != 4 || stats.Nodes[KindProgram] != 1 {
+		t.Errorf("Nodes wrong. got=%v", stats.Nodes)
+	}
+	if stats.MaxDepth != 7 {
+		t.Errorf("MaxDepth wrong. want=7, got=%d", stats.MaxDepth)
+	}
+	if stats.Functions != 1 || stats.Literals != 3 {
+		t.Errorf("Functions or Literals wrong. want=1, 3, got=%d, %d", stats.Functions, stats.Literals)
+	}
+
+	if empty := Stats(nil); empty.Total != 0 || empty.MaxDepth != 0 {
+		t.Errorf("Stats(nil) = %+v", empty)
+	}
+}
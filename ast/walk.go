@@ -0,0 +1,207 @@
+package ast
+
+import "sort"
+
+// A Visitor's Visit method is called by Walk for every node it enters. If it
+// returns a non-nil Visitor w, Walk visits the node's children with w and
+// then calls w.Visit(nil) on the way out.
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, visiting
+// children in source order. Missing parts of a node, such as the else of an
+// if without one, are skipped. Node types outside this package have no
+// children as far as Walk is concerned.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *LetGroup:
+		for _, binding := range n.Bindings {
+			Walk(v, binding)
+		}
+	case *DestructuringLet:
+		walkIdentifiers(v, n.Names)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *ForStatement:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		walkExpression(v, n.Condition)
+		walkExpression(v, n.Post)
+		walkBlock(v, n.Body)
+	case *WhileStatement:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Body)
+	case *ForInStatement:
+		walkIdentifier(v, n.Key)
+		walkIdentifier(v, n.Value)
+		walkExpression(v, n.Iterable)
+		walkBlock(v, n.Body)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *AssignExpression:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *MemberExpression:
+		walkExpression(v, n.Object)
+		walkIdentifier(v, n.Property)
+	case *PostfixExpression:
+		walkExpression(v, n.Target)
+	case *ComparisonChain:
+		walkExpressions(v, n.Operands)
+	case *RangeExpression:
+		walkExpression(v, n.From)
+		walkExpression(v, n.To)
+	case *Identifier:
+		walkType(v, n.Type)
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		walkIdentifier(v, n.Rest)
+		walkType(v, n.ReturnType)
+		walkBlock(v, n.Body)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *HashLiteral:
+		for _, key := range n.OrderedKeys() {
+			walkExpression(v, key)
+			walkExpression(v, n.Pairs[key])
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// The walk helpers skip nil children, including nil pointers, which would
+// otherwise reach Visit as non-nil Nodes.
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walkExpression(v, exp)
+	}
+}
+
+func walkIdentifier(v Visitor, ident *Identifier) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkIdentifiers(v Visitor, idents []*Identifier) {
+	for _, ident := range idents {
+		walkIdentifier(v, ident)
+	}
+}
+
+func walkType(v Visitor, typ *TypeAnnotation) {
+	if typ != nil {
+		Walk(v, typ)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks the tree rooted at node, calling f for every node on the way
+// in and f(nil) on the way out. Returning false from f skips the children of
+// the node.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// OrderedKeys returns the keys of the hash literal in source order, so that
+// walking or evaluating it does not depend on map iteration. Literals built
+// without Keys, or whose Keys do not match Pairs, fall back to ordering the
+// keys by position and then by text.
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if hl.keysMatch() {
+		return hl.Keys
+	}
+
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if isNil(a) || isNil(b) {
+			return !isNil(a) && isNil(b)
+		}
+		if a.Pos().Offset != b.Pos().Offset {
+			return a.Pos().Offset < b.Pos().Offset
+		}
+		return a.String() < b.String()
+	})
+	return keys
+}
+
+// keysMatch reports whether Keys lists each key of Pairs exactly once.
+func (hl *HashLiteral) keysMatch() bool {
+	if len(hl.Keys) != len(hl.Pairs) {
+		return false
+	}
+	seen := make(map[Expression]bool, len(hl.Keys))
+	for _, key := range hl.Keys {
+		if _, ok := hl.Pairs[key]; !ok || seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
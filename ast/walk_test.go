@@ -0,0 +1,107 @@
+package ast
+
+import (
+	"fmt"
+	"simple-interpreter/token"
+	"strings"
+	"testing"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(v int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(v)}, Value: v}
+}
+
+// let f = fn(x) { if (x < 1) { return x; } }; f(2);
+func walkProgram() *Program {
+	body := &BlockStatement{Statements: []Statement{
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition: &InfixExpression{Left: ident("x"), Operator: "<", Right: integer(1)},
+			Consequence: &BlockStatement{Statements: []Statement{
+				&ReturnStatement{ReturnValue: ident("x")},
+			}},
+		}},
+	}}
+	return &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: &FunctionLiteral{Parameters: []*Identifier{ident("x")}, Body: body}},
+		&ExpressionStatement{Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{integer(2)}}},
+	}}
+}
+
+func TestInspect(t *testing.T) {
+	var events []string
+	Inspect(walkProgram(), func(n Node) bool {
+		if n == nil {
+			events = append(events, ")")
+			return false
+		}
+		name := strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+		if id, ok := n.(*Identifier); ok {
+			name = id.Value
+		}
+		events = append(events, "("+name)
+		return true
+	})
+
+	expected := "(Program (LetStatement (f ) (FunctionLiteral (x ) (BlockStatement " +
+		"(ExpressionStatement (IfExpression (InfixExpression (x ) (IntegerLiteral ) ) " +
+		"(BlockStatement (ReturnStatement (x ) ) ) ) ) ) ) ) " +
+		"(ExpressionStatement (CallExpression (f ) (IntegerLiteral ) ) ) )"
+	if got := strings.Join(events, " "); got != expected {
+		t.Errorf("wrong walk order.\nwant=%s\ngot =%s", expected, got)
+	}
+}
+
+type countVisitor map[string]int
+
+func (c countVisitor) Visit(n Node) Visitor {
+	if n != nil {
+		c[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")]++
+	}
+	if _, ok := n.(*FunctionLiteral); ok {
+		return nil
+	}
+	return c
+}
+
+func TestWalkSkipsChildren(t *testing.T) {
+	counts := countVisitor{}
+	Walk(counts, walkProgram())
+
+	if counts["FunctionLiteral"] != 1 || counts["IfExpression"] != 0 || counts["ReturnStatement"] != 0 {
+		t.Errorf("function body should not be walked, got %v", counts)
+	}
+	if counts["Identifier"] != 2 || counts["IntegerLiteral"] != 1 {
+		t.Errorf("wrong counts outside the function: %v", counts)
+	}
+}
+
+func TestWalkHashLiteralAndMissingChildren(t *testing.T) {
+	hash := &HashLiteral{Pairs: map[Expression]Expression{
+		integer(2): ident("b"),
+		integer(1): ident("a"),
+	}}
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: hash},
+		&ReturnStatement{},
+		&ExpressionStatement{Expression: &IfExpression{Condition: ident("c"), Consequence: &BlockStatement{}}},
+		&ForStatement{Body: &BlockStatement{}},
+	}}
+
+	var visited []string
+	Inspect(program, func(n Node) bool {
+		if n != nil {
+			visited = append(visited, n.String())
+		}
+		return true
+	})
+	if got := strings.Join(visited[3:7], " "); got != "1 a 2 b" {
+		t.Errorf("hash pairs walked as %q, want keys in order each followed by its value", got)
+	}
+	if len(visited) != 14 {
+		t.Errorf("expected 14 nodes, got %d: %q", len(visited), visited)
+	}
+}
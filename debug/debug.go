@@ -0,0 +1,207 @@
+package debug
+
+import (
+	"errors"
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+)
+
+type StopReason int
+
+const (
+	StopStep StopReason = iota
+	StopBreakpoint
+	StopDone
+)
+
+type Stop struct {
+	Reason StopReason
+	Node   ast.Node
+	Result object.Object
+}
+
+type Frame struct {
+	Name string
+	Call *ast.CallExpression
+	Node ast.Node
+	Env  *object.Environment
+}
+
+type mode int
+
+const (
+	modeStep mode = iota
+	modeStepOver
+	modeContinue
+	modeAbort
+)
+
+type command struct {
+	mode  mode
+	depth int
+}
+
+var ErrFinished = errors.New("debug session has finished")
+
+var errAborted = errors.New("debug session aborted")
+
+type Session struct {
+	program *ast.Program
+	env     *object.Environment
+	eval    *evaluator.Evaluator
+
+	nodeBreakpoints map[ast.Node]bool
+	fnBreakpoints   map[string]bool
+
+	frames   []*Frame
+	started  bool
+	finished bool
+	current  command
+
+	resume  chan command
+	stopped chan Stop
+}
+
+// NewSession prepares to debug program in env. The session's evaluator has
+// only the builtins that need no capability; set Evaluator().Builtins before
+// the first step to allow more.
+func NewSession(program *ast.Program, env *object.Environment) *Session {
+	s := &Session{
+		program:         program,
+		env:             env,
+		eval:            evaluator.New(),
+		nodeBreakpoints: make(map[ast.Node]bool),
+		fnBreakpoints:   make(map[string]bool),
+		frames:          []*Frame{{Name: "<program>", Env: env}},
+		resume:          make(chan command),
+		stopped:         make(chan Stop),
+	}
+	s.eval.Hooks.OnEnterNode = s.enterNode
+	s.eval.Hooks.OnCall = s.enterCall
+	s.eval.Hooks.OnReturn = s.exitCall
+	return s
+}
+
+func (s *Session) Evaluator() *evaluator.Evaluator {
+	return s.eval
+}
+
+func (s *Session) SetBreakpoint(node ast.Node) {
+	s.nodeBreakpoints[node] = true
+}
+
+func (s *Session) ClearBreakpoint(node ast.Node) {
+	delete(s.nodeBreakpoints, node)
+}
+
+func (s *Session) SetFunctionBreakpoint(name string) {
+	s.fnBreakpoints[name] = true
+}
+
+func (s *Session) ClearFunctionBreakpoint(name string) {
+	delete(s.fnBreakpoints, name)
+}
+
+func (s *Session) Step() (Stop, error) {
+	return s.run(command{mode: modeStep})
+}
+
+func (s *Session) StepOver() (Stop, error) {
+	return s.run(command{mode: modeStepOver, depth: len(s.frames)})
+}
+
+func (s *Session) Continue() (Stop, error) {
+	return s.run(command{mode: modeContinue})
+}
+
+func (s *Session) Close() {
+	if s.started && !s.finished {
+		s.resume <- command{mode: modeAbort}
+		<-s.stopped
+		s.finished = true
+	}
+}
+
+func (s *Session) Frames() []Frame {
+	frames := make([]Frame, len(s.frames))
+	for i, f := range s.frames {
+		frames[len(s.frames)-1-i] = *f
+	}
+	return frames
+}
+
+func (s *Session) run(cmd command) (Stop, error) {
+	if s.finished {
+		return Stop{}, ErrFinished
+	}
+
+	if !s.started {
+		s.started = true
+		s.current = cmd
+		go s.evaluate()
+	} else {
+		s.resume <- cmd
+	}
+
+	stop := <-s.stopped
+	if stop.Reason == StopDone {
+		s.finished = true
+	}
+	return stop, nil
+}
+
+func (s *Session) evaluate() {
+	var result object.Object
+	defer func() {
+		if r := recover(); r != nil && r != errAborted {
+			panic(r)
+		}
+		s.stopped <- Stop{Reason: StopDone, Result: result}
+	}()
+
+	result = s.eval.Eval(s.program, s.env)
+}
+
+func (s *Session) enterNode(node ast.Node, env *object.Environment) {
+	top := s.frames[len(s.frames)-1]
+	top.Node = node
+	top.Env = env
+
+	if _, ok := node.(ast.Statement); !ok {
+		return
+	}
+
+	switch {
+	case s.nodeBreakpoints[node]:
+		s.pause(Stop{Reason: StopBreakpoint, Node: node})
+	case s.current.mode == modeStep:
+		s.pause(Stop{Reason: StopStep, Node: node})
+	case s.current.mode == modeStepOver && len(s.frames) <= s.current.depth:
+		s.pause(Stop{Reason: StopStep, Node: node})
+	}
+}
+
+func (s *Session) enterCall(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	name := "<anonymous>"
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+	s.frames = append(s.frames, &Frame{Name: name, Call: call, Node: call})
+
+	if s.fnBreakpoints[name] {
+		s.pause(Stop{Reason: StopBreakpoint, Node: call})
+	}
+}
+
+func (s *Session) exitCall(call *ast.CallExpression, fn object.Object, result object.Object) {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+func (s *Session) pause(stop Stop) {
+	s.stopped <- stop
+	s.current = <-s.resume
+	if s.current.mode == modeAbort {
+		panic(errAborted)
+	}
+}
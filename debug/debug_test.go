@@ -0,0 +1,122 @@
+package debug
+
+import (
+	"simple-interpreter/ast"
+	"simple-interpreter/lexer"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"testing"
+)
+
+const input = `
+let add = fn(a, b) {
+	let sum = a + b;
+	sum;
+};
+let x = add(1, 2);
+let y = x * 2;
+y;
+`
+
+func newTestSession(t *testing.T) (*Session, *ast.Program) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return NewSession(program, object.NewEnvironment()), program
+}
+
+func TestStepOver(t *testing.T) {
+	s, program := newTestSession(t)
+	defer s.Close()
+
+	for i, expected := range program.Statements {
+		stop, err := s.StepOver()
+		if err != nil {
+			t.Fatalf("StepOver() returned error: %s", err)
+		}
+		if stop.Reason != StopStep {
+			t.Fatalf("stops[%d] wrong reason. got=%d", i, stop.Reason)
+		}
+		if stop.Node != expected {
+			t.Fatalf("stops[%d] wrong node. want=%q, got=%q",
+				i, expected.String(), stop.Node.String())
+		}
+	}
+
+	stop, err := s.StepOver()
+	if err != nil {
+		t.Fatalf("StepOver() returned error: %s", err)
+	}
+	if stop.Reason != StopDone {
+		t.Fatalf("expected session to finish. got reason=%d", stop.Reason)
+	}
+	if stop.Result.Inspect() != "6" {
+		t.Errorf("wrong result. got=%s", stop.Result.Inspect())
+	}
+
+	if _, err := s.Step(); err != ErrFinished {
+		t.Errorf("expected ErrFinished after completion. got=%v", err)
+	}
+}
+
+func TestStepIntoFunction(t *testing.T) {
+	s, _ := newTestSession(t)
+	defer s.Close()
+
+	s.Step()
+	s.Step()
+	stop, _ := s.Step()
+
+	if stop.Node.String() != "let sum = (a + b);" {
+		t.Fatalf("did not step into function. got=%q", stop.Node.String())
+	}
+
+	frames := s.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("wrong number of frames. got=%d", len(frames))
+	}
+	if frames[0].Name != "add" || frames[1].Name != "<program>" {
+		t.Errorf("wrong frames. got=%q, %q", frames[0].Name, frames[1].Name)
+	}
+	if a, ok := frames[0].Env.Get("a"); !ok || a.Inspect() != "1" {
+		t.Errorf("frame environment does not hold argument a. got=%v", a)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	s, program := newTestSession(t)
+	defer s.Close()
+
+	s.SetFunctionBreakpoint("add")
+	s.SetBreakpoint(program.Statements[2])
+
+	stop, _ := s.Continue()
+	if stop.Reason != StopBreakpoint {
+		t.Fatalf("expected breakpoint stop. got reason=%d", stop.Reason)
+	}
+	if _, ok := stop.Node.(*ast.CallExpression); !ok {
+		t.Fatalf("expected stop at call expression. got=%T", stop.Node)
+	}
+
+	stop, _ = s.Continue()
+	if stop.Reason != StopBreakpoint || stop.Node != program.Statements[2] {
+		t.Fatalf("expected stop at statement breakpoint. got=%q", stop.Node)
+	}
+
+	stop, _ = s.Continue()
+	if stop.Reason != StopDone {
+		t.Fatalf("expected session to finish. got reason=%d", stop.Reason)
+	}
+}
+
+func TestCloseWhilePaused(t *testing.T) {
+	s, _ := newTestSession(t)
+	s.Step()
+	s.Close()
+
+	if _, err := s.Continue(); err != ErrFinished {
+		t.Errorf("expected ErrFinished after Close. got=%v", err)
+	}
+}
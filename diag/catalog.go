@@ -0,0 +1,168 @@
+package diag
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is used when a catalog has no messages for the requested
+// language.
+const DefaultLanguage = "en"
+
+// Data holds the values substituted into a message template. Templates refer
+// to them by name, as in "identifier not found: {name}".
+type Data map[string]interface{}
+
+// Template is the localized text for one diagnostic code. Fix is only
+// rendered when every placeholder it mentions is present in the data.
+type Template struct {
+	Message string
+	Fix     string
+}
+
+type Catalog struct {
+	mu    sync.RWMutex
+	langs map[string]map[Code]Template
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{langs: make(map[string]map[Code]Template)}
+}
+
+// Messages is the catalog used to render diagnostics. It ships with English
+// and Spanish; deployments can Register further languages.
+var Messages = NewCatalog()
+
+func init() {
+	Messages.Register("en", english)
+	Messages.Register("es", spanish)
+}
+
+// Register adds or replaces templates for lang.
+func (c *Catalog) Register(lang string, templates map[Code]Template) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, ok := c.langs[lang]
+	if !ok {
+		table = make(map[Code]Template, len(templates))
+		c.langs[lang] = table
+	}
+	for code, t := range templates {
+		table[code] = t
+	}
+}
+
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	langs := make([]string, 0, len(c.langs))
+	for lang := range c.langs {
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// lookup finds the template for code in lang, falling back from a regional
+// variant ("pt-BR") to its base language and then to DefaultLanguage.
+func (c *Catalog) lookup(lang string, code Code) (Template, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	candidates := []string{lang}
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		candidates = append(candidates, lang[:i])
+	}
+	candidates = append(candidates, DefaultLanguage)
+
+	for _, l := range candidates {
+		if t, ok := c.langs[l][code]; ok {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
+
+// New builds an error diagnostic rendered in DefaultLanguage.
+func (c *Catalog) New(code Code, data Data) Diagnostic {
+	d := Diagnostic{Code: code, Severity: Error, Data: data}
+	return c.Localize(d, DefaultLanguage)
+}
+
+// Localize re-renders d's message and fix in lang. Diagnostics whose code has
+// no template are returned unchanged.
+func (c *Catalog) Localize(d Diagnostic, lang string) Diagnostic {
+	t, ok := c.lookup(lang, d.Code)
+	if !ok {
+		return d
+	}
+	d.Message, _ = render(t.Message, d.Data)
+	d.Fix = ""
+	if fix, ok := render(t.Fix, d.Data); ok {
+		d.Fix = fix
+	}
+	return d
+}
+
+// LocalizeError localizes err if it is a Diagnostic or List and returns any
+// other error unchanged.
+func (c *Catalog) LocalizeError(err error, lang string) error {
+	switch err := err.(type) {
+	case Diagnostic:
+		return c.Localize(err, lang)
+	case List:
+		localized := make(List, len(err))
+		for i, d := range err {
+			localized[i] = c.Localize(d, lang)
+		}
+		return localized
+	default:
+		return err
+	}
+}
+
+func New(code Code, data Data) Diagnostic {
+	return Messages.New(code, data)
+}
+
+func Localize(d Diagnostic, lang string) Diagnostic {
+	return Messages.Localize(d, lang)
+}
+
+func LocalizeError(err error, lang string) error {
+	return Messages.LocalizeError(err, lang)
+}
+
+// render substitutes {name} placeholders in tmpl. It reports false if tmpl is
+// empty or mentions a name missing from data.
+func render(tmpl string, data Data) (string, bool) {
+	if tmpl == "" {
+		return "", false
+	}
+
+	var b strings.Builder
+	complete := true
+	for {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			break
+		}
+		b.WriteString(tmpl[:open])
+		name := tmpl[open+1 : open+end]
+		if v, ok := data[name]; ok {
+			fmt.Fprint(&b, v)
+		} else {
+			complete = false
+			b.WriteString(tmpl[open : open+end+1])
+		}
+		tmpl = tmpl[open+end+1:]
+	}
+	b.WriteString(tmpl)
+	return b.String(), complete
+}
@@ -0,0 +1,99 @@
+package diag
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+func TestNewRendersEnglish(t *testing.T) {
+	d := New(IdentifierNotFound, Data{"name": "cont", "suggestion": "count"})
+	if d.Message != "identifier not found: cont" {
+		t.Errorf("message = %q", d.Message)
+	}
+	if d.Fix != "did you mean `count`?" {
+		t.Errorf("fix = %q", d.Fix)
+	}
+
+	d = New(IdentifierNotFound, Data{"name": "cont"})
+	if d.Fix != "" {
+		t.Errorf("fix should be omitted without a suggestion, got %q", d.Fix)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	d := New(DivisionByZero, Data{"left": 1, "operator": "/", "right": 0})
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"es", "división entre cero: 1 / 0"},
+		{"es-MX", "división entre cero: 1 / 0"},
+		{"fr", "division by zero: 1 / 0"},
+		{"en", "division by zero: 1 / 0"},
+	}
+	for _, tt := range tests {
+		if got := Localize(d, tt.lang).Message; got != tt.expected {
+			t.Errorf("Localize(%s) = %q, want %q", tt.lang, got, tt.expected)
+		}
+	}
+
+	err := LocalizeError(List{d}, "es")
+	if err.Error() != "división entre cero: 1 / 0" {
+		t.Errorf("LocalizeError = %q", err)
+	}
+}
+
+func TestCatalogRegister(t *testing.T) {
+	c := NewCatalog()
+	c.Register("en", map[Code]Template{DivisionByZero: {Message: "div {left}/{right}"}})
+	c.Register("pt", map[Code]Template{DivisionByZero: {Message: "divisão por zero: {left} / {right}"}})
+
+	d := c.New(DivisionByZero, Data{"left": 4, "right": 0})
+	if d.Message != "div 4/0" {
+		t.Errorf("message = %q", d.Message)
+	}
+	if got := c.Localize(d, "pt-BR").Message; got != "divisão por zero: 4 / 0" {
+		t.Errorf("pt-BR message = %q", got)
+	}
+
+	unknown := Diagnostic{Code: "MKY0000", Message: "kept"}
+	if got := c.Localize(unknown, "pt"); got.Message != "kept" {
+		t.Errorf("diagnostic without template changed to %q", got.Message)
+	}
+}
+
+func TestRenderMissingData(t *testing.T) {
+	got, ok := render("got {a} and {b}", Data{"a": 1})
+	if ok || got != "got 1 and {b}" {
+		t.Errorf("render = %q, %t", got, ok)
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{\w+\}`)
+
+func placeholders(s string) []string {
+	names := placeholder.FindAllString(s, -1)
+	sort.Strings(names)
+	return names
+}
+
+func TestTranslationsComplete(t *testing.T) {
+	for lang, table := range map[string]map[Code]Template{"es": spanish} {
+		for code, en := range english {
+			tr, ok := table[code]
+			if !ok {
+				t.Errorf("%s: missing translation for %s", lang, code)
+				continue
+			}
+			if a, b := placeholders(en.Message), placeholders(tr.Message); !reflect.DeepEqual(a, b) {
+				t.Errorf("%s: %s message placeholders %v, want %v", lang, code, b, a)
+			}
+			if (en.Fix == "") != (tr.Fix == "") {
+				t.Errorf("%s: %s fix presence differs from English", lang, code)
+			}
+		}
+	}
+}
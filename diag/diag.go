@@ -0,0 +1,231 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Code identifies a class of diagnostic. Codes are stable across releases so
+// tools and documentation can refer to them.
+//
+//	MKY1xxx  lexical errors
+//	MKY2xxx  syntax errors
+//	MKY3xxx  name resolution errors
+//	MKY4xxx  runtime errors
+//	MKY9xxx  internal errors
+type Code string
+
+const (
+	IllegalCharacter    Code = "MKY1001"
+	InvalidEscape       Code = "MKY1002"
+	UnterminatedComment Code = "MKY1003"
+	InvalidCharLiteral  Code = "MKY1004"
+	InvalidNumber       Code = "MKY1005"
+	UnterminatedString  Code = "MKY1006"
+
+	UnexpectedToken    Code = "MKY2001"
+	NoPrefixParse      Code = "MKY2002"
+	InvalidInteger     Code = "MKY2003"
+	InvalidAssign      Code = "MKY2004"
+	RestNotLast        Code = "MKY2005"
+	DuplicateName      Code = "MKY2006"
+	TooDeeplyNested    Code = "MKY2007"
+	UnsupportedFeature Code = "MKY2008"
+
+	UseBeforeDefinition Code = "MKY3001"
+	UnusedBinding       Code = "MKY3002"
+
+	UnknownOperator        Code = "MKY4001"
+	TypeMismatch           Code = "MKY4002"
+	DivisionByZero         Code = "MKY4003"
+	IdentifierNotFound     Code = "MKY4004"
+	NotAFunction           Code = "MKY4005"
+	WrongArgumentCount     Code = "MKY4006"
+	MaxCallDepth           Code = "MKY4007"
+	IndexNotSupported      Code = "MKY4008"
+	UnusableHashKey        Code = "MKY4009"
+	InvalidArgument        Code = "MKY4010"
+	ArgumentType           Code = "MKY4011"
+	UnknownPrefixOperator  Code = "MKY4012"
+	HostError              Code = "MKY4013"
+	NegativeShift          Code = "MKY4014"
+	UnknownPostfixOperator Code = "MKY4015"
+	MemberNotSupported     Code = "MKY4016"
+	DestructureMismatch    Code = "MKY4017"
+	BadSyntax              Code = "MKY4018"
+	RangeTooLarge          Code = "MKY4019"
+
+	Internal Code = "MKY9001"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "error":
+		*s = Error
+	case "warning":
+		*s = Warning
+	case "info":
+		*s = Info
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Position is a location in source text. Lines and columns are 1-based; the
+// zero Position means the location is unknown. File is empty when the source
+// has no name.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Diagnostic struct {
+	Code     Code     `json:"code"`
+	Severity Severity `json:"severity"`
+	Range    Range    `json:"range"`
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix,omitempty"`
+	Data     Data     `json:"data,omitempty"`
+	// Excerpt is the source line the diagnostic starts on with a caret line
+	// under the range; see Excerpt.
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+func (d Diagnostic) Error() string {
+	return d.Message
+}
+
+// String renders d as a single compiler-style line, followed by the source
+// excerpt and the suggested fix when there are any.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if start := d.Range.Start; start.IsValid() {
+		if start.File != "" {
+			fmt.Fprintf(&b, "%s:", start.File)
+		}
+		fmt.Fprintf(&b, "%d:%d: ", start.Line, start.Column)
+	}
+	fmt.Fprintf(&b, "%s[%s]: %s", d.Severity, d.Code, d.Message)
+	for _, line := range strings.Split(d.Excerpt, "\n") {
+		if d.Excerpt != "" {
+			fmt.Fprintf(&b, "\n  %s", line)
+		}
+	}
+	if d.Fix != "" {
+		fmt.Fprintf(&b, "\n  help: %s", d.Fix)
+	}
+	return b.String()
+}
+
+// Excerpt returns the line of src that r starts on, followed by a line of
+// carets under the range. Ranges that are empty or run past the end of the
+// line get a single caret. It returns "" if r.Start is not a position in src.
+func Excerpt(src string, r Range) string {
+	start := r.Start
+	if !start.IsValid() || start.Offset < 0 || start.Offset > len(src) {
+		return ""
+	}
+	lineStart := strings.LastIndexByte(src[:start.Offset], '\n') + 1
+	lineEnd := len(src)
+	if i := strings.IndexByte(src[start.Offset:], '\n'); i >= 0 {
+		lineEnd = start.Offset + i
+	}
+	line := strings.TrimSuffix(src[lineStart:lineEnd], "\r")
+
+	// Keep tabs in the indent so the caret lines up however they are shown.
+	var b strings.Builder
+	b.WriteString(line)
+	b.WriteByte('\n')
+	for _, ch := range src[lineStart:start.Offset] {
+		if ch == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	width := 1
+	if end := r.End; end.Line == start.Line && end.Offset <= lineEnd && end.Column > start.Column {
+		width = end.Column - start.Column
+	}
+	b.WriteString(strings.Repeat("^", width))
+	return b.String()
+}
+
+// List is a set of diagnostics that can be returned as a single error.
+type List []Diagnostic
+
+func (l List) Error() string {
+	msgs := make([]string, len(l))
+	for i, d := range l {
+		msgs[i] = d.Message
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns l as an error, or nil if it holds no errors.
+func (l List) Err() error {
+	for _, d := range l {
+		if d.Severity == Error {
+			return l
+		}
+	}
+	return nil
+}
+
+func WriteText(w io.Writer, diags []Diagnostic) error {
+	for _, d := range diags {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes diags as a JSON array.
+func WriteJSON(w io.Writer, diags []Diagnostic) error {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diags)
+}
@@ -0,0 +1,100 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{
+		Code:     UnexpectedToken,
+		Severity: Error,
+		Range:    Range{Start: Position{Line: 3, Column: 7}},
+		Message:  "expected next token to be ), got ; instead",
+		Fix:      "insert `)`",
+	}
+	expected := "3:7: error[MKY2001]: expected next token to be ), got ; instead\n  help: insert `)`"
+	if d.String() != expected {
+		t.Errorf("String() wrong.\nwant=%q\ngot =%q", expected, d.String())
+	}
+
+	d.Range.Start.File = "lib/math.mk"
+	expected = "lib/math.mk:3:7: error[MKY2001]: expected next token to be ), got ; instead\n  help: insert `)`"
+	if d.String() != expected {
+		t.Errorf("String() wrong.\nwant=%q\ngot =%q", expected, d.String())
+	}
+
+	d.Excerpt = "let x = (1;\n          ^"
+	expected = "lib/math.mk:3:7: error[MKY2001]: expected next token to be ), got ; instead\n  let x = (1;\n            ^\n  help: insert `)`"
+	if d.String() != expected {
+		t.Errorf("String() wrong.\nwant=%q\ngot =%q", expected, d.String())
+	}
+
+	d.Range = Range{}
+	d.Fix = ""
+	d.Excerpt = ""
+	expected = "error[MKY2001]: expected next token to be ), got ; instead"
+	if d.String() != expected {
+		t.Errorf("String() wrong.\nwant=%q\ngot =%q", expected, d.String())
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	src := "let a = 1;\n\tlet bc = ;\r\nlet d"
+	tests := []struct {
+		start, end Position
+		expected   string
+	}{
+		{Position{Line: 1, Column: 5, Offset: 4}, Position{Line: 1, Column: 6, Offset: 5}, "let a = 1;\n    ^"},
+		{Position{Line: 2, Column: 6, Offset: 16}, Position{Line: 2, Column: 8, Offset: 18}, "\tlet bc = ;\n\t    ^^"},
+		{Position{Line: 2, Column: 11, Offset: 21}, Position{}, "\tlet bc = ;\n\t         ^"},
+		{Position{Line: 3, Column: 6, Offset: 29}, Position{Line: 3, Column: 6, Offset: 29}, "let d\n     ^"},
+		{Position{}, Position{}, ""},
+		{Position{Line: 9, Column: 1, Offset: 99}, Position{}, ""},
+	}
+
+	for _, tt := range tests {
+		got := Excerpt(src, Range{Start: tt.start, End: tt.end})
+		if got != tt.expected {
+			t.Errorf("Excerpt(%v) wrong.\nwant=%q\ngot =%q", tt.start, tt.expected, got)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	diags := []Diagnostic{
+		{Code: DivisionByZero, Severity: Error, Message: "division by zero: 1 / 0"},
+		{Code: IdentifierNotFound, Severity: Warning, Message: "x", Fix: "did you mean `y`?"},
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, diags); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Diagnostic
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, diags) {
+		t.Errorf("round trip mismatch: %+v", got)
+	}
+
+	buf.Reset()
+	WriteJSON(&buf, nil)
+	if buf.String() != "[]\n" {
+		t.Errorf("empty list encoded as %q", buf.String())
+	}
+}
+
+func TestListErr(t *testing.T) {
+	if err := (List{{Severity: Warning, Message: "w"}}).Err(); err != nil {
+		t.Errorf("warnings alone should not be an error, got %v", err)
+	}
+
+	l := List{{Severity: Error, Message: "a"}, {Severity: Warning, Message: "b"}}
+	if err := l.Err(); err == nil || err.Error() != "a\nb" {
+		t.Errorf("Err() = %v", err)
+	}
+}
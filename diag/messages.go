@@ -0,0 +1,181 @@
+package diag
+
+var english = map[Code]Template{
+	IllegalCharacter: {Message: "illegal character {char}"},
+	InvalidEscape: {
+		Message: "invalid escape sequence {escape} in string literal",
+		Fix:     "write `\\\\` for a literal backslash",
+	},
+	UnterminatedComment: {
+		Message: "block comment is not terminated",
+		Fix:     "close the comment with `*/`",
+	},
+	InvalidCharLiteral: {
+		Message: "invalid character literal {literal}",
+		Fix:     "a character literal holds exactly one character; use double quotes for strings",
+	},
+	InvalidNumber: {
+		Message: "malformed number literal {literal}",
+		Fix:     "underscores may only appear between digits",
+	},
+	UnterminatedString: {
+		Message: "string literal is not terminated",
+		Fix:     "close the string with `{quote}`",
+	},
+
+	UnexpectedToken: {
+		Message: "expected next token to be {expected}, got {got} instead",
+		Fix:     "insert `{insert}`",
+	},
+	NoPrefixParse:  {Message: "no prefix parse function for {token} found"},
+	InvalidInteger: {Message: "could not parse {literal} as integer"},
+	InvalidAssign: {
+		Message: "cannot assign to {target}",
+		Fix:     "only variables can be assigned; declare one with `let`",
+	},
+	RestNotLast: {
+		Message: "rest parameter {name} must be the last parameter",
+		Fix:     "move `...{name}` to the end of the parameter list",
+	},
+	DuplicateName: {
+		Message: "{name} is bound more than once",
+		Fix:     "rename one of the `{name}` bindings",
+	},
+	TooDeeplyNested: {
+		Message: "expression too deeply nested",
+		Fix:     "split it up with `let`; at most {limit} levels are allowed",
+	},
+	UnsupportedFeature: {
+		Message: "syntax feature {feature} is not supported",
+		Fix:     "remove it from parser.Options.Features",
+	},
+
+	UnknownOperator:        {Message: "unknown operator: {left} {operator} {right}"},
+	UnknownPrefixOperator:  {Message: "unknown operator: {operator}{right}"},
+	UnknownPostfixOperator: {Message: "unknown operator: {left}{operator}"},
+	MemberNotSupported: {
+		Message: "cannot read member {name} of {type}",
+		Fix:     "only hashes have members; call a function with {name}(...) instead",
+	},
+	UseBeforeDefinition: {
+		Message: "{name} is used before it is defined",
+		Fix:     "move `let {name}` above this use",
+	},
+	UnusedBinding: {
+		Message: "{name} is defined but never used",
+		Fix:     "remove it, or rename it to `_{name}` if that is intended",
+	},
+	DestructureMismatch: {Message: "cannot destructure {type} with {pattern} pattern"},
+	BadSyntax: {
+		Message: "cannot evaluate the code at {position}, which failed to parse",
+		Fix:     "fix the syntax errors reported by the parser first",
+	},
+	RangeTooLarge:  {Message: "range {from}{operator}{to} has more than {limit} elements"},
+	TypeMismatch:   {Message: "type mismatch: {left} {operator} {right}"},
+	DivisionByZero: {Message: "division by zero: {left} {operator} {right}"},
+	IdentifierNotFound: {
+		Message: "identifier not found: {name}",
+		Fix:     "did you mean `{suggestion}`?",
+	},
+	NotAFunction:       {Message: "not a function: {type}"},
+	WrongArgumentCount: {Message: "wrong number of arguments. got={got}, want={want}"},
+	MaxCallDepth:       {Message: "maximum call depth of {limit} exceeded"},
+	IndexNotSupported:  {Message: "index operator not supported: {type}"},
+	UnusableHashKey:    {Message: "unusable as hash key: {type}"},
+	InvalidArgument:    {Message: "argument to `{builtin}` not supported, got {got}"},
+	ArgumentType:       {Message: "argument to `{builtin}` must be {expected}, got {got}"},
+	HostError:          {Message: "{builtin}: {detail}"},
+	NegativeShift:      {Message: "negative shift count: {left} {operator} {right}"},
+
+	Internal: {Message: "internal {component} error: {detail}"},
+}
+
+var spanish = map[Code]Template{
+	IllegalCharacter: {Message: "carácter no válido {char}"},
+	InvalidEscape: {
+		Message: "secuencia de escape no válida {escape} en la cadena",
+		Fix:     "escribe `\\\\` para una barra invertida literal",
+	},
+	UnterminatedComment: {
+		Message: "el comentario de bloque no está cerrado",
+		Fix:     "cierra el comentario con `*/`",
+	},
+	InvalidCharLiteral: {
+		Message: "literal de carácter no válido {literal}",
+		Fix:     "un literal de carácter contiene exactamente un carácter; usa comillas dobles para cadenas",
+	},
+	InvalidNumber: {
+		Message: "literal numérico mal formado {literal}",
+		Fix:     "los guiones bajos solo pueden aparecer entre dígitos",
+	},
+	UnterminatedString: {
+		Message: "la cadena no está cerrada",
+		Fix:     "cierra la cadena con `{quote}`",
+	},
+
+	UnexpectedToken: {
+		Message: "se esperaba que el siguiente token fuera {expected}, pero se encontró {got}",
+		Fix:     "inserta `{insert}`",
+	},
+	NoPrefixParse:  {Message: "no se puede comenzar una expresión con {token}"},
+	InvalidInteger: {Message: "no se pudo interpretar {literal} como entero"},
+	InvalidAssign: {
+		Message: "no se puede asignar a {target}",
+		Fix:     "solo se puede asignar a variables; declara una con `let`",
+	},
+	RestNotLast: {
+		Message: "el parámetro de resto {name} debe ser el último",
+		Fix:     "mueve `...{name}` al final de la lista de parámetros",
+	},
+	DuplicateName: {
+		Message: "{name} se declara más de una vez",
+		Fix:     "cambia el nombre de uno de los `{name}`",
+	},
+	TooDeeplyNested: {
+		Message: "la expresión está anidada demasiado profundamente",
+		Fix:     "divídela con `let`; se permiten como máximo {limit} niveles",
+	},
+	UnsupportedFeature: {
+		Message: "la característica de sintaxis {feature} no está disponible",
+		Fix:     "quítala de parser.Options.Features",
+	},
+
+	UnknownOperator:        {Message: "operador desconocido: {left} {operator} {right}"},
+	UnknownPrefixOperator:  {Message: "operador desconocido: {operator}{right}"},
+	UnknownPostfixOperator: {Message: "operador desconocido: {left}{operator}"},
+	MemberNotSupported: {
+		Message: "no se puede leer el miembro {name} de {type}",
+		Fix:     "solo los hashes tienen miembros; llama a una función con {name}(...)",
+	},
+	UseBeforeDefinition: {
+		Message: "{name} se usa antes de definirse",
+		Fix:     "mueve `let {name}` antes de este uso",
+	},
+	UnusedBinding: {
+		Message: "{name} se define pero nunca se usa",
+		Fix:     "elimínalo, o renómbralo a `_{name}` si es intencionado",
+	},
+	DestructureMismatch: {Message: "no se puede desestructurar {type} con un patrón de {pattern}"},
+	BadSyntax: {
+		Message: "no se puede evaluar el código en {position}, que no se pudo analizar",
+		Fix:     "corrige primero los errores de sintaxis que informa el analizador",
+	},
+	RangeTooLarge:  {Message: "el rango {from}{operator}{to} tiene más de {limit} elementos"},
+	TypeMismatch:   {Message: "tipos incompatibles: {left} {operator} {right}"},
+	DivisionByZero: {Message: "división entre cero: {left} {operator} {right}"},
+	IdentifierNotFound: {
+		Message: "identificador no encontrado: {name}",
+		Fix:     "¿quisiste decir `{suggestion}`?",
+	},
+	NotAFunction:       {Message: "no es una función: {type}"},
+	WrongArgumentCount: {Message: "número de argumentos incorrecto. recibidos={got}, esperados={want}"},
+	MaxCallDepth:       {Message: "se superó la profundidad máxima de llamadas ({limit})"},
+	IndexNotSupported:  {Message: "el operador de índice no es compatible con {type}"},
+	UnusableHashKey:    {Message: "no se puede usar como clave de hash: {type}"},
+	InvalidArgument:    {Message: "argumento no compatible con `{builtin}`: {got}"},
+	ArgumentType:       {Message: "el argumento de `{builtin}` debe ser {expected}, se recibió {got}"},
+	HostError:          {Message: "{builtin}: {detail}"},
+	NegativeShift:      {Message: "desplazamiento negativo: {left} {operator} {right}"},
+
+	Internal: {Message: "error interno ({component}): {detail}"},
+}
@@ -2,14 +2,61 @@ package evaluator
 
 import (
 	"fmt"
+	"simple-interpreter/diag"
 	"simple-interpreter/object"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
 )
 
+type Capability int
+
+const CapNone Capability = 0
+
+const (
+	CapFS Capability = 1 << iota
+	CapNet
+	CapEnv
+	CapExec
+
+	CapAll = CapFS | CapNet | CapEnv | CapExec
+)
+
+var builtinCapabilities = map[string]Capability{}
+
+func Builtins(allowed Capability) map[string]*object.Builtin {
+	table := make(map[string]*object.Builtin, len(builtins))
+	for name, builtin := range builtins {
+		if builtinCapabilities[name]&^allowed == 0 {
+			table[name] = builtin
+		}
+	}
+	return table
+}
+
+func newHash(pairs map[string]object.Object) *object.Hash {
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(pairs))}
+	for k, v := range pairs {
+		key := &object.String{Value: k}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: v}
+	}
+	return hash
+}
+
+func hashGet(hash *object.Hash, key string) (object.Object, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+	return pair.Value, ok
+}
+
+func argumentTypeError(builtin string, expected object.ObjectType, got object.Object) *object.Error {
+	return newError(diag.ArgumentType, diag.Data{"builtin": builtin, "expected": expected, "got": got.Type()})
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
 			}
 
 			switch arg := args[0].(type) {
@@ -18,7 +65,7 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newError(diag.InvalidArgument, diag.Data{"builtin": "len", "got": args[0].Type()})
 			}
 		},
 	},
@@ -26,12 +73,10 @@ var builtins = map[string]*object.Builtin{
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s",
-					args[0].Type())
+				return newError(diag.ArgumentType, diag.Data{"builtin": "first", "expected": object.ARRAY_OBJ, "got": args[0].Type()})
 			}
 
 			arr := args[0].(*object.Array)
@@ -46,12 +91,10 @@ var builtins = map[string]*object.Builtin{
 	"last": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s",
-					args[0].Type())
+				return newError(diag.ArgumentType, diag.Data{"builtin": "last", "expected": object.ARRAY_OBJ, "got": args[0].Type()})
 			}
 
 			arr := args[0].(*object.Array)
@@ -67,12 +110,10 @@ var builtins = map[string]*object.Builtin{
 	"rest": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s",
-					args[0].Type())
+				return newError(diag.ArgumentType, diag.Data{"builtin": "rest", "expected": object.ARRAY_OBJ, "got": args[0].Type()})
 			}
 
 			arr := args[0].(*object.Array)
@@ -90,12 +131,10 @@ var builtins = map[string]*object.Builtin{
 	"push": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 2})
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s",
-					args[0].Type())
+				return newError(diag.ArgumentType, diag.Data{"builtin": "push", "expected": object.ARRAY_OBJ, "got": args[0].Type()})
 			}
 
 			arr := args[0].(*object.Array)
@@ -108,6 +147,41 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
+	// Characters are integer code points; chr and ord convert between them
+	// and one-character strings.
+	"chr": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+			}
+			code, ok := args[0].(*object.Integer)
+			if !ok {
+				return argumentTypeError("chr", object.INTEGER_OBJ, args[0])
+			}
+			if code.Value < 0 || code.Value > unicode.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+				return newError(diag.InvalidArgument, diag.Data{"builtin": "chr", "got": code.Value})
+			}
+			return &object.String{Value: string(rune(code.Value))}
+		},
+	},
+
+	"ord": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return argumentTypeError("ord", object.STRING_OBJ, args[0])
+			}
+			r, size := utf8.DecodeRuneInString(str.Value)
+			if size == 0 || size != len(str.Value) {
+				return newError(diag.InvalidArgument, diag.Data{"builtin": "ord", "got": strconv.Quote(str.Value)})
+			}
+			return &object.Integer{Value: int64(r)}
+		},
+	},
+
 	"puts": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
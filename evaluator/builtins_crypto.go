@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"simple-interpreter/diag"
+	"simple-interpreter/object"
+)
+
+func init() {
+	builtins["sha256"] = &object.Builtin{Fn: sha256Builtin}
+	builtins["md5"] = &object.Builtin{Fn: md5Builtin}
+	builtins["hmac"] = &object.Builtin{Fn: hmacBuiltin}
+	builtins["base64Encode"] = &object.Builtin{Fn: base64EncodeBuiltin}
+	builtins["base64Decode"] = &object.Builtin{Fn: base64DecodeBuiltin}
+	builtins["uuid"] = &object.Builtin{Fn: uuidBuiltin}
+}
+
+func stringArgs(builtin string, args []object.Object, n int) ([]string, *object.Error) {
+	if len(args) != n {
+		return nil, newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": n})
+	}
+	values := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(*object.String)
+		if !ok {
+			return nil, argumentTypeError(builtin, object.STRING_OBJ, arg)
+		}
+		values[i] = s.Value
+	}
+	return values, nil
+}
+
+// sha256 and md5 return the lowercase hex digest of a string.
+func sha256Builtin(args ...object.Object) object.Object {
+	values, errObj := stringArgs("sha256", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	sum := sha256.Sum256([]byte(values[0]))
+	return &object.String{Value: hex.EncodeToString(sum[:])}
+}
+
+func md5Builtin(args ...object.Object) object.Object {
+	values, errObj := stringArgs("md5", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	sum := md5.Sum([]byte(values[0]))
+	return &object.String{Value: hex.EncodeToString(sum[:])}
+}
+
+// hmac(key, message) returns the hex HMAC-SHA256 of message.
+func hmacBuiltin(args ...object.Object) object.Object {
+	values, errObj := stringArgs("hmac", args, 2)
+	if errObj != nil {
+		return errObj
+	}
+	mac := hmac.New(sha256.New, []byte(values[0]))
+	mac.Write([]byte(values[1]))
+	return &object.String{Value: hex.EncodeToString(mac.Sum(nil))}
+}
+
+func base64EncodeBuiltin(args ...object.Object) object.Object {
+	values, errObj := stringArgs("base64Encode", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	return &object.String{Value: base64.StdEncoding.EncodeToString([]byte(values[0]))}
+}
+
+func base64DecodeBuiltin(args ...object.Object) object.Object {
+	values, errObj := stringArgs("base64Decode", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+	data, err := base64.StdEncoding.DecodeString(values[0])
+	if err != nil {
+		return hostError("base64Decode", err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+// uuid returns a random (version 4) UUID.
+func uuidBuiltin(args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 0})
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return hostError("uuid", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return &object.String{Value: fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])}
+}
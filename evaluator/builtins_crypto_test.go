@@ -0,0 +1,64 @@
+package evaluator
+
+import (
+	"regexp"
+	"simple-interpreter/object"
+	"testing"
+)
+
+func TestCryptoBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`md5("abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`hmac("key", "The quick brown fox jumps over the lazy dog")`, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{`base64Encode("hello, monkey")`, "aGVsbG8sIG1vbmtleQ=="},
+		{`base64Decode(base64Encode("round trip"))`, "round trip"},
+	}
+	for _, tt := range tests {
+		str, ok := testEval(tt.input).(*object.String)
+		if !ok {
+			t.Errorf("%s: expected string, got %s", tt.input, testEval(tt.input).Inspect())
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s = %q, want %q", tt.input, str.Value, tt.expected)
+		}
+	}
+}
+
+func TestCryptoBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sha256(1)`, "argument to `sha256` must be STRING, got INTEGER"},
+		{`hmac("key")`, "wrong number of arguments. got=1, want=2"},
+		{`base64Decode("!!")`, "base64Decode: illegal base64 data at input byte 0"},
+		{`uuid(1)`, "wrong number of arguments. got=1, want=0"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+}
+
+func TestUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a := uuidBuiltin().(*object.String).Value
+	b := uuidBuiltin().(*object.String).Value
+	if !pattern.MatchString(a) {
+		t.Errorf("uuid %q is not a version 4 UUID", a)
+	}
+	if a == b {
+		t.Errorf("uuid returned %q twice", a)
+	}
+}
@@ -0,0 +1,182 @@
+package evaluator
+
+import (
+	"database/sql"
+	"fmt"
+	"simple-interpreter/diag"
+	"simple-interpreter/object"
+	"strconv"
+	"time"
+)
+
+const dbHandle = "db"
+
+// The db builtins stand in for a std/db module until the language has modules,
+// so open, query and exec are global and prefixed with db. They are backed by
+// database/sql with no driver bundled, since the interpreter takes no
+// third-party dependencies: the embedding program (or a --plugin) imports the
+// drivers it wants, SQLite included, and scripts select one by name:
+//
+//	let db = dbOpen("sqlite", "app.db");
+//	dbExec(db, "insert into users(name) values (?)", ["ada"]);
+//	dbQuery(db, "select id, name from users") => [{"id": 1, "name": "ada"}]
+//	close(db);
+func init() {
+	builtins["dbOpen"] = &object.Builtin{Fn: dbOpen}
+	builtins["dbQuery"] = &object.Builtin{Fn: dbQuery}
+	builtins["dbExec"] = &object.Builtin{Fn: dbExec}
+	// A DSN may name a local file or a server, so opening needs both.
+	builtinCapabilities["dbOpen"] = CapFS | CapNet
+}
+
+func dbOpen(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 2})
+	}
+	driver, ok := args[0].(*object.String)
+	if !ok {
+		return argumentTypeError("dbOpen", object.STRING_OBJ, args[0])
+	}
+	dsn, ok := args[1].(*object.String)
+	if !ok {
+		return argumentTypeError("dbOpen", object.STRING_OBJ, args[1])
+	}
+
+	db, err := sql.Open(driver.Value, dsn.Value)
+	if err != nil {
+		return hostError("dbOpen", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return hostError("dbOpen", err)
+	}
+	return &object.Handle{Kind: dbHandle, Value: db}
+}
+
+// statementArgs unpacks the (db, sql[, params]) arguments shared by dbQuery
+// and dbExec.
+func statementArgs(builtin string, args []object.Object) (*sql.DB, string, []interface{}, *object.Error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, "", nil, newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": "2 or 3"})
+	}
+	h, errObj := handleArg(builtin, args[0], dbHandle)
+	if errObj != nil {
+		return nil, "", nil, errObj
+	}
+	query, ok := args[1].(*object.String)
+	if !ok {
+		return nil, "", nil, argumentTypeError(builtin, object.STRING_OBJ, args[1])
+	}
+
+	var params []interface{}
+	if len(args) == 3 {
+		arr, ok := args[2].(*object.Array)
+		if !ok {
+			return nil, "", nil, argumentTypeError(builtin, object.ARRAY_OBJ, args[2])
+		}
+		for _, el := range arr.Elements {
+			switch el := el.(type) {
+			case *object.Integer:
+				params = append(params, el.Value)
+			case *object.String:
+				params = append(params, el.Value)
+			case *object.Boolean:
+				params = append(params, el.Value)
+			case *object.Null:
+				params = append(params, nil)
+			default:
+				return nil, "", nil, newError(diag.InvalidArgument, diag.Data{"builtin": builtin, "got": el.Type()})
+			}
+		}
+	}
+	return h.Value.(*sql.DB), query.Value, params, nil
+}
+
+func dbQuery(args ...object.Object) object.Object {
+	db, query, params, errObj := statementArgs("dbQuery", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return hostError("dbQuery", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return hostError("dbQuery", err)
+	}
+
+	result := &object.Array{Elements: []object.Object{}}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return hostError("dbQuery", err)
+		}
+
+		row := make(map[string]object.Object, len(columns))
+		for i, column := range columns {
+			row[column] = sqlValue(values[i])
+		}
+		result.Elements = append(result.Elements, newHash(row))
+	}
+	if err := rows.Err(); err != nil {
+		return hostError("dbQuery", err)
+	}
+	return result
+}
+
+// dbExec runs a statement that returns no rows and reports
+// {"rowsAffected": n, "lastInsertId": id}. Either count is null when the
+// driver does not support it.
+func dbExec(args ...object.Object) object.Object {
+	db, query, params, errObj := statementArgs("dbExec", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	res, err := db.Exec(query, params...)
+	if err != nil {
+		return hostError("dbExec", err)
+	}
+
+	count := func(n int64, err error) object.Object {
+		if err != nil {
+			return NULL
+		}
+		return &object.Integer{Value: n}
+	}
+	return newHash(map[string]object.Object{
+		"rowsAffected": count(res.RowsAffected()),
+		"lastInsertId": count(res.LastInsertId()),
+	})
+}
+
+// sqlValue converts a scanned column to an object. The language has no
+// floats yet, so they come back as their decimal string.
+func sqlValue(v interface{}) object.Object {
+	switch v := v.(type) {
+	case nil:
+		return NULL
+	case int64:
+		return &object.Integer{Value: v}
+	case bool:
+		return nativeBoolToBooleanObject(v)
+	case string:
+		return &object.String{Value: v}
+	case []byte:
+		return &object.String{Value: string(v)}
+	case float64:
+		return &object.String{Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case time.Time:
+		return &object.String{Value: v.Format(time.RFC3339Nano)}
+	default:
+		return &object.String{Value: fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,151 @@
+package evaluator
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"simple-interpreter/object"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// memDriver is a minimal database/sql driver for the db builtins. It accepts
+// "insert" with (name, active) arguments and "select" returning every row.
+// Each DSN names its own store, so tests stay independent across runs.
+type memDriver struct {
+	mu     sync.Mutex
+	stores map[string]*memStore
+}
+
+type memStore struct {
+	mu   sync.Mutex
+	rows [][]driver.Value
+}
+
+func (d *memDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stores[dsn] == nil {
+		d.stores[dsn] = &memStore{}
+	}
+	return &memConn{d.stores[dsn]}, nil
+}
+
+var memDSNs int64
+
+// freshDSN names a store no earlier test or run has written to.
+func freshDSN() string {
+	return strconv.FormatInt(atomic.AddInt64(&memDSNs, 1), 10)
+}
+
+type memConn struct{ d *memStore }
+
+func (c *memConn) Prepare(query string) (driver.Stmt, error) {
+	if query != "insert" && query != "select" {
+		return nil, errors.New("syntax error")
+	}
+	return &memStmt{c.d, query}, nil
+}
+func (c *memConn) Close() error              { return nil }
+func (c *memConn) Begin() (driver.Tx, error) { return nil, errors.New("unsupported") }
+
+type memStmt struct {
+	d     *memStore
+	query string
+}
+
+func (s *memStmt) Close() error { return nil }
+
+func (s *memStmt) NumInput() int {
+	if s.query == "insert" {
+		return 2
+	}
+	return 0
+}
+
+func (s *memStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	id := int64(len(s.d.rows) + 1)
+	s.d.rows = append(s.d.rows, []driver.Value{id, args[0], args[1]})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *memStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	return &memRows{rows: append([][]driver.Value(nil), s.d.rows...)}, nil
+}
+
+type memRows struct{ rows [][]driver.Value }
+
+func (r *memRows) Columns() []string { return []string{"id", "name", "active"} }
+func (r *memRows) Close() error      { return nil }
+
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("monkeytest", &memDriver{stores: make(map[string]*memStore)})
+}
+
+func TestDBBuiltins(t *testing.T) {
+	input := `
+let db = dbOpen("monkeytest", "` + freshDSN() + `");
+let res = dbExec(db, "insert", ["ada", true]);
+dbExec(db, "insert", ["bob", if (false) { 1 }]);
+let rows = dbQuery(db, "select");
+close(db);
+[res["rowsAffected"], res["lastInsertId"], len(rows), rows[0]["name"], rows[0]["active"], rows[1]["id"], rows[1]["active"]]
+`
+	result, ok := testEvalWith(input, CapFS|CapNet).(*object.Array)
+	if !ok {
+		t.Fatalf("expected array, got %s", testEvalWith(input, CapFS|CapNet).Inspect())
+	}
+	expected := "[1, null, 2, ada, true, 2, null]"
+	if got := result.Inspect(); got != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+}
+
+func TestDBBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`dbOpen("nodriver", "")`, `dbOpen: sql: unknown driver "nodriver" (forgotten import?)`},
+		{`dbOpen(1, "")`, "argument to `dbOpen` must be STRING, got INTEGER"},
+		{`dbQuery(1, "select")`, "argument to `dbQuery` must be HANDLE, got INTEGER"},
+		{`dbQuery(dbOpen("monkeytest", ""), "drop")`, "dbQuery: syntax error"},
+		{`dbExec(dbOpen("monkeytest", ""), "insert", [[1], 2])`, "argument to `dbExec` not supported, got ARRAY"},
+		{`dbExec(dbOpen("monkeytest", ""))`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEvalWith(tt.input, CapFS|CapNet).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+}
+
+func TestDBOpenRequiresCapabilities(t *testing.T) {
+	if _, ok := Builtins(CapAll &^ CapNet)["dbOpen"]; ok {
+		t.Error("dbOpen registered without CapNet")
+	}
+	if _, ok := Builtins(CapFS | CapNet)["dbOpen"]; !ok {
+		t.Error("dbOpen missing with CapFS|CapNet")
+	}
+}
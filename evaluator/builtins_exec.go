@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"simple-interpreter/diag"
+	"simple-interpreter/object"
+)
+
+func init() {
+	builtins["exec"] = &object.Builtin{Fn: execBuiltin}
+	builtinCapabilities["exec"] = CapExec
+}
+
+// execBuiltin runs a program directly, without a shell:
+//
+//	exec("git", ["status", "--short"]) => {"stdout": ..., "stderr": ..., "code": 0}
+func execBuiltin(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": "1 or 2"})
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return argumentTypeError("exec", object.STRING_OBJ, args[0])
+	}
+
+	var argv []string
+	if len(args) == 2 {
+		arr, ok := args[1].(*object.Array)
+		if !ok {
+			return argumentTypeError("exec", object.ARRAY_OBJ, args[1])
+		}
+		for _, el := range arr.Elements {
+			s, ok := el.(*object.String)
+			if !ok {
+				return argumentTypeError("exec", object.STRING_OBJ, el)
+			}
+			argv = append(argv, s.Value)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name.Value, argv...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	code := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return newError(diag.HostError, diag.Data{"builtin": "exec", "detail": err})
+		}
+		code = exitErr.ExitCode()
+	}
+
+	return newHash(map[string]object.Object{
+		"stdout": &object.String{Value: stdout.String()},
+		"stderr": &object.String{Value: stderr.String()},
+		"code":   &object.Integer{Value: int64(code)},
+	})
+}
@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"os/exec"
+	"simple-interpreter/object"
+	"testing"
+)
+
+func TestExecBuiltin(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	result := testEvalWith(`exec("sh", ["-c", "echo out; echo err >&2; exit 3"])`, CapExec)
+	hash, ok := result.(*object.Hash)
+	if !ok {
+		t.Fatalf("expected hash, got %T (%+v)", result, result)
+	}
+
+	expected := map[string]string{"stdout": "out\n", "stderr": "err\n", "code": "3"}
+	for key, want := range expected {
+		pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("missing key %q", key)
+			continue
+		}
+		got := pair.Value.Inspect()
+		if got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExecBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`exec(1)`, "argument to `exec` must be STRING, got INTEGER"},
+		{`exec("true", "x")`, "argument to `exec` must be ARRAY, got STRING"},
+		{`exec("true", [1])`, "argument to `exec` must be STRING, got INTEGER"},
+		{`exec("/nonexistent/program")`, "exec: fork/exec /nonexistent/program: no such file or directory"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEvalWith(tt.input, CapExec).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+}
+
+func TestExecRequiresCapability(t *testing.T) {
+	if _, ok := Builtins(CapAll &^ CapExec)["exec"]; ok {
+		t.Error("exec registered without CapExec")
+	}
+	if _, ok := Builtins(CapExec)["exec"]; !ok {
+		t.Error("exec missing with CapExec")
+	}
+
+	errObj, ok := testEval(`exec("echo", ["hi"])`).(*object.Error)
+	if !ok || errObj.Message != "identifier not found: exec" {
+		t.Errorf("exec available under Eval. got=%v", errObj)
+	}
+}
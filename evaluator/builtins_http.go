@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"io"
+	"net/http"
+	"simple-interpreter/diag"
+	"simple-interpreter/object"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	builtins["httpGet"] = &object.Builtin{Fn: httpGet}
+	builtins["httpRequest"] = &object.Builtin{Fn: httpRequest}
+	builtinCapabilities["httpGet"] = CapNet
+	builtinCapabilities["httpRequest"] = CapNet
+}
+
+func httpGet(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+	}
+	url, ok := args[0].(*object.String)
+	if !ok {
+		return argumentTypeError("httpGet", object.STRING_OBJ, args[0])
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url.Value, nil)
+	if err != nil {
+		return newError(diag.HostError, diag.Data{"builtin": "httpGet", "detail": err})
+	}
+	return doHTTP("httpGet", req)
+}
+
+// httpRequest sends the request described by a hash:
+//
+//	httpRequest({"method": "POST", "url": u, "headers": {"Content-Type": "text/plain"}, "body": "hi"})
+func httpRequest(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+	}
+	spec, ok := args[0].(*object.Hash)
+	if !ok {
+		return argumentTypeError("httpRequest", object.HASH_OBJ, args[0])
+	}
+
+	field := func(name string) (string, *object.Error) {
+		v, ok := hashGet(spec, name)
+		if !ok {
+			return "", nil
+		}
+		s, ok := v.(*object.String)
+		if !ok {
+			return "", argumentTypeError("httpRequest", object.STRING_OBJ, v)
+		}
+		return s.Value, nil
+	}
+
+	method, errObj := field("method")
+	if errObj != nil {
+		return errObj
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	url, errObj := field("url")
+	if errObj != nil {
+		return errObj
+	}
+	body, errObj := field("body")
+	if errObj != nil {
+		return errObj
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return newError(diag.HostError, diag.Data{"builtin": "httpRequest", "detail": err})
+	}
+
+	if v, ok := hashGet(spec, "headers"); ok {
+		headers, ok := v.(*object.Hash)
+		if !ok {
+			return argumentTypeError("httpRequest", object.HASH_OBJ, v)
+		}
+		for _, pair := range headers.Pairs {
+			name, ok := pair.Key.(*object.String)
+			if !ok {
+				return argumentTypeError("httpRequest", object.STRING_OBJ, pair.Key)
+			}
+			value, ok := pair.Value.(*object.String)
+			if !ok {
+				return argumentTypeError("httpRequest", object.STRING_OBJ, pair.Value)
+			}
+			req.Header.Set(name.Value, value.Value)
+		}
+	}
+	return doHTTP("httpRequest", req)
+}
+
+func doHTTP(builtin string, req *http.Request) object.Object {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return newError(diag.HostError, diag.Data{"builtin": builtin, "detail": err})
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return newError(diag.HostError, diag.Data{"builtin": builtin, "detail": err})
+	}
+
+	headers := make(map[string]object.Object, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[name] = &object.String{Value: strings.Join(values, ", ")}
+	}
+
+	return newHash(map[string]object.Object{
+		"status":  &object.Integer{Value: int64(resp.StatusCode)},
+		"headers": newHash(headers),
+		"body":    &object.String{Value: string(body)},
+	})
+}
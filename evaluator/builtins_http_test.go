@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"simple-interpreter/object"
+	"testing"
+)
+
+func TestHTTPBuiltins(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s", r.URL.Path, r.Header.Get("X-Token"), body)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		input  string
+		status int64
+		method string
+		body   string
+	}{
+		{fmt.Sprintf(`httpGet("%s/a")`, srv.URL), 201, "GET", "/a  "},
+		{
+			fmt.Sprintf(`httpRequest({"method": "post", "url": "%s/b", "headers": {"X-Token": "t"}, "body": "hi"})`, srv.URL),
+			201, "POST", "/b t hi",
+		},
+	}
+
+	for _, tt := range tests {
+		hash, ok := testEvalWith(tt.input, CapNet).(*object.Hash)
+		if !ok {
+			t.Fatalf("%s: expected hash, got %s", tt.input, testEvalWith(tt.input, CapNet).Inspect())
+		}
+		status, _ := hashGet(hash, "status")
+		testIntegerObject(t, status, tt.status)
+
+		body, _ := hashGet(hash, "body")
+		if body.Inspect() != tt.body {
+			t.Errorf("body = %q, want %q", body.Inspect(), tt.body)
+		}
+
+		headers, _ := hashGet(hash, "headers")
+		method, _ := hashGet(headers.(*object.Hash), "X-Method")
+		if method == nil || method.Inspect() != tt.method {
+			t.Errorf("X-Method = %v, want %s", method, tt.method)
+		}
+	}
+}
+
+func TestHTTPBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`httpGet(1)`, "argument to `httpGet` must be STRING, got INTEGER"},
+		{`httpRequest("x")`, "argument to `httpRequest` must be HASH, got STRING"},
+		{`httpRequest({"url": 1})`, "argument to `httpRequest` must be STRING, got INTEGER"},
+		{`httpRequest({"url": "http://x", "headers": {"a": 1}})`, "argument to `httpRequest` must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEvalWith(tt.input, CapNet).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+
+	if _, ok := Builtins(CapAll &^ CapNet)["httpGet"]; ok {
+		t.Error("httpGet registered without CapNet")
+	}
+}
@@ -0,0 +1,259 @@
+package evaluator
+
+import (
+	"errors"
+	"io"
+	"net"
+	"simple-interpreter/diag"
+	"simple-interpreter/object"
+	"strings"
+	"time"
+)
+
+const (
+	listenerHandle = "listener"
+	connHandle     = "conn"
+	packetHandle   = "packet"
+
+	defaultReadSize = 4096
+	maxReadSize     = 64 << 10
+	dialTimeout     = 30 * time.Second
+)
+
+// Only the builtins that create sockets need CapNet; read, write, close and
+// localAddr operate on handles a script could not have obtained without it.
+func init() {
+	for name, fn := range map[string]object.BuiltinFunction{
+		"listen":  listenBuiltin,
+		"accept":  acceptBuiltin,
+		"connect": connectBuiltin,
+	} {
+		builtins[name] = &object.Builtin{Fn: fn}
+		builtinCapabilities[name] = CapNet
+	}
+	builtins["read"] = &object.Builtin{Fn: readBuiltin}
+	builtins["write"] = &object.Builtin{Fn: writeBuiltin}
+	builtins["close"] = &object.Builtin{Fn: closeBuiltin}
+	builtins["localAddr"] = &object.Builtin{Fn: localAddrBuiltin}
+}
+
+func networkArgs(builtin string, args []object.Object) (string, string, *object.Error) {
+	if len(args) != 2 {
+		return "", "", newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 2})
+	}
+	network, ok := args[0].(*object.String)
+	if !ok {
+		return "", "", argumentTypeError(builtin, object.STRING_OBJ, args[0])
+	}
+	address, ok := args[1].(*object.String)
+	if !ok {
+		return "", "", argumentTypeError(builtin, object.STRING_OBJ, args[1])
+	}
+	return network.Value, address.Value, nil
+}
+
+func handleArg(builtin string, arg object.Object, kinds ...string) (*object.Handle, *object.Error) {
+	h, ok := arg.(*object.Handle)
+	if !ok {
+		return nil, argumentTypeError(builtin, object.HANDLE_OBJ, arg)
+	}
+	if len(kinds) == 0 {
+		return h, nil
+	}
+	for _, kind := range kinds {
+		if h.Kind == kind {
+			return h, nil
+		}
+	}
+	return nil, newError(diag.InvalidArgument, diag.Data{"builtin": builtin, "got": h.Inspect()})
+}
+
+func hostError(builtin string, err error) *object.Error {
+	return newError(diag.HostError, diag.Data{"builtin": builtin, "detail": err})
+}
+
+// listen("tcp", ":8080") returns a listener for stream networks and a packet
+// socket for "udp" and "unixgram".
+func listenBuiltin(args ...object.Object) object.Object {
+	network, address, errObj := networkArgs("listen", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	if strings.HasPrefix(network, "udp") || network == "unixgram" {
+		pc, err := net.ListenPacket(network, address)
+		if err != nil {
+			return hostError("listen", err)
+		}
+		return &object.Handle{Kind: packetHandle, Value: pc}
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return hostError("listen", err)
+	}
+	return &object.Handle{Kind: listenerHandle, Value: ln}
+}
+
+func acceptBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+	}
+	h, errObj := handleArg("accept", args[0], listenerHandle)
+	if errObj != nil {
+		return errObj
+	}
+
+	conn, err := h.Value.(net.Listener).Accept()
+	if err != nil {
+		return hostError("accept", err)
+	}
+	return &object.Handle{Kind: connHandle, Value: conn}
+}
+
+func connectBuiltin(args ...object.Object) object.Object {
+	network, address, errObj := networkArgs("connect", args)
+	if errObj != nil {
+		return errObj
+	}
+
+	conn, err := net.DialTimeout(network, address, dialTimeout)
+	if err != nil {
+		return hostError("connect", err)
+	}
+	return &object.Handle{Kind: connHandle, Value: conn}
+}
+
+// read(conn, n) returns up to n bytes (4096 by default, at most 64 KiB) as a
+// string, or null once the peer has closed the connection. On a packet socket
+// it returns {"data": ..., "addr": ...}.
+func readBuiltin(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": "1 or 2"})
+	}
+	h, errObj := handleArg("read", args[0], connHandle, packetHandle)
+	if errObj != nil {
+		return errObj
+	}
+
+	size := defaultReadSize
+	if len(args) == 2 {
+		n, ok := args[1].(*object.Integer)
+		if !ok {
+			return argumentTypeError("read", object.INTEGER_OBJ, args[1])
+		}
+		if n.Value <= 0 || n.Value > maxReadSize {
+			return newError(diag.InvalidArgument, diag.Data{"builtin": "read", "got": n.Value})
+		}
+		size = int(n.Value)
+	}
+	buf := make([]byte, size)
+
+	if pc, ok := h.Value.(net.PacketConn); ok && h.Kind == packetHandle {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return hostError("read", err)
+		}
+		return newHash(map[string]object.Object{
+			"data": &object.String{Value: string(buf[:n])},
+			"addr": &object.String{Value: addr.String()},
+		})
+	}
+
+	n, err := h.Value.(net.Conn).Read(buf)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return NULL
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return hostError("read", err)
+	}
+	return &object.String{Value: string(buf[:n])}
+}
+
+// write(conn, data) sends data and returns the number of bytes written. A
+// packet socket also takes the destination: write(sock, data, "host:port").
+func writeBuiltin(args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": "2 or 3"})
+	}
+	h, errObj := handleArg("write", args[0], connHandle, packetHandle)
+	if errObj != nil {
+		return errObj
+	}
+	data, ok := args[1].(*object.String)
+	if !ok {
+		return argumentTypeError("write", object.STRING_OBJ, args[1])
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if h.Kind == packetHandle {
+		if len(args) != 3 {
+			return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 3})
+		}
+		dest, ok := args[2].(*object.String)
+		if !ok {
+			return argumentTypeError("write", object.STRING_OBJ, args[2])
+		}
+		pc := h.Value.(net.PacketConn)
+		addr, resolveErr := resolvePacketAddr(pc.LocalAddr().Network(), dest.Value)
+		if resolveErr != nil {
+			return hostError("write", resolveErr)
+		}
+		n, err = pc.WriteTo([]byte(data.Value), addr)
+	} else {
+		n, err = h.Value.(net.Conn).Write([]byte(data.Value))
+	}
+	if err != nil {
+		return hostError("write", err)
+	}
+	return &object.Integer{Value: int64(n)}
+}
+
+func resolvePacketAddr(network, address string) (net.Addr, error) {
+	if strings.HasPrefix(network, "udp") {
+		return net.ResolveUDPAddr(network, address)
+	}
+	return net.ResolveUnixAddr(network, address)
+}
+
+func closeBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+	}
+	h, errObj := handleArg("close", args[0])
+	if errObj != nil {
+		return errObj
+	}
+	closer, ok := h.Value.(io.Closer)
+	if !ok {
+		return newError(diag.InvalidArgument, diag.Data{"builtin": "close", "got": h.Inspect()})
+	}
+	if err := closer.Close(); err != nil {
+		return hostError("close", err)
+	}
+	return NULL
+}
+
+func localAddrBuiltin(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": 1})
+	}
+	h, errObj := handleArg("localAddr", args[0], listenerHandle, connHandle, packetHandle)
+	if errObj != nil {
+		return errObj
+	}
+
+	var addr net.Addr
+	switch v := h.Value.(type) {
+	case net.Listener:
+		addr = v.Addr()
+	case net.Conn:
+		addr = v.LocalAddr()
+	case net.PacketConn:
+		addr = v.LocalAddr()
+	}
+	return &object.String{Value: addr.String()}
+}
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"simple-interpreter/object"
+	"testing"
+)
+
+func TestSocketBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`
+		let ln = listen("tcp", "127.0.0.1:0");
+		let client = connect("tcp", localAddr(ln));
+		let server = accept(ln);
+		write(client, "ping");
+		let got = read(server);
+		close(client);
+		close(ln);
+		[got, read(server), close(server)]
+		`, `[ping, null, null]`},
+		{`
+		let ln = listen("tcp", "127.0.0.1:0");
+		let c = connect("tcp", localAddr(ln));
+		write(c, "abcdef");
+		let s = accept(ln);
+		let r = read(s, 3);
+		close(c); close(s); close(ln);
+		r
+		`, `abc`},
+	}
+
+	for _, tt := range tests {
+		result := testEvalWith(tt.input, CapNet)
+		if result.Inspect() != tt.expected {
+			t.Errorf("got %s, want %s", result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestUDPSocketBuiltins(t *testing.T) {
+	result := testEvalWith(`
+	let a = listen("udp", "127.0.0.1:0");
+	let b = listen("udp", "127.0.0.1:0");
+	write(a, "hello", localAddr(b));
+	let packet = read(b);
+	let result = [packet["data"], packet["addr"], localAddr(a)];
+	close(a);
+	close(b);
+	result
+	`, CapNet)
+	arr, ok := result.(*object.Array)
+	if !ok {
+		t.Fatalf("expected array, got %s", result.Inspect())
+	}
+	if arr.Elements[0].Inspect() != "hello" {
+		t.Errorf("data = %s, want hello", arr.Elements[0].Inspect())
+	}
+	if arr.Elements[1].Inspect() != arr.Elements[2].Inspect() {
+		t.Errorf("sender = %s, want %s", arr.Elements[1].Inspect(), arr.Elements[2].Inspect())
+	}
+}
+
+func TestSocketBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`listen("tcp")`, "wrong number of arguments. got=1, want=2"},
+		{`accept(1)`, "argument to `accept` must be HANDLE, got INTEGER"},
+		{`accept(connect("tcp", localAddr(listen("tcp", "127.0.0.1:0"))))`, "argument to `accept` not supported, got <conn>"},
+		{`read(listen("tcp", "127.0.0.1:0"))`, "argument to `read` not supported, got <listener>"},
+		{`read(connect("tcp", localAddr(listen("tcp", "127.0.0.1:0"))), 0)`, "argument to `read` not supported, got 0"},
+		{`read(connect("tcp", localAddr(listen("tcp", "127.0.0.1:0"))), 65537)`, "argument to `read` not supported, got 65537"},
+		{`read(connect("tcp", localAddr(listen("tcp", "127.0.0.1:0"))), 9223372036854775807)`, "argument to `read` not supported, got 9223372036854775807"},
+		{`write(listen("udp", "127.0.0.1:0"), "x")`, "wrong number of arguments. got=2, want=3"},
+	}
+	for _, tt := range tests {
+		errObj, ok := testEvalWith(tt.input, CapNet).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected error", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: got %q, want %q", tt.input, errObj.Message, tt.expected)
+		}
+	}
+
+	for _, name := range []string{"listen", "accept", "connect"} {
+		if _, ok := Builtins(CapAll &^ CapNet)[name]; ok {
+			t.Errorf("%s registered without CapNet", name)
+		}
+	}
+}
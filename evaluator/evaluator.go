@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"simple-interpreter/ast"
+	"simple-interpreter/diag"
 	"simple-interpreter/object"
+	"simple-interpreter/token"
+	"strconv"
 )
 
 var (
@@ -12,94 +17,221 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+const DefaultMaxDepth = 1000
+
+type Hooks struct {
+	OnEnterNode func(node ast.Node, env *object.Environment)
+	OnExitNode  func(node ast.Node, result object.Object)
+	OnCall      func(call *ast.CallExpression, fn object.Object, args []object.Object)
+	OnReturn    func(call *ast.CallExpression, fn object.Object, result object.Object)
+
+	// OnError fires once per runtime error, at the node that raised it,
+	// rather than at every enclosing node the error propagates through.
+	OnError func(node ast.Node, err *object.Error)
+
+	// OnBuiltin fires after each builtin call with the name the builtin is
+	// registered under.
+	OnBuiltin func(call *ast.CallExpression, name string, args []object.Object, result object.Object)
+}
+
+type Evaluator struct {
+	MaxDepth int
+	Hooks    Hooks
+	Builtins map[string]*object.Builtin
+
+	depth    int
+	reported *object.Error
+}
+
+// New returns an evaluator with only the builtins that need no capability.
+// Callers that want file, network, environment or process access set
+// Builtins to a table from Builtins with those capabilities.
+func New() *Evaluator {
+	return &Evaluator{MaxDepth: DefaultMaxDepth, Builtins: Builtins(CapNone)}
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func EvalSafe(node ast.Node, env *object.Environment) (object.Object, error) {
+	return New().EvalSafe(node, env)
+}
+
+func (e *Evaluator) EvalSafe(node ast.Node, env *object.Environment) (result object.Object, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = diag.New(diag.Internal, diag.Data{"component": "evaluator", "detail": r})
+		}
+	}()
+
+	result = e.Eval(node, env)
+	if errObj, ok := result.(*object.Error); ok {
+		return result, Diagnostic(errObj)
+	}
+	return result, nil
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if e.Hooks.OnEnterNode != nil {
+		e.Hooks.OnEnterNode(node, env)
+	}
+	result := e.evalNode(node, env)
+	if e.Hooks.OnError != nil {
+		if errObj, ok := result.(*object.Error); ok && errObj != e.reported {
+			e.reported = errObj
+			e.Hooks.OnError(node, errObj)
+		}
+	}
+	if e.Hooks.OnExitNode != nil {
+		e.Hooks.OnExitNode(node, result)
+	}
+	return result
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return e.evalInterpolatedString(node, env)
+	case *ast.CharLiteral:
+		return &object.Integer{Value: int64(node.Value)}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, left, env)
+		}
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *ast.PostfixExpression:
+		return e.evalPostfixExpression(node, env)
+	case *ast.ComparisonChain:
+		return e.evalComparisonChain(node, env)
+	case *ast.RangeExpression:
+		return e.evalRangeExpression(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.LetGroup:
+		for _, binding := range node.Bindings {
+			if val := e.Eval(binding, env); isError(val) {
+				return val
+			}
+		}
+	case *ast.DestructuringLet:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := destructure(node, val, env); err != nil {
+			return err
+		}
+	case *ast.AssignExpression:
+		val := e.Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Assign(node.Name.Value, val) {
+			return newError(diag.IdentifierNotFound, diag.Data{"name": node.Name.Value})
+		}
+		return val
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.BadExpression, *ast.BadStatement:
+		pos := node.Pos()
+		return newError(diag.BadSyntax, diag.Data{"position": strconv.Itoa(pos.Line) + ":" + strconv.Itoa(pos.Column)})
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		return evalFunction(node, env)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if member, ok := node.Function.(*ast.MemberExpression); ok {
+			return e.evalMethodCall(node, member, env)
+		}
+		function := e.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.callFunction(node, function, args)
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return newError(diag.MemberNotSupported, diag.Data{"name": node.Property.Value, "type": obj.Type()})
+		}
+		return evalHashIndexExpression(hash, &object.String{Value: node.Property.Value})
 	case *ast.ArrayLiteral:
-		elems := evalExpressions(node.Elements, env)
+		elems := e.evalExpressions(node.Elements, env)
 		if len(elems) == 1 && isError(elems[0]) {
 			return elems[0]
 		}
 		return &object.Array{Elements: elems}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := e.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 
 		return evalIndexExpression(left, index)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	}
 	return nil
 }
 
-func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.Eval(stmt, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
@@ -124,8 +256,10 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitwiseNotExpression(right)
 	default:
-		return newError("unknown operator: %s %s", operator, right.Type())
+		return newError(diag.UnknownPrefixOperator, diag.Data{"operator": operator, "right": right.Type()})
 	}
 }
 
@@ -144,12 +278,20 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError(diag.UnknownPrefixOperator, diag.Data{"operator": "-", "right": right.Type()})
 	}
 	value := right.(*object.Integer).Value
 	return &object.Integer{Value: -value}
 }
 
+func evalBitwiseNotExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError(diag.UnknownPrefixOperator, diag.Data{"operator": "~", "right": right.Type()})
+	}
+	value := right.(*object.Integer).Value
+	return &object.Integer{Value: ^value}
+}
+
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
@@ -161,9 +303,9 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalInfixStringExpression(operator, left, right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.TypeMismatch, operands(left, operator, right))
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.UnknownOperator, operands(left, operator, right))
 	}
 }
 
@@ -179,7 +321,29 @@ func evalInfixIntegerExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(diag.DivisionByZero, diag.Data{"left": leftVal, "operator": operator, "right": rightVal})
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(diag.DivisionByZero, diag.Data{"left": leftVal, "operator": operator, "right": rightVal})
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<", ">>":
+		if rightVal < 0 {
+			return newError(diag.NegativeShift, diag.Data{"left": leftVal, "operator": operator, "right": rightVal})
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: leftVal << rightVal}
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -188,8 +352,12 @@ func evalInfixIntegerExpression(operator string, left object.Object, right objec
 		return nativeBoolToBooleanObject(leftVal > rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.UnknownOperator, operands(left, operator, right))
 	}
 }
 
@@ -200,25 +368,190 @@ func evalInfixStringExpression(operator string, left object.Object, right object
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diag.UnknownOperator, operands(left, operator, right))
 	}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) == true {
-		return Eval(ie.Consequence, env)
+		return e.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.Eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+// evalForStatement runs the loop in its own scope, so a variable declared
+// in the init clause is not visible after the loop. The loop itself
+// evaluates to NULL.
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+	if fs.Init != nil {
+		if init := e.Eval(fs.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := e.Eval(fs.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		result := e.Eval(fs.Body, loopEnv)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+
+		if fs.Post != nil {
+			if post := e.Eval(fs.Post, loopEnv); isError(post) {
+				return post
+			}
+		}
+	}
+}
+
+// evalLogicalExpression short-circuits && and ||: the right operand is only
+// evaluated when the left one does not decide the result. Like most dynamic
+// languages the result is the deciding operand itself, not a Boolean, so
+// name || "default" picks the first truthy value.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+	return e.Eval(node.Right, env)
+}
+
+// evalPostfixExpression increments or decrements an integer variable,
+// array element or hash value in place and returns its previous value. The
+// operands of an index target are evaluated once.
+func (e *Evaluator) evalPostfixExpression(node *ast.PostfixExpression, env *object.Environment) object.Object {
+	var (
+		old   object.Object
+		store func(object.Object)
+	)
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		old = e.evalIdentifier(target, env)
+		store = func(val object.Object) { env.Assign(target.Value, val) }
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		old = evalIndexExpression(left, index)
+		store = func(val object.Object) {
+			switch left := left.(type) {
+			case *object.Array:
+				left.Elements[index.(*object.Integer).Value] = val
+			case *object.Hash:
+				key := index.(object.Hashable).HashKey()
+				left.Pairs[key] = object.HashPair{Key: index, Value: val}
+			}
+		}
+	default:
+		return newError(diag.InvalidAssign, diag.Data{"target": fmt.Sprint(node.Target)})
+	}
+	if isError(old) {
+		return old
+	}
+
+	integer, ok := old.(*object.Integer)
+	if !ok {
+		return newError(diag.UnknownPostfixOperator, diag.Data{"left": old.Type(), "operator": node.Operator})
+	}
+	delta := int64(1)
+	if node.Operator == "--" {
+		delta = -1
+	}
+	store(&object.Integer{Value: integer.Value + delta})
+	return integer
+}
+
+// evalComparisonChain compares adjacent operands left to right, stopping at
+// the first comparison that fails; later operands are then not evaluated.
+func (e *Evaluator) evalComparisonChain(node *ast.ComparisonChain, env *object.Environment) object.Object {
+	left := e.Eval(node.Operands[0], env)
+	if isError(left) {
+		return left
+	}
+	var result object.Object = TRUE
+	for i, operator := range node.Operators {
+		right := e.Eval(node.Operands[i+1], env)
+		if isError(right) {
+			return right
+		}
+		result = evalInfixExpression(operator, left, right)
+		if isError(result) || !isTruthy(result) {
+			return result
+		}
+		left = right
+	}
+	return result
+}
+
+// evalRangeExpression builds the array of integers from start up to end,
+// including end for ..=. A range whose end comes before its start is empty.
+// MaxRangeLength is the most elements a range expression may produce, so
+// that a script cannot exhaust memory with a range such as 0..1e12.
+const MaxRangeLength = 1 << 24
+
+func (e *Evaluator) evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Object {
+	start := e.Eval(node.From, env)
+	if isError(start) {
+		return start
+	}
+	end := e.Eval(node.To, env)
+	if isError(end) {
+		return end
+	}
+	operator := node.Token.Literal
+	if start.Type() != end.Type() {
+		return newError(diag.TypeMismatch, operands(start, operator, end))
+	}
+	if start.Type() != object.INTEGER_OBJ {
+		return newError(diag.UnknownOperator, operands(start, operator, end))
+	}
+
+	from, to := start.(*object.Integer).Value, end.(*object.Integer).Value
+	if to < from || (to == from && !node.Inclusive) {
+		return &object.Array{Elements: []object.Object{}}
+	}
+	// The length is counted in uint64, where to - from cannot overflow.
+	// Only the inclusive range over every int64 is one too long to count,
+	// and it is over the limit anyway.
+	length := uint64(to) - uint64(from)
+	if node.Inclusive && length != math.MaxUint64 {
+		length++
+	}
+	if length > MaxRangeLength {
+		return newError(diag.RangeTooLarge, diag.Data{"from": from, "operator": operator, "to": to, "limit": MaxRangeLength})
+	}
+	elements := make([]object.Object, length)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: from + int64(i)}
+	}
+	return &object.Array{Elements: elements}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case FALSE:
@@ -232,11 +565,11 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -246,29 +579,82 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 		}
 	}
 
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
-func evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(ident.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[ident.Value]; ok {
+	if builtin, ok := e.Builtins[ident.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + ident.Value)
+	data := diag.Data{"name": ident.Value}
+	if name := e.closestName(ident.Value, env); name != "" {
+		data["suggestion"] = name
+	}
+	return newError(diag.IdentifierNotFound, data)
+}
+
+// closestName suggests a bound name within a small edit distance of name.
+func (e *Evaluator) closestName(name string, env *object.Environment) string {
+	best, bestDist := "", max(1, len(name)/3)+1
+	consider := func(candidate string) {
+		d := editDistance(name, candidate)
+		if d < bestDist || (d == bestDist && candidate < best) {
+			best, bestDist = candidate, d
+		}
+	}
+	for scope := env; scope != nil; scope = scope.Outer() {
+		for candidate := range scope.Bindings() {
+			consider(candidate)
+		}
+	}
+	for candidate := range e.Builtins {
+		consider(candidate)
+	}
+	return best
+}
+
+// editDistance counts the insertions, deletions, substitutions and adjacent
+// transpositions needed to turn a into b.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
 }
 
 func evalFunction(fn *ast.FunctionLiteral, env *object.Environment) object.Object {
-	return &object.Function{Parameters: fn.Parameters, Body: fn.Body, Env: env}
+	return &object.Function{Parameters: fn.Parameters, Rest: fn.Rest, Body: fn.Body, Env: env}
 }
 
-func evalExpressions(args []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(args []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, exp := range args {
-		evalExp := Eval(exp, env)
+		evalExp := e.Eval(exp, env)
 		if isError(evalExp) {
 			return []object.Object{evalExp}
 		}
@@ -290,7 +676,7 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(diag.IndexNotSupported, diag.Data{"type": left.Type()})
 	}
 }
 
@@ -299,7 +685,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(diag.UnusableHashKey, diag.Data{"type": index.Type()})
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -309,20 +695,37 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+// evalInterpolatedString concatenates the parts of node in order, embedding
+// each non-string value as it would be printed.
+func (e *Evaluator) evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for _, part := range node.Parts {
+		value := e.Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	// Pairs is a map, so evaluate keys in source order to keep side effects
+	// and traces deterministic.
+	for _, keyNode := range node.OrderedKeys() {
+		valueNode := node.Pairs[keyNode]
+		key := e.Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(diag.UnusableHashKey, diag.Data{"type": key.Type()})
 		}
 
-		value := Eval(valueNode, env)
+		value := e.Eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -333,17 +736,116 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 	return &object.Hash{Pairs: pairs}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalMethodCall evaluates obj.name(args). A hash with a "name" key calls
+// that value with args; otherwise the receiver becomes the first argument of
+// the function or builtin called name, so xs.push(1) is push(xs, 1).
+func (e *Evaluator) evalMethodCall(call *ast.CallExpression, member *ast.MemberExpression, env *object.Environment) object.Object {
+	receiver := e.Eval(member.Object, env)
+	if isError(receiver) {
+		return receiver
+	}
+
+	var function object.Object
+	var args []object.Object
+	if hash, ok := receiver.(*object.Hash); ok {
+		if pair, ok := hash.Pairs[(&object.String{Value: member.Property.Value}).HashKey()]; ok {
+			function = pair.Value
+		}
+	}
+	if function == nil {
+		function = e.evalIdentifier(member.Property, env)
+		if isError(function) {
+			return function
+		}
+		args = append(args, receiver)
+	}
+
+	rest := e.evalExpressions(call.Arguments, env)
+	if len(rest) == 1 && isError(rest[0]) {
+		return rest[0]
+	}
+	return e.callFunction(call, function, append(args, rest...))
+}
+
+func (e *Evaluator) callFunction(
+	call *ast.CallExpression,
+	fn object.Object,
+	args []object.Object,
+) object.Object {
+	if e.Hooks.OnCall != nil {
+		e.Hooks.OnCall(call, fn, args)
+	}
+	result := e.applyFunction(fn, args)
+	if builtin, ok := fn.(*object.Builtin); ok && e.Hooks.OnBuiltin != nil {
+		e.Hooks.OnBuiltin(call, e.builtinName(builtin, call), args, result)
+	}
+	if e.Hooks.OnReturn != nil {
+		e.Hooks.OnReturn(call, fn, result)
+	}
+	return result
+}
+
+func (e *Evaluator) builtinName(builtin *object.Builtin, call *ast.CallExpression) string {
+	for name, b := range e.Builtins {
+		if b == builtin {
+			return name
+		}
+	}
+	return call.Function.String()
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if fn.Rest != nil && len(args) < len(fn.Parameters) {
+			return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": "at least " + strconv.Itoa(len(fn.Parameters))})
+		}
+		if fn.Rest == nil && len(args) != len(fn.Parameters) {
+			return newError(diag.WrongArgumentCount, diag.Data{"got": len(args), "want": len(fn.Parameters)})
+		}
+		if e.depth >= e.MaxDepth {
+			return newError(diag.MaxCallDepth, diag.Data{"limit": e.MaxDepth})
+		}
+
+		e.depth++
+		defer func() { e.depth-- }()
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		evaluated := e.Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(diag.NotAFunction, diag.Data{"type": fn.Type()})
+	}
+}
+
+// destructure binds the names of a destructuring let to the elements of an
+// array or the values of a hash. Names past the end of the array, or missing
+// from the hash, are bound to NULL.
+func destructure(node *ast.DestructuringLet, val object.Object, env *object.Environment) *object.Error {
+	switch {
+	case node.Pattern.Type == token.LBRACKET && val.Type() == object.ARRAY_OBJ:
+		elements := val.(*object.Array).Elements
+		for i, name := range node.Names {
+			if i < len(elements) {
+				env.Set(name.Value, elements[i])
+			} else {
+				env.Set(name.Value, NULL)
+			}
+		}
+	case node.Pattern.Type == token.LBRACE && val.Type() == object.HASH_OBJ:
+		for _, name := range node.Names {
+			env.Set(name.Value, evalHashIndexExpression(val, &object.String{Value: name.Value}))
+		}
+	default:
+		pattern := "array"
+		if node.Pattern.Type == token.LBRACE {
+			pattern = "hash"
+		}
+		return newError(diag.DestructureMismatch, diag.Data{"type": val.Type(), "pattern": pattern})
 	}
+	return nil
 }
 
 func extendFunctionEnv(
@@ -355,6 +857,10 @@ func extendFunctionEnv(
 	for idx, param := range fn.Parameters {
 		extendedEnv.Set(param.Value, args[idx])
 	}
+	if fn.Rest != nil {
+		rest := append([]object.Object{}, args[len(fn.Parameters):]...)
+		extendedEnv.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
 	return extendedEnv
 }
 
@@ -365,8 +871,24 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(code diag.Code, data diag.Data) *object.Error {
+	d := diag.New(code, data)
+	return &object.Error{Code: string(code), Message: d.Message, Fix: d.Fix, Data: data}
+}
+
+func operands(left object.Object, operator string, right object.Object) diag.Data {
+	return diag.Data{"left": left.Type(), "operator": operator, "right": right.Type()}
+}
+
+// Diagnostic describes a runtime error object as a structured diagnostic.
+func Diagnostic(err *object.Error) diag.Diagnostic {
+	return diag.Diagnostic{
+		Code:     diag.Code(err.Code),
+		Severity: diag.Error,
+		Message:  err.Message,
+		Fix:      err.Fix,
+		Data:     err.Data,
+	}
 }
 
 func isError(obj object.Object) bool {
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"fmt"
+	"reflect"
+	"simple-interpreter/ast"
+	"simple-interpreter/diag"
 	"simple-interpreter/lexer"
 	"simple-interpreter/object"
 	"simple-interpreter/parser"
+	"simple-interpreter/token"
 	"testing"
 )
 
@@ -27,6 +32,16 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"17 % 5", 2},
+		{"-7 % 3", -1},
+		{"1 + 10 % 4 * 3", 7},
+		{"12 & 10", 8},
+		{"12 | 3", 15},
+		{"12 ^ 10", 6},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 | 2 & 6 << 1", 5},
 	}
 
 	for _, tt := range tests {
@@ -44,6 +59,15 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// testEvalWith evaluates input with the builtins the allowed capabilities
+// grant, which Eval leaves out.
+func testEvalWith(input string, allowed Capability) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := New()
+	e.Builtins = Builtins(allowed)
+	return e.Eval(program, object.NewEnvironment())
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 
@@ -171,6 +195,12 @@ func TestReturnStatements(t *testing.T) {
 		{"return 10; 9;", 10},
 		{"return 2 * 5; 9;", 10},
 		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+		{"if (10 > 1) { if (10 < 1) { return 10; } return 1; }", 1},
+		{"let f = fn(x) { if (x > 0) { return 1; } return 2; }; f(1) + f(-1) * 10;", 21},
+		{"let f = fn() { return 1; }; f(); 5;", 5},
+		{"let f = fn() { let g = fn() { return 1; }; g(); return 2; }; f();", 2},
+		{"let f = fn() { for (let i = 0; i < 10; i++) { if (i == 3) { return i; } } 99 }; f();", 3},
 	}
 
 	for _, tt := range tests {
@@ -223,6 +253,30 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"10 / (5 - 5)",
+			"division by zero: 10 / 0",
+		},
+		{
+			"10 % 0",
+			"division by zero: 10 % 0",
+		},
+		{
+			"1 << -1",
+			"negative shift count: 1 << -1",
+		},
+		{
+			"~true",
+			"unknown operator: ~BOOLEAN",
+		},
+		{
+			"let add = fn(x, y) { x + y; }; add(1);",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"let loop = fn(x) { loop(x); }; loop(1);",
+			"maximum call depth of 1000 exceeded",
+		},
 	}
 
 	for _, tt := range tests {
@@ -241,6 +295,41 @@ func TestErrorHandling(t *testing.T) {
 
 }
 
+func TestErrorDiagnostics(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diag.Code
+		expectedFix  string
+	}{
+		{"5 + true;", diag.TypeMismatch, ""},
+		{"-true", diag.UnknownPrefixOperator, ""},
+		{"10 / 0", diag.DivisionByZero, ""},
+		{"let count = 1; cuont", diag.IdentifierNotFound, "did you mean `count`?"},
+		{"lenn([])", diag.IdentifierNotFound, "did you mean `len`?"},
+		{"qqqqqqq", diag.IdentifierNotFound, ""},
+		{"len(1)", diag.InvalidArgument, ""},
+		{"len(1, 2)", diag.WrongArgumentCount, ""},
+		{"5(1)", diag.NotAFunction, ""},
+		{"1[0]", diag.IndexNotSupported, ""},
+		{"let x = (1 + ;", diag.BadSyntax, "fix the syntax errors reported by the parser first"},
+	}
+
+	for _, tt := range tests {
+		_, err := EvalSafe(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		d, ok := err.(diag.Diagnostic)
+		if !ok {
+			t.Errorf("%q: expected diag.Diagnostic, got %T (%v)", tt.input, err, err)
+			continue
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("%q: code = %s, want %s", tt.input, d.Code, tt.expectedCode)
+		}
+		if d.Fix != tt.expectedFix {
+			t.Errorf("%q: fix = %q, want %q", tt.input, d.Fix, tt.expectedFix)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -250,6 +339,9 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 1, b = 2, c = 3; a + b + c", 6},
+		{"let a = 2, b = a * a; b", 4},
+		{"let s = 0; for (let i = 0, j = 3; i < j; i++) { s = s + j }; s", 9},
 	}
 
 	for _, tt := range tests {
@@ -257,6 +349,217 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, b, c] = [1, 2]; c", nil},
+		{"let [a] = [3, 4]; a", 3},
+		{`let {x, y} = {"x": 5, "y": 6}; x * y`, 30},
+		{`let {x, z} = {"x": 5}; z`, nil},
+		{"let f = fn() { let [a, b] = [1, 2]; a + b }; f()", 3},
+		{"let [a] = 5;", "cannot destructure INTEGER with array pattern"},
+		{"let {a} = [1];", "cannot destructure ARRAY with hash pattern"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected error %q, got %+v", tt.input, expected, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let total = 0; for (let i = 1; i < 5; i = i + 1) { total = total + i; }; total", 10},
+		{"let n = 1; for (; n < 100;) { n = n * 2 }; n", 128},
+		{"let f = fn() { for (let i = 0; ; i = i + 1) { if (i > 3) { return i; } } }; f()", 4},
+		{"for (let i = 0; i < 3; i = i + 1) { i }", nil},
+		{"let i = 7; for (let i = 0; i < 3; i = i + 1) {}; i", 7},
+		{"let a = 1; let b = 2; a = b = 5; a + b", 10},
+		{"let x = 1; let f = fn() { x = x + 1 }; f(); f(); x", 3},
+	}
+
+	for _, tt := range tests {
+		obj := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, obj, int64(expected))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && false", false},
+		{"true || false", true},
+		{"false && missing", false},
+		{"true || missing", true},
+		{"1 && 2", 2},
+		{"if (false) { 1 } || 3", 3},
+		{"5 || 6", 5},
+		{"false || if (false) { 1 }", nil},
+		{"let n = 0; let bump = fn() { n = n + 1; true }; false && bump(); true || bump(); n", 0},
+		{"let n = 0; let bump = fn() { n = n + 1; true }; true && bump(); false || bump(); n", 2},
+	}
+
+	for _, tt := range tests {
+		obj := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		default:
+			testNullObject(t, obj)
+		}
+	}
+
+	if errObj, ok := testEval("true && missing").(*object.Error); !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected the right operand to be evaluated, got %v", testEval("true && missing"))
+	}
+}
+
+func TestComparisonChains(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0 < 5 < 10", true},
+		{"0 < 15 < 10", false},
+		{"10 > 5 >= 5 > 1", true},
+		{"1 <= 1 <= 0", false},
+		{"3 >= 4", false},
+		{"let n = 0; let next = fn() { n = n + 1; n }; 0 < next() < 2; n", 1},
+		{"let n = 0; let next = fn() { n = n + 1; n }; 5 < 1 < next(); n", 0},
+	}
+
+	for _, tt := range tests {
+		obj := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		}
+	}
+
+	errObj, ok := testEval("1 < true < 3").(*object.Error)
+	if !ok || errObj.Message != "type mismatch: INTEGER < BOOLEAN" {
+		t.Errorf("expected a type mismatch, got %v", testEval("1 < true < 3"))
+	}
+}
+
+func TestPostfixExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 5; i++", 5},
+		{"let i = 5; i++; i", 6},
+		{"let i = 5; i--; i--; i", 3},
+		{"let total = 0; for (let i = 0; i < 4; i++) { total = total + i }; total", 6},
+		{"let a = [1, 2, 3]; a[1]++; a[1]", 3},
+		{"let h = {\"n\": 10}; h[\"n\"]--; h[\"n\"]", 9},
+		{"let calls = 0; let idx = fn() { calls++; 0 }; let a = [7]; a[idx()]++; calls", 1},
+		{"let x = 3; --x", 3},
+		{"1--1", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestPostfixExpressionInvalidTarget(t *testing.T) {
+	tests := []struct {
+		target   ast.Expression
+		expected string
+	}{
+		{&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5}, "cannot assign to 5"},
+		{nil, "cannot assign to <nil>"},
+	}
+
+	for _, tt := range tests {
+		node := &ast.PostfixExpression{Token: token.Token{Type: token.INCREMENT, Literal: "++"}, Target: tt.target, Operator: "++"}
+		errObj, ok := Eval(node, object.NewEnvironment()).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("got %v, want error %q", errObj, tt.expected)
+		}
+	}
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let p = {"x": 3, "y": 4}; p.x * p.y`, 12},
+		{`{"a": {"b": 5}}.a.b`, 5},
+		{`{"a": 1}.missing`, nil},
+		{`[1, 2, 3].len()`, 3},
+		{`[1, 2].push(3).last()`, 3},
+		{`let double = fn(x) { x * 2 }; 21.double()`, 42},
+		{`let add = fn(a, b) { a + b }; 40.add(2)`, 42},
+		{`let counter = {"next": fn(n) { n + 1 }}; counter.next(1)`, 2},
+		{`let getA = fn(h) { h.a }; {"a": 9}.getA()`, 9},
+	}
+
+	for _, tt := range tests {
+		obj := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, obj, int64(expected))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"y = 1", "identifier not found: y"},
+		{"5.x", "cannot read member x of INTEGER"},
+		{"[1].nothing()", "identifier not found: nothing"},
+		{"y++", "identifier not found: y"},
+		{"let b = true; b++", "unknown operator: BOOLEAN++"},
+		{"let a = [1]; a[5]--", "unknown operator: NULL--"},
+		{"for (let i = 0; i < 3; i = i + true) {}", "type mismatch: INTEGER + BOOLEAN"},
+		{"for (let i = 0; j < 3; i = i + 1) {}", "identifier not found: j"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%q: message = %q, want %q", tt.input, errObj.Message, tt.expectedMessage)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) {x + 2;};"
 
@@ -289,6 +592,68 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let five = fn() { 5 }; five() + five();", 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let a = newAdder(1); let b = newAdder(10); a(1) + b(1);", 13},
+		{"let counter = fn() { let n = 0; fn() { n = n + 1; n } }; let next = counter(); next(); next(); next();", 3},
+		{"let counter = fn() { let n = 0; fn() { n = n + 1; n } }; let a = counter(); let b = counter(); a(); a(); b();", 1},
+		{"let x = 1; let f = fn() { x }; let x = 2; f();", 2},
+		{"let f = fn() { let x = 5; fn() { x } }; let x = 1; f()();", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(...xs) { len(xs) }; count()", 0},
+		{"let count = fn(...xs) { len(xs) }; count(1, 2, 3)", 3},
+		{"let second = fn(a, ...xs) { xs[0] }; second(1, 2, 3)", 2},
+		{"let sum = fn(...nums) { let total = 0; for (let i = 0; i < len(nums); i++) { total = total + nums[i] }; total }; sum(1, 2, 3, 4)", 10},
+		{"let f = fn(a, b, ...xs) { a }; f(1)", "wrong number of arguments. got=1, want=at least 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected error %q, got %+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestChainedCallsAndIndexes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let matrix = [[1, 2], [3, 4]]; matrix[1][0]", 3},
+		{"let getFn = fn() { fn(x) { x * 2 } }; getFn()(3)", 6},
+		{"let arr = [fn(x) { [x, x + 1] }]; arr[0](5)[1]", 6},
+		{"let add = fn(a) { fn(b) { fn(c) { a + b + c } } }; add(1)(2)(3)", 6},
+		{`let h = {"f": fn() { [7, 8] }}; h["f"]()[1] * 2`, 16},
 	}
 
 	for _, tt := range tests {
@@ -308,6 +673,35 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"cost ${5}"`, "cost 5"},
+		{`let name = "world"; "hello ${name}!"`, "hello world!"},
+		{`"${1 + 2}${[1, 2]}${true}"`, "3[1, 2]true"},
+		{`let x = 2; "n: ${ {"k": "in ${x}"}["k"] } \${x}"`, "n: in 2 ${x}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: String has wrong value. got=%q, want=%q", tt.input, str.Value, tt.expected)
+		}
+	}
+
+	evaluated := testEval(`"a ${missing} b"`)
+	if _, ok := evaluated.(*object.Error); !ok {
+		t.Errorf("expected an error for an unknown identifier. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -332,6 +726,10 @@ func TestBuiltinFunction(t *testing.T) {
 		{`len("Hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len()`, "wrong number of arguments. got=0, want=1"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`len({"a": 1})`, "argument to `len` not supported, got HASH"},
 	}
 
 	for _, tt := range tests {
@@ -356,6 +754,106 @@ func TestBuiltinFunction(t *testing.T) {
 	}
 }
 
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest(rest([1]))`, nil},
+		{`push([], 1)`, []int64{1}},
+		{`let a = [1]; let b = push(a, 2); len(a) * 10 + len(b)`, 12},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last("abc")`, "argument to `last` must be ARRAY, got STRING"},
+		{`rest([1], [2])`, "wrong number of arguments. got=2, want=1"},
+		{`push([1])`, "wrong number of arguments. got=1, want=2"},
+		{`push(1, 2)`, "argument to `push` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("%s: object is not Array. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("%s: wrong number of elements. want=%d, got=%d", tt.input, len(expected), len(array.Elements))
+				continue
+			}
+			for i, want := range expected {
+				testIntegerObject(t, array.Elements[i], want)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`'a'`, 97},
+		{`'b' - 'a'`, 1},
+		{`'a' < 'b'`, true},
+		{`'\n' == 10`, true},
+		{`ord("π")`, 960},
+		{`chr('a' + 2)`, "c"},
+		{`chr(ord("é"))`, "é"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s = %s, want %q", tt.input, evaluated.Inspect(), expected)
+			}
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`ord("ab")`, "argument to `ord` not supported, got \"ab\""},
+		{`ord("")`, "argument to `ord` not supported, got \"\""},
+		{`chr(-1)`, "argument to `chr` not supported, got -1"},
+		{`chr("a")`, "argument to `chr` must be INTEGER, got STRING"},
+	}
+	for _, tt := range errors {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("%s: got %s, want error %q", tt.input, testEval(tt.input).Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -376,6 +874,52 @@ func TestArrayLiterals(t *testing.T) {
 	testIntegerObject(t, result.Elements[2], 6)
 }
 
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1..4", []int64{1, 2, 3}},
+		{"1..=4", []int64{1, 2, 3, 4}},
+		{"let n = 2; -n..n", []int64{-2, -1, 0, 1}},
+		{"3..3", []int64{}},
+		{"3..=3", []int64{3}},
+		{"5..1", []int64{}},
+		{"len(0..10)", 10},
+		{"(0..=10)[10]", 10},
+		{`1.."a"`, "type mismatch: INTEGER .. STRING"},
+		{`"a"..="z"`, "unknown operator: STRING ..= STRING"},
+		{"9223372036854775806..=9223372036854775807", []int64{9223372036854775806, 9223372036854775807}},
+		{"len(-9223372036854775807 - 1..=-9223372036854775807)", 2},
+		{"0..=9223372036854775807", "range 0..=9223372036854775807 has more than 16777216 elements"},
+		{"0..9000000000000", "range 0..9000000000000 has more than 16777216 elements"},
+		{"-9223372036854775807 - 1..=9223372036854775807", "range -9223372036854775808..=9223372036854775807 has more than 16777216 elements"},
+		{"0..=16777216", "range 0..=16777216 has more than 16777216 elements"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%q: expected error %q, got %+v", tt.input, expected, evaluated)
+			}
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok || len(array.Elements) != len(expected) {
+				t.Errorf("%q: expected %d elements, got %+v", tt.input, len(expected), evaluated)
+				continue
+			}
+			for i, value := range expected {
+				testIntegerObject(t, array.Elements[i], value)
+			}
+		}
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -470,6 +1014,15 @@ func TestHashLiterals(t *testing.T) {
 	}
 }
 
+func TestHashLiteralEvaluationOrder(t *testing.T) {
+	input := `let n = 0; let next = fn() { n = n + 1; n };
+let h = {"b": next(), "a": next(), next(): "c", next(): "d"};
+[h["b"], h["a"], h[3], h[4]]`
+	if got := testEval(input).Inspect(); got != `[1, 2, c, d]` {
+		t.Errorf("hash pairs not evaluated in source order. got=%s", got)
+	}
+}
+
 func TestHashIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -514,3 +1067,134 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestEvalSafe(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"1 + 2", ""},
+		{"1 / 0", "division by zero: 1 / 0"},
+		{"let f = fn() { 1 }; f(1, 2)", "wrong number of arguments. got=2, want=0"},
+	}
+
+	for _, tt := range tests {
+		program, err := parser.ParseSafe(tt.input)
+		if err != nil {
+			t.Fatalf("ParseSafe(%q) returned error: %s", tt.input, err)
+		}
+
+		_, err = EvalSafe(program, object.NewEnvironment())
+		if tt.expectedError == "" {
+			if err != nil {
+				t.Errorf("EvalSafe(%q) returned error: %s", tt.input, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expectedError {
+			t.Errorf("EvalSafe(%q) wrong error. expected=%q, got=%v",
+				tt.input, tt.expectedError, err)
+		}
+	}
+}
+
+func FuzzEval(f *testing.F) {
+	seeds := []string{
+		"let add = fn(x, y) { x + y; }; add(5, 5);",
+		"if (1 < 2) { 10 } else { 20 }",
+		`let h = {"one": 1, true: 2}; h["one"]`,
+		"len([1, 2, 3]); push(rest([1]), first([]))",
+		"10 / 0",
+		"fn(x) { x }()",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+		Eval(program, object.NewEnvironment())
+	})
+}
+
+func TestBuiltinsCapabilities(t *testing.T) {
+	builtins["testExec"] = &object.Builtin{Fn: func(args ...object.Object) object.Object { return NULL }}
+	builtinCapabilities["testExec"] = CapExec | CapFS
+	defer func() {
+		delete(builtins, "testExec")
+		delete(builtinCapabilities, "testExec")
+	}()
+
+	tests := []struct {
+		allowed  Capability
+		expected bool
+	}{
+		{CapNone, false},
+		{CapExec, false},
+		{CapFS | CapNet, false},
+		{CapExec | CapFS, true},
+		{CapExec | CapFS | CapEnv, true},
+	}
+
+	for _, tt := range tests {
+		table := Builtins(tt.allowed)
+		if _, ok := table["testExec"]; ok != tt.expected {
+			t.Errorf("Builtins(%b) registered testExec=%t, want=%t", tt.allowed, ok, tt.expected)
+		}
+		if _, ok := table["len"]; !ok {
+			t.Errorf("Builtins(%b) is missing len", tt.allowed)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	input := `
+let double = fn(x) { x * 2 };
+let xs = push([1], double(2));
+len(xs);
+len(1);
+`
+	var calls, returns, enters, exits int
+	var builtinCalls []string
+	var errs []*object.Error
+	var errNode ast.Node
+
+	e := New()
+	e.Hooks = Hooks{
+		OnEnterNode: func(ast.Node, *object.Environment) { enters++ },
+		OnExitNode:  func(ast.Node, object.Object) { exits++ },
+		OnCall:      func(*ast.CallExpression, object.Object, []object.Object) { calls++ },
+		OnReturn:    func(*ast.CallExpression, object.Object, object.Object) { returns++ },
+		OnError: func(node ast.Node, err *object.Error) {
+			errs = append(errs, err)
+			errNode = node
+		},
+		OnBuiltin: func(call *ast.CallExpression, name string, args []object.Object, result object.Object) {
+			builtinCalls = append(builtinCalls, fmt.Sprintf("%s/%d=%s", name, len(args), result.Inspect()))
+		},
+	}
+	e.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment())
+
+	if calls != 4 || returns != 4 {
+		t.Errorf("calls=%d returns=%d, want 4 each", calls, returns)
+	}
+	if enters == 0 || enters != exits {
+		t.Errorf("enters=%d exits=%d", enters, exits)
+	}
+
+	expected := []string{"push/2=[1, 4]", "len/1=2", "len/1=ERROR: argument to `len` not supported, got INTEGER"}
+	if !reflect.DeepEqual(builtinCalls, expected) {
+		t.Errorf("builtin calls = %q, want %q", builtinCalls, expected)
+	}
+
+	if len(errs) != 1 {
+		t.Fatalf("OnError fired %d times, want 1", len(errs))
+	}
+	if call, ok := errNode.(*ast.CallExpression); !ok || call.Function.String() != "len" {
+		t.Errorf("error reported at %T %s", errNode, errNode)
+	}
+}
@@ -0,0 +1,123 @@
+package interp
+
+import (
+	"simple-interpreter/ast"
+	"simple-interpreter/diag"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/optimize"
+	"simple-interpreter/parser"
+	"sync"
+)
+
+type Options struct {
+	AllowFS   bool
+	AllowNet  bool
+	AllowEnv  bool
+	AllowExec bool
+
+	// BuiltinAllowlist restricts the registered builtins to the named ones.
+	// A nil list allows every builtin permitted by the capability flags.
+	BuiltinAllowlist []string
+
+	// Language selects the message catalog used for returned errors, such
+	// as "es". The empty string means diag.DefaultLanguage.
+	Language string
+
+	// Optimize folds constant expressions before evaluation, on each
+	// Interpreter.Run and once in Compile; see optimize.Fold.
+	Optimize bool
+}
+
+type Interpreter struct {
+	mu   sync.Mutex
+	opts Options
+	env  *object.Environment
+	eval *evaluator.Evaluator
+}
+
+func New(opts Options) *Interpreter {
+	e := evaluator.New()
+	e.Builtins = builtinsFor(opts)
+
+	return &Interpreter{
+		opts: opts,
+		env:  object.NewEnvironment(),
+		eval: e,
+	}
+}
+
+func (i *Interpreter) Options() Options {
+	return i.opts
+}
+
+func (i *Interpreter) Env() *object.Environment {
+	return i.env
+}
+
+func (i *Interpreter) Evaluator() *evaluator.Evaluator {
+	return i.eval
+}
+
+// RegisterBuiltin makes fn callable as name in later runs, replacing any
+// builtin of the same name. Host-registered builtins bypass the capability
+// flags and allowlist.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.eval.Builtins[name] = &object.Builtin{Fn: fn}
+}
+
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	program, err := parser.ParseSafe(src)
+	if err != nil {
+		return nil, i.opts.localize(err)
+	}
+	if i.opts.Optimize {
+		program = optimize.Fold(program).(*ast.Program)
+	}
+	result, err := i.eval.EvalSafe(program, i.env)
+	return result, i.opts.localize(err)
+}
+
+func (o Options) localize(err error) error {
+	if err == nil || o.Language == "" {
+		return err
+	}
+	return diag.LocalizeError(err, o.Language)
+}
+
+func (o Options) capabilities() evaluator.Capability {
+	caps := evaluator.CapNone
+	if o.AllowFS {
+		caps |= evaluator.CapFS
+	}
+	if o.AllowNet {
+		caps |= evaluator.CapNet
+	}
+	if o.AllowEnv {
+		caps |= evaluator.CapEnv
+	}
+	if o.AllowExec {
+		caps |= evaluator.CapExec
+	}
+	return caps
+}
+
+func builtinsFor(opts Options) map[string]*object.Builtin {
+	table := evaluator.Builtins(opts.capabilities())
+	if opts.BuiltinAllowlist == nil {
+		return table
+	}
+
+	allowed := make(map[string]*object.Builtin, len(opts.BuiltinAllowlist))
+	for _, name := range opts.BuiltinAllowlist {
+		if builtin, ok := table[name]; ok {
+			allowed[name] = builtin
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,161 @@
+package interp
+
+import (
+	"fmt"
+	"simple-interpreter/object"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunKeepsState(t *testing.T) {
+	i := New(Options{})
+
+	if _, err := i.Run("let add = fn(a, b) { a + b };"); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	result, err := i.Run("add(2, len([1, 2, 3]))")
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if result.Inspect() != "5" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestBuiltinAllowlist(t *testing.T) {
+	i := New(Options{BuiltinAllowlist: []string{"len"}})
+
+	if _, err := i.Run(`len("abc")`); err != nil {
+		t.Errorf("allowlisted builtin failed: %s", err)
+	}
+
+	_, err := i.Run("first([1])")
+	if err == nil || err.Error() != "identifier not found: first" {
+		t.Errorf("builtin outside the allowlist should be unavailable. got=%v", err)
+	}
+
+	empty := New(Options{BuiltinAllowlist: []string{}})
+	if _, err := empty.Run(`len("abc")`); err == nil {
+		t.Errorf("empty allowlist should register no builtins")
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	i := New(Options{BuiltinAllowlist: []string{}})
+	i.RegisterBuiltin("answer", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+
+	result, err := i.Run("answer(0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("got %s, want 42", result.Inspect())
+	}
+}
+
+func TestRunReportsErrors(t *testing.T) {
+	i := New(Options{})
+
+	if _, err := i.Run("let = 5"); err == nil {
+		t.Errorf("expected parse error")
+	}
+	if _, err := i.Run("1 / 0"); err == nil {
+		t.Errorf("expected runtime error")
+	}
+}
+
+func TestRunLocalizesErrors(t *testing.T) {
+	i := New(Options{Language: "es"})
+
+	if _, err := i.Run("1 / 0"); err == nil || err.Error() != "división entre cero: 1 / 0" {
+		t.Errorf("runtime error = %v", err)
+	}
+	if _, err := i.Run("let x = (1;"); err == nil || !strings.HasPrefix(err.Error(), "se esperaba") {
+		t.Errorf("parse error = %v", err)
+	}
+}
+
+const concurrentScript = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let h = {"n": %d, "list": push([1, 2], 3)};
+fib(h["n"]) + len(h["list"]);
+`
+
+// Run with -race to check that separate interpreters share no mutable state.
+func TestConcurrentInterpreters(t *testing.T) {
+	expected := []int64{0, 1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89, 144, 233, 377, 610}
+
+	var wg sync.WaitGroup
+	for n := range expected {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			i := New(Options{})
+			for run := 0; run < 5; run++ {
+				result, err := i.Run(fmt.Sprintf(concurrentScript, n))
+				if err != nil {
+					t.Errorf("Run returned error: %s", err)
+					return
+				}
+				if result.Inspect() != fmt.Sprint(expected[n]+3) {
+					t.Errorf("fib(%d) wrong. got=%s", n, result.Inspect())
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentRunOnSharedInterpreter(t *testing.T) {
+	i := New(Options{})
+	if _, err := i.Run("let counter = 0;"); err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 20; n++ {
+				if _, err := i.Run("let counter = counter + 1;"); err != nil {
+					t.Errorf("Run returned error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	result, _ := i.Run("counter")
+	if result.Inspect() != "160" {
+		t.Errorf("lost updates on shared interpreter. got=%s", result.Inspect())
+	}
+}
+
+func TestEvaluatorBuiltinsAreIsolated(t *testing.T) {
+	a := New(Options{})
+	a.Evaluator().Builtins["len"] = a.Evaluator().Builtins["first"]
+
+	b := New(Options{})
+	result, err := b.Run(`len([7, 8])`)
+	if err != nil || result.Inspect() != "2" {
+		t.Errorf("builtin table modification leaked between interpreters. got=%v, %v", result, err)
+	}
+}
+
+func TestRunOptimize(t *testing.T) {
+	for _, optimize := range []bool{false, true} {
+		i := New(Options{Optimize: optimize})
+		result, err := i.Run(`let greet = fn(name) { if (1 < 2) { "hi " + name } else { "bye" } }; greet("a" + "b")`)
+		if err != nil {
+			t.Fatalf("Optimize=%t: %s", optimize, err)
+		}
+		if result.Inspect() != "hi ab" {
+			t.Errorf("Optimize=%t: got %s, want hi ab", optimize, result.Inspect())
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package interp
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugin opens the Go plugin at path and calls its exported Register
+// function with i. Register must have the signature
+//
+//	func Register(*interp.Interpreter)
+//
+// or return an error, in which case a non-nil error aborts loading.
+func LoadPlugin(i *Interpreter, path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("loading plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup("Register")
+	if err != nil {
+		return fmt.Errorf("loading plugin %s: %w", path, err)
+	}
+
+	switch register := sym.(type) {
+	case func(*Interpreter):
+		register(i)
+		return nil
+	case func(*Interpreter) error:
+		if err := register(i); err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("plugin %s: Register has type %T, want func(*interp.Interpreter)", path, sym)
+	}
+}
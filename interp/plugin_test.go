@@ -0,0 +1,45 @@
+package interp
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// The plugin has to be loaded by a binary built the same way it was, so the
+// test builds a small host program rather than loading into itself.
+func TestLoadPlugin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a plugin")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available")
+	}
+
+	dir := t.TempDir()
+	so := filepath.Join(dir, "greet.so")
+	host := filepath.Join(dir, "host")
+	for _, args := range [][]string{
+		{"build", "-buildmode=plugin", "-o", so, "./testdata/greet"},
+		{"build", "-o", host, "./testdata/host"},
+	} {
+		if out, err := exec.Command(gobin, args...).CombinedOutput(); err != nil {
+			t.Skipf("cannot build plugins here: %v\n%s", err, out)
+		}
+	}
+
+	out, err := exec.Command(host, so, `greet("monkey")`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("host failed: %v\n%s", err, out)
+	}
+	if string(out) != "hello, monkey" {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestLoadPluginMissing(t *testing.T) {
+	if err := LoadPlugin(New(Options{}), filepath.Join(t.TempDir(), "missing.so")); err == nil {
+		t.Error("expected error loading a missing plugin")
+	}
+}
@@ -0,0 +1,149 @@
+package interp
+
+import (
+	"fmt"
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/optimize"
+	"simple-interpreter/parser"
+	"sort"
+)
+
+// Script is a parsed program that can be evaluated many times, concurrently,
+// each run starting from a fresh copy of the prototype environment.
+type Script struct {
+	program  *ast.Program
+	builtins map[string]*object.Builtin
+	proto    *object.Environment
+	opts     Options
+}
+
+func Compile(src string, opts Options) (*Script, error) {
+	program, err := parser.ParseSafe(src)
+	if err != nil {
+		return nil, opts.localize(err)
+	}
+	if opts.Optimize {
+		program = optimize.Fold(program).(*ast.Program)
+	}
+
+	return &Script{
+		program:  program,
+		builtins: builtinsFor(opts),
+		proto:    object.NewEnvironment(),
+		opts:     opts,
+	}, nil
+}
+
+// Define binds name in the prototype environment shared by every later run.
+// Each run starts with its own copy of an array or hash defined this way.
+// It must not be called concurrently with Run.
+func (s *Script) Define(name string, value interface{}) error {
+	obj, err := toObject(value)
+	if err != nil {
+		return fmt.Errorf("define %s: %w", name, err)
+	}
+	s.proto.Set(name, obj)
+	return nil
+}
+
+func (s *Script) Run(vars map[string]interface{}) (object.Object, error) {
+	// Arrays and hashes can be changed in place, as by counts[0]++, so each
+	// run gets its own copy of them rather than sharing the prototype's.
+	env := s.proto.Clone()
+	copies := make(map[object.Object]object.Object)
+	for name, val := range env.Bindings() {
+		env.Set(name, copyValue(val, copies))
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		obj, err := toObject(vars[name])
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", name, err)
+		}
+		env.Set(name, obj)
+	}
+
+	e := evaluator.New()
+	e.Builtins = s.builtins
+	result, err := e.EvalSafe(s.program, env)
+	return result, s.opts.localize(err)
+}
+
+// copyValue copies the arrays and hashes in obj, recursively. copies maps
+// each container already copied to its copy, so shared and cyclic values
+// keep their shape.
+func copyValue(obj object.Object, copies map[object.Object]object.Object) object.Object {
+	if c, ok := copies[obj]; ok {
+		return c
+	}
+	switch obj := obj.(type) {
+	case *object.Array:
+		c := &object.Array{Elements: make([]object.Object, len(obj.Elements))}
+		copies[obj] = c
+		for i, el := range obj.Elements {
+			c.Elements[i] = copyValue(el, copies)
+		}
+		return c
+	case *object.Hash:
+		c := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, len(obj.Pairs))}
+		copies[obj] = c
+		for key, pair := range obj.Pairs {
+			c.Pairs[key] = object.HashPair{Key: pair.Key, Value: copyValue(pair.Value, copies)}
+		}
+		return c
+	}
+	return obj
+}
+
+func toObject(value interface{}) (object.Object, error) {
+	switch v := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return v, nil
+	case bool:
+		if v {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case int:
+		return &object.Integer{Value: int64(v)}, nil
+	case int32:
+		return &object.Integer{Value: int64(v)}, nil
+	case int64:
+		return &object.Integer{Value: v}, nil
+	case string:
+		return &object.String{Value: v}, nil
+	case []interface{}:
+		elements := make([]object.Object, len(v))
+		for i, el := range v {
+			obj, err := toObject(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(v))
+		for key, val := range v {
+			obj, err := toObject(val)
+			if err != nil {
+				return nil, err
+			}
+			k := &object.String{Value: key}
+			pairs[k.HashKey()] = object.HashPair{Key: k, Value: obj}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", value)
+	}
+}
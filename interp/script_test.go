@@ -0,0 +1,137 @@
+package interp
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const rules = `
+let discount = fn(order) {
+	if (order["total"] > threshold) { order["total"] / 10 } else { 0 }
+};
+discount(order) + len(tags);
+`
+
+func TestScriptRunsManyTimes(t *testing.T) {
+	script, err := Compile(rules, Options{})
+	if err != nil {
+		t.Fatalf("Compile returned error: %s", err)
+	}
+	if err := script.Define("threshold", 100); err != nil {
+		t.Fatalf("Define returned error: %s", err)
+	}
+
+	tests := []struct {
+		total    int
+		tags     []interface{}
+		expected string
+	}{
+		{50, []interface{}{}, "0"},
+		{200, []interface{}{"vip"}, "21"},
+		{1000, []interface{}{"a", true, nil}, "103"},
+	}
+
+	for _, tt := range tests {
+		result, err := script.Run(map[string]interface{}{
+			"order": map[string]interface{}{"total": tt.total},
+			"tags":  tt.tags,
+		})
+		if err != nil {
+			t.Fatalf("Run returned error: %s", err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for total=%d. got=%s, want=%s",
+				tt.total, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestScriptRunsAreIsolated(t *testing.T) {
+	script, err := Compile(`let seen = if (seen) { seen + 1 } else { 1 }; seen`, Options{})
+	if err != nil {
+		t.Fatalf("Compile returned error: %s", err)
+	}
+	script.Define("seen", false)
+
+	for i := 0; i < 3; i++ {
+		result, err := script.Run(nil)
+		if err != nil {
+			t.Fatalf("Run returned error: %s", err)
+		}
+		if result.Inspect() != "1" {
+			t.Fatalf("run %d observed state from a previous run. got=%s", i, result.Inspect())
+		}
+	}
+}
+
+func TestScriptConcurrentRuns(t *testing.T) {
+	script, err := Compile(`x * 2`, Options{})
+	if err != nil {
+		t.Fatalf("Compile returned error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := script.Run(map[string]interface{}{"x": i})
+			if err != nil || result.Inspect() != fmt.Sprint(i*2) {
+				t.Errorf("x=%d got=%v, err=%v", i, result, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Run with -race to check that runs do not share mutable prototype values.
+func TestScriptConcurrentRunsMutatingDefinitions(t *testing.T) {
+	script, err := Compile(`counts[0]++; config["runs"]++; [counts[0], config["runs"]]`, Options{})
+	if err != nil {
+		t.Fatalf("Compile returned error: %s", err)
+	}
+	script.Define("counts", []interface{}{0})
+	script.Define("config", map[string]interface{}{"runs": 0})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := script.Run(nil)
+			if err != nil || result.Inspect() != "[1, 1]" {
+				t.Errorf("run observed state from another run. got=%v, err=%v", result, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestScriptOptimize(t *testing.T) {
+	script, err := Compile(`x * (2 + 3)`, Options{Optimize: true})
+	if err != nil {
+		t.Fatalf("Compile returned error: %s", err)
+	}
+	if got := script.program.String(); got != "(x * 5)" {
+		t.Errorf("compiled program not folded. got=%s", got)
+	}
+	result, err := script.Run(map[string]interface{}{"x": 2})
+	if err != nil || result.Inspect() != "10" {
+		t.Errorf("got=%v, err=%v", result, err)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	if _, err := Compile("let = ;", Options{}); err == nil {
+		t.Errorf("expected Compile to report parse errors")
+	}
+
+	script, _ := Compile("x", Options{})
+	if _, err := script.Run(map[string]interface{}{"x": 1.5}); err == nil {
+		t.Errorf("expected unsupported variable type to be rejected")
+	}
+	if _, err := script.Run(nil); err == nil {
+		t.Errorf("expected missing variable to be reported")
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"simple-interpreter/interp"
+	"simple-interpreter/object"
+)
+
+func Register(i *interp.Interpreter) {
+	i.RegisterBuiltin("greet", func(args ...object.Object) object.Object {
+		return &object.String{Value: "hello, " + args[0].Inspect()}
+	})
+}
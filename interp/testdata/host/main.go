@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"simple-interpreter/interp"
+)
+
+func main() {
+	i := interp.New(interp.Options{})
+	if err := interp.LoadPlugin(i, os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	result, err := i.Run(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Print(result.Inspect())
+}
@@ -0,0 +1,349 @@
+package jupyter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"simple-interpreter/diag"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/interp"
+	"simple-interpreter/lexer"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"simple-interpreter/token"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type ConnectionInfo struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	ControlPort     int    `json:"control_port"`
+	StdinPort       int    `json:"stdin_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+}
+
+func ReadConnectionFile(path string) (ConnectionInfo, error) {
+	var info ConnectionInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("reading connection file: %w", err)
+	}
+	if info.SignatureScheme != "" && info.SignatureScheme != "hmac-sha256" {
+		return info, fmt.Errorf("unsupported signature scheme %q", info.SignatureScheme)
+	}
+	return info, nil
+}
+
+// Kernel serves a Monkey interpreter over the Jupyter messaging protocol.
+// State persists across cells the same way it does across REPL lines.
+type Kernel struct {
+	key    []byte
+	interp *interp.Interpreter
+	out    bytes.Buffer
+	count  int
+
+	shell, control, stdin, iopub, hb *socket
+
+	closeOnce sync.Once
+}
+
+// New binds the kernel's sockets described by info.
+func New(info ConnectionInfo, opts interp.Options) (*Kernel, error) {
+	if info.Transport == "" {
+		info.Transport = "tcp"
+	}
+	if info.Transport != "tcp" {
+		return nil, fmt.Errorf("unsupported transport %q", info.Transport)
+	}
+
+	k := &Kernel{key: []byte(info.Key), interp: interp.New(opts)}
+
+	builtins := k.interp.Evaluator().Builtins
+	if _, ok := builtins["puts"]; ok {
+		builtins["puts"] = &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(&k.out, arg.Inspect())
+				}
+				return evaluator.NULL
+			},
+		}
+	}
+
+	binds := []struct {
+		s    **socket
+		port int
+		kind string
+	}{
+		{&k.shell, info.ShellPort, "ROUTER"},
+		{&k.control, info.ControlPort, "ROUTER"},
+		{&k.stdin, info.StdinPort, "ROUTER"},
+		{&k.iopub, info.IOPubPort, "PUB"},
+		{&k.hb, info.HBPort, "REP"},
+	}
+	for _, b := range binds {
+		s, err := listen(info.Transport, info.IP, b.port, b.kind)
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		*b.s = s
+	}
+	return k, nil
+}
+
+// Serve handles requests until a shutdown_request arrives or the kernel is
+// closed.
+func (k *Kernel) Serve() error {
+	defer k.Close()
+
+	go func() {
+		for {
+			select {
+			case in := <-k.hb.incoming:
+				in.peer.writeMessage(in.frames)
+			case <-k.hb.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		var (
+			sock *socket
+			in   incoming
+		)
+		select {
+		case in = <-k.control.incoming:
+			sock = k.control
+		case in = <-k.shell.incoming:
+			sock = k.shell
+		case <-k.shell.done:
+			return nil
+		}
+
+		msg, err := decode(in.frames, k.key)
+		if err != nil {
+			continue
+		}
+		if stop := k.handle(sock, msg); stop {
+			return nil
+		}
+	}
+}
+
+func (k *Kernel) Close() error {
+	k.closeOnce.Do(func() {
+		for _, s := range []*socket{k.shell, k.control, k.stdin, k.iopub, k.hb} {
+			if s != nil {
+				s.close()
+			}
+		}
+	})
+	return nil
+}
+
+func (k *Kernel) send(sock *socket, msg *Message) {
+	frames, err := encode(msg, k.key)
+	if err != nil {
+		return
+	}
+	sock.send(frames)
+}
+
+func (k *Kernel) respond(sock *socket, parent *Message, msgType string, content interface{}) {
+	msg, err := reply(parent, msgType, content)
+	if err != nil {
+		return
+	}
+	k.send(sock, msg)
+}
+
+func (k *Kernel) publish(parent *Message, msgType string, content interface{}) {
+	msg, err := reply(parent, msgType, content)
+	if err != nil {
+		return
+	}
+	msg.Identities = [][]byte{[]byte(msgType)}
+	k.send(k.iopub, msg)
+}
+
+func (k *Kernel) handle(sock *socket, msg *Message) (stop bool) {
+	k.publish(msg, "status", map[string]string{"execution_state": "busy"})
+	defer k.publish(msg, "status", map[string]string{"execution_state": "idle"})
+
+	switch msg.Header.MsgType {
+	case "kernel_info_request":
+		k.respond(sock, msg, "kernel_info_reply", kernelInfo())
+	case "execute_request":
+		k.execute(sock, msg)
+	case "is_complete_request":
+		k.isComplete(sock, msg)
+	case "shutdown_request":
+		var req struct {
+			Restart bool `json:"restart"`
+		}
+		json.Unmarshal(msg.Content, &req)
+		k.respond(sock, msg, "shutdown_reply", map[string]interface{}{
+			"status":  "ok",
+			"restart": req.Restart,
+		})
+		return true
+	}
+	return false
+}
+
+func kernelInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"status":                 "ok",
+		"protocol_version":       protocolVersion,
+		"implementation":         "soulstice",
+		"implementation_version": "0.1",
+		"language_info": map[string]interface{}{
+			"name":           "monkey",
+			"version":        "0.1",
+			"mimetype":       "text/x-monkey",
+			"file_extension": ".mk",
+		},
+		"banner": "This is the Soulstice programming language!",
+	}
+}
+
+func (k *Kernel) execute(sock *socket, msg *Message) {
+	var req struct {
+		Code         string `json:"code"`
+		Silent       bool   `json:"silent"`
+		StoreHistory *bool  `json:"store_history"`
+	}
+	if err := json.Unmarshal(msg.Content, &req); err != nil {
+		return
+	}
+
+	if !req.Silent && (req.StoreHistory == nil || *req.StoreHistory) {
+		k.count++
+	}
+	if !req.Silent {
+		k.publish(msg, "execute_input", map[string]interface{}{
+			"code":            req.Code,
+			"execution_count": k.count,
+		})
+	}
+
+	k.out.Reset()
+	result, err := k.interp.Run(req.Code)
+	if k.out.Len() > 0 && !req.Silent {
+		k.publish(msg, "stream", map[string]string{
+			"name": "stdout",
+			"text": k.out.String(),
+		})
+	}
+
+	if err != nil {
+		lines := strings.Split(err.Error(), "\n")
+		content := map[string]interface{}{
+			"ename":     "Error",
+			"evalue":    lines[0],
+			"traceback": lines,
+		}
+		k.publish(msg, "error", content)
+		content["status"] = "error"
+		content["execution_count"] = k.count
+		k.respond(sock, msg, "execute_reply", content)
+		return
+	}
+
+	if result != nil && result != evaluator.NULL && !req.Silent {
+		k.publish(msg, "execute_result", map[string]interface{}{
+			"execution_count": k.count,
+			"data":            displayData(result),
+			"metadata":        map[string]interface{}{},
+		})
+	}
+	k.respond(sock, msg, "execute_reply", map[string]interface{}{
+		"status":           "ok",
+		"execution_count":  k.count,
+		"user_expressions": map[string]interface{}{},
+		"payload":          []interface{}{},
+	})
+}
+
+func (k *Kernel) isComplete(sock *socket, msg *Message) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(msg.Content, &req)
+
+	status := "complete"
+	if unclosed(req.Code) {
+		status = "incomplete"
+	} else if _, err := parser.ParseSafe(req.Code); err != nil {
+		status = "invalid"
+	}
+	k.respond(sock, msg, "is_complete_reply", map[string]string{"status": status})
+}
+
+// unclosed reports whether code ends inside an open bracket, string or
+// block comment, so a frontend should keep reading lines instead of
+// executing.
+func unclosed(code string) bool {
+	depth := 0
+	l := lexer.New(code)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.INTERP_START:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.INTERP_END:
+			depth--
+		}
+	}
+	for _, d := range l.Diagnostics() {
+		if d.Code == diag.UnterminatedComment || d.Code == diag.UnterminatedString {
+			return true
+		}
+	}
+	return depth > 0
+}
+
+// displayData renders obj as a Jupyter MIME bundle. Arrays and hashes also
+// get an HTML table so notebooks show them as structured data.
+func displayData(obj object.Object) map[string]string {
+	data := map[string]string{"text/plain": obj.Inspect()}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		var b strings.Builder
+		b.WriteString("<table><tr><th>index</th><th>value</th></tr>")
+		for i, el := range obj.Elements {
+			fmt.Fprintf(&b, "<tr><td>%d</td><td>%s</td></tr>", i, html.EscapeString(el.Inspect()))
+		}
+		b.WriteString("</table>")
+		data["text/html"] = b.String()
+	case *object.Hash:
+		rows := make([]string, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			rows = append(rows, fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>",
+				html.EscapeString(pair.Key.Inspect()), html.EscapeString(pair.Value.Inspect())))
+		}
+		sort.Strings(rows)
+
+		var b strings.Builder
+		b.WriteString("<table><tr><th>key</th><th>value</th></tr>")
+		for _, row := range rows {
+			b.WriteString(row)
+		}
+		b.WriteString("</table>")
+		data["text/html"] = b.String()
+	}
+	return data
+}
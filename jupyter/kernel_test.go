@@ -0,0 +1,248 @@
+package jupyter
+
+import (
+	"encoding/json"
+	"net"
+	"simple-interpreter/interp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "secret"
+
+type client struct {
+	t     *testing.T
+	shell *zmtpConn
+	iopub *zmtpConn
+}
+
+func dial(t *testing.T, s *socket, socketType string) *zmtpConn {
+	t.Helper()
+	c, err := net.Dial("tcp", s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	z, err := handshake(c, socketType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z.socketType != s.kind {
+		t.Fatalf("peer socket type = %q, want %q", z.socketType, s.kind)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		n := len(s.peers)
+		s.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("kernel never registered the connection")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return z
+}
+
+func startKernel(t *testing.T) (*Kernel, *client, chan error) {
+	t.Helper()
+	k, err := New(ConnectionInfo{IP: "127.0.0.1", Key: testKey}, interp.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { k.Close() })
+
+	done := make(chan error, 1)
+	go func() { done <- k.Serve() }()
+
+	c := &client{t: t, shell: dial(t, k.shell, "DEALER"), iopub: dial(t, k.iopub, "SUB")}
+	return k, c, done
+}
+
+func (c *client) request(msgType string, content interface{}) {
+	c.t.Helper()
+	raw, _ := json.Marshal(content)
+	frames, err := encode(&Message{
+		Header:  Header{MsgID: newID(), Session: "test", MsgType: msgType, Version: protocolVersion},
+		Content: raw,
+	}, []byte(testKey))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.shell.writeMessage(frames); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) recv(z *zmtpConn) *Message {
+	c.t.Helper()
+	frames, err := z.readMessage()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	msg, err := decode(frames, []byte(testKey))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// published collects iopub messages up to and including the idle status.
+func (c *client) published() []*Message {
+	c.t.Helper()
+	var msgs []*Message
+	for {
+		msg := c.recv(c.iopub)
+		msgs = append(msgs, msg)
+		if msg.Header.MsgType == "status" && strings.Contains(string(msg.Content), "idle") {
+			return msgs
+		}
+	}
+}
+
+func content(t *testing.T, msg *Message) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal(msg.Content, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func find(msgs []*Message, msgType string) *Message {
+	for _, msg := range msgs {
+		if msg.Header.MsgType == msgType {
+			return msg
+		}
+	}
+	return nil
+}
+
+func TestMessageSignature(t *testing.T) {
+	msg := &Message{
+		Identities: [][]byte{[]byte("peer")},
+		Header:     Header{MsgID: "1", MsgType: "execute_request"},
+		Content:    json.RawMessage(`{"code":"1"}`),
+	}
+	frames, err := encode(msg, []byte(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decode(frames, []byte(testKey))
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if string(got.Identities[0]) != "peer" || got.Header.MsgType != "execute_request" || got.Parent != nil {
+		t.Fatalf("round trip mismatch: %+v", got)
+	}
+
+	if _, err := decode(frames, []byte("other")); err == nil {
+		t.Fatal("expected signature mismatch to be rejected")
+	}
+}
+
+func TestKernelExecute(t *testing.T) {
+	_, c, done := startKernel(t)
+
+	c.request("execute_request", map[string]interface{}{"code": `let a = [1, "<b>"]; puts("hi"); a`})
+	reply := c.recv(c.shell)
+	if reply.Header.MsgType != "execute_reply" || reply.Parent == nil {
+		t.Fatalf("unexpected reply %+v", reply.Header)
+	}
+	if got := content(t, reply); got["status"] != "ok" || got["execution_count"] != 1.0 {
+		t.Fatalf("unexpected execute_reply %v", got)
+	}
+
+	msgs := c.published()
+	if msgs[0].Header.MsgType != "status" || !strings.Contains(string(msgs[0].Content), "busy") {
+		t.Fatalf("first iopub message = %s %s", msgs[0].Header.MsgType, msgs[0].Content)
+	}
+	stream := find(msgs, "stream")
+	if stream == nil || content(t, stream)["text"] != "hi\n" {
+		t.Fatalf("missing stdout stream in %d messages", len(msgs))
+	}
+	result := find(msgs, "execute_result")
+	if result == nil {
+		t.Fatal("missing execute_result")
+	}
+	data := content(t, result)["data"].(map[string]interface{})
+	if data["text/plain"] != `[1, <b>]` {
+		t.Errorf("text/plain = %v", data["text/plain"])
+	}
+	if html, _ := data["text/html"].(string); !strings.Contains(html, "<td>&lt;b&gt;</td>") {
+		t.Errorf("text/html = %v", data["text/html"])
+	}
+
+	c.request("execute_request", map[string]interface{}{"code": "a[0] / 0"})
+	if got := content(t, c.recv(c.shell)); got["status"] != "error" || got["execution_count"] != 2.0 {
+		t.Fatalf("unexpected execute_reply %v", got)
+	}
+	if find(c.published(), "error") == nil {
+		t.Fatal("missing error message on iopub")
+	}
+
+	c.request("shutdown_request", map[string]interface{}{"restart": false})
+	if reply := c.recv(c.shell); reply.Header.MsgType != "shutdown_reply" {
+		t.Fatalf("unexpected reply %s", reply.Header.MsgType)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("kernel did not stop after shutdown_request")
+	}
+}
+
+func TestKernelInfoAndIsComplete(t *testing.T) {
+	_, c, _ := startKernel(t)
+
+	c.request("kernel_info_request", map[string]interface{}{})
+	info := content(t, c.recv(c.shell))
+	if lang := info["language_info"].(map[string]interface{}); lang["name"] != "monkey" {
+		t.Fatalf("language_info = %v", lang)
+	}
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"let x = 1;", "complete"},
+		{"fn(x) {", "incomplete"},
+		{"let x = 1; /* still", "incomplete"},
+		{`puts("total: ${ fn(x) {`, "incomplete"},
+		{"let s = \"\"\"\nfirst line", "incomplete"},
+		{`let s = "a ${x} b`, "incomplete"},
+		{"let = 1;", "invalid"},
+	}
+	for _, tt := range tests {
+		c.request("is_complete_request", map[string]string{"code": tt.code})
+		if got := content(t, c.recv(c.shell))["status"]; got != tt.want {
+			t.Errorf("is_complete(%q) = %v, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	k, _, _ := startKernel(t)
+	hb := dial(t, k.hb, "REQ")
+
+	if err := hb.writeMessage([][]byte{{}, []byte("ping")}); err != nil {
+		t.Fatal(err)
+	}
+	frames, err := hb.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || string(frames[1]) != "ping" {
+		t.Fatalf("heartbeat echoed %q", frames)
+	}
+}
@@ -0,0 +1,145 @@
+package jupyter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	protocolVersion = "5.3"
+	delimiter       = "<IDS|MSG>"
+)
+
+type Header struct {
+	MsgID    string `json:"msg_id"`
+	Session  string `json:"session"`
+	Username string `json:"username"`
+	Date     string `json:"date"`
+	MsgType  string `json:"msg_type"`
+	Version  string `json:"version"`
+}
+
+type Message struct {
+	Identities [][]byte
+	Header     Header
+	Parent     *Header
+	Metadata   map[string]interface{}
+	Content    json.RawMessage
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func sign(key []byte, parts ...[]byte) []byte {
+	if len(key) == 0 {
+		return nil
+	}
+	mac := hmac.New(sha256.New, key)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	sum := mac.Sum(nil)
+	out := make([]byte, hex.EncodedLen(len(sum)))
+	hex.Encode(out, sum)
+	return out
+}
+
+// decode parses a multipart wire message, checking its signature against key.
+func decode(frames [][]byte, key []byte) (*Message, error) {
+	split := -1
+	for i, f := range frames {
+		if string(f) == delimiter {
+			split = i
+			break
+		}
+	}
+	if split < 0 || len(frames) < split+6 {
+		return nil, errors.New("jupyter: malformed message")
+	}
+
+	parts := frames[split+2 : split+6]
+	if expected := sign(key, parts...); expected != nil && !hmac.Equal(expected, frames[split+1]) {
+		return nil, errors.New("jupyter: invalid message signature")
+	}
+
+	msg := &Message{Identities: frames[:split]}
+	if err := json.Unmarshal(parts[0], &msg.Header); err != nil {
+		return nil, fmt.Errorf("jupyter: header: %w", err)
+	}
+	if len(bytes.TrimSpace(parts[1])) > 2 {
+		msg.Parent = &Header{}
+		if err := json.Unmarshal(parts[1], msg.Parent); err != nil {
+			return nil, fmt.Errorf("jupyter: parent header: %w", err)
+		}
+	}
+	if err := json.Unmarshal(parts[2], &msg.Metadata); err != nil {
+		return nil, fmt.Errorf("jupyter: metadata: %w", err)
+	}
+	msg.Content = append(json.RawMessage(nil), parts[3]...)
+	return msg, nil
+}
+
+// encode serializes msg into its multipart wire form, signed with key.
+func encode(msg *Message, key []byte) ([][]byte, error) {
+	header, err := json.Marshal(msg.Header)
+	if err != nil {
+		return nil, err
+	}
+	parent := []byte("{}")
+	if msg.Parent != nil {
+		if parent, err = json.Marshal(msg.Parent); err != nil {
+			return nil, err
+		}
+	}
+	metadata := []byte("{}")
+	if msg.Metadata != nil {
+		if metadata, err = json.Marshal(msg.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	content := []byte(msg.Content)
+	if content == nil {
+		content = []byte("{}")
+	}
+
+	frames := make([][]byte, 0, len(msg.Identities)+6)
+	frames = append(frames, msg.Identities...)
+	frames = append(frames,
+		[]byte(delimiter),
+		sign(key, header, parent, metadata, content),
+		header, parent, metadata, content,
+	)
+	return frames, nil
+}
+
+// reply builds a message of msgType answering parent, addressed to the same
+// identities.
+func reply(parent *Message, msgType string, content interface{}) (*Message, error) {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Identities: parent.Identities,
+		Header: Header{
+			MsgID:    newID(),
+			Session:  parent.Header.Session,
+			Username: parent.Header.Username,
+			Date:     time.Now().UTC().Format(time.RFC3339Nano),
+			MsgType:  msgType,
+			Version:  protocolVersion,
+		},
+		Parent:  &parent.Header,
+		Content: raw,
+	}, nil
+}
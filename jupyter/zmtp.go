@@ -0,0 +1,326 @@
+package jupyter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// This file implements the subset of ZMTP 3.0 (NULL security mechanism)
+// needed to serve the ROUTER, PUB and REP sockets a Jupyter kernel binds.
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+type zmtpConn struct {
+	conn       net.Conn
+	r          *bufio.Reader
+	wmu        sync.Mutex
+	id         []byte
+	socketType string
+}
+
+func greeting() []byte {
+	g := make([]byte, 64)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3
+	g[11] = 0
+	copy(g[12:32], "NULL")
+	return g
+}
+
+func handshake(c net.Conn, socketType string) (*zmtpConn, error) {
+	z := &zmtpConn{conn: c, r: bufio.NewReader(c)}
+
+	if _, err := c.Write(greeting()); err != nil {
+		return nil, err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(z.r, peer); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return nil, errors.New("zmtp: bad greeting signature")
+	}
+	if peer[10] < 3 {
+		return nil, fmt.Errorf("zmtp: unsupported protocol version %d", peer[10])
+	}
+	if mech := string(bytes.TrimRight(peer[12:32], "\x00")); mech != "NULL" {
+		return nil, fmt.Errorf("zmtp: unsupported security mechanism %q", mech)
+	}
+
+	if err := z.writeFrame(readyCommand(socketType), flagCommand); err != nil {
+		return nil, err
+	}
+	body, flags, err := z.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagCommand == 0 {
+		return nil, errors.New("zmtp: expected READY command")
+	}
+	props, err := parseReady(body)
+	if err != nil {
+		return nil, err
+	}
+	z.socketType = string(props["Socket-Type"])
+	z.id = props["Identity"]
+	return z, nil
+}
+
+func readyCommand(socketType string) []byte {
+	var b bytes.Buffer
+	b.WriteByte(5)
+	b.WriteString("READY")
+	b.WriteByte(byte(len("Socket-Type")))
+	b.WriteString("Socket-Type")
+	binary.Write(&b, binary.BigEndian, uint32(len(socketType)))
+	b.WriteString(socketType)
+	return b.Bytes()
+}
+
+func parseReady(body []byte) (map[string][]byte, error) {
+	if len(body) < 1 || len(body) < 1+int(body[0]) || string(body[1:1+body[0]]) != "READY" {
+		return nil, errors.New("zmtp: expected READY command")
+	}
+	props := make(map[string][]byte)
+	rest := body[1+body[0]:]
+	for len(rest) > 0 {
+		nameLen := int(rest[0])
+		if len(rest) < 1+nameLen+4 {
+			return nil, errors.New("zmtp: malformed READY property")
+		}
+		name := string(rest[1 : 1+nameLen])
+		valueLen := int(binary.BigEndian.Uint32(rest[1+nameLen:]))
+		rest = rest[1+nameLen+4:]
+		if len(rest) < valueLen {
+			return nil, errors.New("zmtp: malformed READY property")
+		}
+		props[name] = rest[:valueLen]
+		rest = rest[valueLen:]
+	}
+	return props, nil
+}
+
+func (z *zmtpConn) readFrame() ([]byte, byte, error) {
+	flags, err := z.r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var size uint64
+	if flags&flagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+			return nil, 0, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return nil, 0, fmt.Errorf("zmtp: frame of %d bytes exceeds limit", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(z.r, body); err != nil {
+		return nil, 0, err
+	}
+	return body, flags, nil
+}
+
+const maxFrameSize = 64 << 20
+
+func (z *zmtpConn) readMessage() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		body, flags, err := z.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+func (z *zmtpConn) writeFrame(body []byte, flags byte) error {
+	var header [9]byte
+	n := 2
+	if len(body) > 255 {
+		flags |= flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+		n = 9
+	} else {
+		header[1] = byte(len(body))
+	}
+	header[0] = flags
+
+	if _, err := z.conn.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := z.conn.Write(body)
+	return err
+}
+
+func (z *zmtpConn) writeMessage(frames [][]byte) error {
+	z.wmu.Lock()
+	defer z.wmu.Unlock()
+
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := z.writeFrame(frame, flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type incoming struct {
+	peer   *zmtpConn
+	frames [][]byte
+}
+
+type socket struct {
+	kind     string
+	ln       net.Listener
+	incoming chan incoming
+	done     chan struct{}
+
+	mu     sync.Mutex
+	peers  map[string]*zmtpConn
+	nextID uint32
+}
+
+func listen(transport, ip string, port int, kind string) (*socket, error) {
+	ln, err := net.Listen(transport, fmt.Sprintf("%s:%d", ip, port))
+	if err != nil {
+		return nil, err
+	}
+	s := &socket{
+		kind:     kind,
+		ln:       ln,
+		incoming: make(chan incoming),
+		done:     make(chan struct{}),
+		peers:    make(map[string]*zmtpConn),
+	}
+	go s.acceptLoop()
+	return s, nil
+}
+
+func (s *socket) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *socket) acceptLoop() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(c)
+	}
+}
+
+func (s *socket) serve(c net.Conn) {
+	defer c.Close()
+
+	z, err := handshake(c, s.kind)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	if len(z.id) == 0 {
+		s.nextID++
+		z.id = binary.BigEndian.AppendUint32([]byte{0}, s.nextID)
+	}
+	key := string(z.id)
+	s.peers[key] = z
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.peers, key)
+		s.mu.Unlock()
+	}()
+
+	for {
+		frames, err := z.readMessage()
+		if err != nil {
+			return
+		}
+		if s.kind == "PUB" {
+			// Subscriptions are ignored: every message goes to every peer.
+			continue
+		}
+		if s.kind == "ROUTER" {
+			frames = append([][]byte{z.id}, frames...)
+		}
+		select {
+		case s.incoming <- incoming{peer: z, frames: frames}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *socket) send(frames [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.kind {
+	case "ROUTER":
+		if len(frames) == 0 {
+			return errors.New("zmtp: ROUTER message needs a routing id")
+		}
+		peer, ok := s.peers[string(frames[0])]
+		if !ok {
+			return nil
+		}
+		return peer.writeMessage(frames[1:])
+	case "PUB":
+		for _, peer := range s.peers {
+			peer.writeMessage(frames)
+		}
+		return nil
+	default:
+		return fmt.Errorf("zmtp: send not supported on %s socket", s.kind)
+	}
+}
+
+func (s *socket) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.done)
+	for _, peer := range s.peers {
+		peer.conn.Close()
+	}
+	return s.ln.Close()
+}
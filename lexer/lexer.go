@@ -1,109 +1,334 @@
 package lexer
 
 import (
+	"fmt"
+	"io"
+	"simple-interpreter/diag"
 	"simple-interpreter/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
+	file         string
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+	line         int
+	column       int
+
+	// A lexer created by NewReader holds only a window of the source in
+	// input, starting at byte offset base, and refills it from reader.
+	reader io.Reader
+	buf    []byte
+	base   int
+	err    error
+
+	// interpolations holds the open ${ ... } of enclosing strings, and
+	// stringStart the opening quote of the string being read.
+	interpolations []interpolation
+	stringStart    diag.Position
+	// interpolationNext is set when a string segment stopped at "${", and
+	// resumeString after INTERP_END, when the next token continues the
+	// enclosing string.
+	interpolationNext bool
+	resumeString      bool
+
+	trivia   bool
+	keywords *token.Keywords
+
+	// peeked holds tokens read ahead by Peek. While marks is non-zero the
+	// reader window is not discarded, so a Rollback can rescan it.
+	peeked []token.Token
+	marks  int
+
+	diagnostics []diag.Diagnostic
+}
+
+// interpolation is an open ${ ... }. It counts the braces opened inside it,
+// so the } that closes it can be told apart, and keeps where its string
+// started for reporting the string unterminated.
+type interpolation struct {
+	braces      int
+	stringStart diag.Position
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
+	l.readChar()
+	return l
+}
+
+// NewFile is like New but records name as the file of every token and
+// diagnostic position, so errors from several sources can be told apart.
+func NewFile(name, input string) *Lexer {
+	l := New(input)
+	l.file = name
+	return l
+}
+
+const readerChunkSize = 4096
+
+// NewReader returns a lexer that reads its source from r in chunks instead of
+// requiring it up front. Read errors end the token stream; see Err.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{reader: r, buf: make([]byte, readerChunkSize), line: 1}
 	l.readChar()
 	return l
 }
 
+// Source returns the text being lexed. Lexers created by NewReader only hold
+// part of it and report false.
+func (l *Lexer) Source() (string, bool) {
+	if l.buf != nil {
+		return "", false
+	}
+	return l.input, true
+}
+
+// Err returns the first error other than io.EOF returned by the reader.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// fill reads from the reader until the window holds n bytes or the reader is
+// exhausted.
+func (l *Lexer) fill(n int) {
+	for l.reader != nil && len(l.input) < n {
+		m, err := l.reader.Read(l.buf)
+		if m > 0 {
+			l.input += string(l.buf[:m])
+		}
+		if err != nil {
+			if err != io.EOF {
+				l.err = err
+			}
+			l.reader = nil
+		}
+	}
+}
+
+// discard drops the consumed part of the window. It is only called between
+// tokens, so no slice positions held by the scanning functions go stale.
+func (l *Lexer) discard() {
+	if l.marks > 0 || l.position < readerChunkSize || l.position > len(l.input) {
+		return
+	}
+	l.base += l.position
+	l.input = l.input[l.position:]
+	l.readPosition -= l.position
+	l.position = 0
+}
+
+// readChar advances by one UTF-8 encoded rune. Invalid encodings decode as
+// utf8.RuneError one byte at a time, so the lexer always makes progress.
 func (l *Lexer) readChar() {
+	if l.reader != nil {
+		// Keep the current rune and a few after it in the window, which
+		// covers peekChar, multi-character operators and \uXXXX escapes.
+		l.fill(l.readPosition + 2*utf8.UTFMax)
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+	l.position = l.readPosition
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.readPosition += 1
+		return
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
+	if b := l.input[l.readPosition]; b < utf8.RuneSelf {
+		l.ch = rune(b)
+		l.readPosition += 1
+		return
+	}
+	r, size := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = r
+	l.readPosition += size
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	if b := l.input[l.readPosition]; b < utf8.RuneSelf {
+		return rune(b)
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 func (l *Lexer) NextToken() token.Token {
+	if len(l.peeked) > 0 {
+		tok := l.peeked[0]
+		l.peeked = l.peeked[1:]
+		return tok
+	}
+	return l.next()
+}
+
+// Peek returns the token n positions ahead without consuming it; Peek(1) is
+// the token the next call to NextToken returns. Past the end of the input it
+// returns EOF.
+func (l *Lexer) Peek(n int) token.Token {
+	if n < 1 {
+		panic("lexer: Peek needs n >= 1")
+	}
+	for len(l.peeked) < n {
+		l.peeked = append(l.peeked, l.next())
+	}
+	return l.peeked[n-1]
+}
+
+// Checkpoint is a saved lexer state returned by Mark.
+type Checkpoint struct {
+	state Lexer
+}
+
+// Mark saves the lexer's state so that Rollback can return to it. Every
+// checkpoint must be passed to exactly one of Rollback or Release; until
+// then a lexer created by NewReader keeps all input read since the mark.
+func (l *Lexer) Mark() Checkpoint {
+	cp := Checkpoint{state: *l}
+	cp.state.interpolations = append([]interpolation(nil), l.interpolations...)
+	cp.state.peeked = append([]token.Token(nil), l.peeked...)
+	l.marks++
+	return cp
+}
+
+// Rollback returns the lexer to the state saved by cp, dropping the tokens
+// and diagnostics produced since, and releases cp.
+func (l *Lexer) Rollback(cp Checkpoint) {
+	// The window only grows while a mark is held, so the current one still
+	// covers everything cp refers to.
+	input, reader, err, marks := l.input, l.reader, l.err, l.marks
+	diagnostics := l.diagnostics[:len(cp.state.diagnostics)]
+
+	*l = cp.state
+	l.input, l.reader, l.err, l.marks = input, reader, err, marks
+	l.diagnostics = diagnostics
+	l.interpolations = append([]interpolation(nil), cp.state.interpolations...)
+	l.peeked = append([]token.Token(nil), cp.state.peeked...)
+	l.Release(cp)
+}
+
+// Release discards cp without rolling back to it.
+func (l *Lexer) Release(cp Checkpoint) {
+	if l.marks > 0 {
+		l.marks--
+	}
+}
+
+func (l *Lexer) next() token.Token {
+	if l.buf != nil {
+		l.discard()
+	}
+
+	if l.resumeString {
+		l.resumeString = false
+		line, column, offset := l.line, l.column, l.base+l.position
+		tok := l.finishString(token.STRING_END)
+		tok.File, tok.Line, tok.Column, tok.Offset = l.file, line, column, offset
+		l.setEnd(&tok)
+		return tok
+	}
+
+	if l.trivia {
+		if tok, ok := l.scanTrivia(); ok {
+			l.setEnd(&tok)
+			if tok.Type == token.ILLEGAL {
+				l.reportUnterminatedComment(tok)
+			}
+			return tok
+		}
+	} else if tok, ok := l.skipSpaces(); !ok {
+		l.setEnd(&tok)
+		l.reportUnterminatedComment(tok)
+		return tok
+	}
+
+	line, column, offset := l.line, l.column, l.base+l.position
+	tok := l.scanToken()
+	tok.File, tok.Line, tok.Column, tok.Offset = l.file, line, column, offset
+	l.setEnd(&tok)
+	return tok
+}
+
+// setEnd records the current position as the end of tok, which has just
+// been scanned. EOF is empty, ending where it starts.
+func (l *Lexer) setEnd(tok *token.Token) {
+	if tok.Type == token.EOF {
+		tok.EndLine, tok.EndColumn, tok.EndOffset = tok.Line, tok.Column, tok.Offset
+		return
+	}
+	tok.EndLine, tok.EndColumn, tok.EndOffset = l.line, l.column, l.base+l.position
+}
+
+// reportUnterminatedComment reports the comment once, as the ILLEGAL token
+// spanning the rest of the input; the next call to NextToken returns EOF.
+func (l *Lexer) reportUnterminatedComment(tok token.Token) {
+	start := diag.Position{File: tok.File, Line: tok.Line, Column: tok.Column, Offset: tok.Offset}
+	l.report(diag.UnterminatedComment, nil, start, l.pos())
+}
+
+func (l *Lexer) scanToken() token.Token {
 	var tok token.Token
 
-	l.skipSpaces()
+	if l.interpolationNext {
+		l.interpolationNext = false
+		l.readChar()
+		l.readChar()
+		l.interpolations = append(l.interpolations, interpolation{stringStart: l.stringStart})
+		return token.Token{Type: token.INTERP_START, Literal: "${"}
+	}
+	if n := len(l.interpolations); n > 0 {
+		switch {
+		case l.ch == '{':
+			l.interpolations[n-1].braces++
+		case l.ch == '}' && l.interpolations[n-1].braces > 0:
+			l.interpolations[n-1].braces--
+		case l.ch == '}':
+			l.stringStart = l.interpolations[n-1].stringStart
+			l.interpolations = l.interpolations[:n-1]
+			l.readChar()
+			l.resumeString = true
+			return token.Token{Type: token.INTERP_END, Literal: "}"}
+		}
+	}
+
+	if tok, ok := l.scanOperator(); ok {
+		return tok
+	}
 
 	switch l.ch {
-	case '=':
-		if l.peekChar() == '=' {
-			tok.Literal = l.input[l.position : l.readPosition+1]
-			tok.Type = token.EQ
-			l.readChar()
-		} else {
-			tok = newToken(token.ASSIGN, l.ch)
-		}
-	case '+':
-		tok = newToken(token.PLUS, l.ch)
-	case '-':
-		tok = newToken(token.MINUS, l.ch)
-	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
-	case '/':
-		tok = newToken(token.SLASH, l.ch)
-	case '!':
-		if l.peekChar() == '=' {
-			tok.Literal = l.input[l.position : l.readPosition+1]
-			tok.Type = token.NOT_EQ
-			l.readChar()
-		} else {
-			tok = newToken(token.BANG, l.ch)
-		}
-	case '>':
-		tok = newToken(token.GT, l.ch)
-	case '<':
-		tok = newToken(token.LT, l.ch)
-	case '(':
-		tok = newToken(token.LPAREN, l.ch)
-	case ')':
-		tok = newToken(token.RPAREN, l.ch)
-	case '{':
-		tok = newToken(token.LBRACE, l.ch)
-	case '}':
-		tok = newToken(token.RBRACE, l.ch)
-	case '[':
-		tok = newToken(token.LBRACKET, l.ch)
-	case ']':
-		tok = newToken(token.RBRACKET, l.ch)
-	case ',':
-		tok = newToken(token.COMMA, l.ch)
-	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
-	case ':':
-		tok = newToken(token.COLON, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		l.stringStart = l.pos()
+		if strings.HasPrefix(l.input[l.position:], `"""`) {
+			tok.Literal, tok.Type = l.readRawString()
+			return tok
+		}
+		l.readChar()
+		return l.finishString(token.STRING)
+	case '\'':
+		tok.Literal, tok.Type = l.readCharLiteral()
 	case 0:
 		tok.Type = token.EOF
 		tok.Literal = ""
 	default:
 		if isChar(l.ch) {
-			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Type, tok.Literal = l.keywords.Lookup(l.readIdentifier())
 			return tok
 		} else if isNum(l.ch) {
-			tok.Literal = l.readInt()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			tok = l.illegalToken()
 		}
 	}
 
@@ -111,49 +336,513 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch)}
+// operators lists every operator and delimiter. scanOperator picks the
+// longest one matching the input, so adding "+=" next to "+" needs only a new
+// entry here.
+type operator struct {
+	literal   string
+	tokenType token.TokenType
+}
+
+var operators = []operator{
+	{"...", token.ELLIPSIS},
+	{"..=", token.RANGE_EQ},
+	{"..", token.RANGE},
+	{"==", token.EQ},
+	{"!=", token.NOT_EQ},
+	{"<=", token.LT_EQ},
+	{">=", token.GT_EQ},
+	{"&&", token.AND},
+	{"||", token.OR},
+	{"<<", token.SHL},
+	{">>", token.SHR},
+	{"|>", token.PIPE},
+	{"++", token.INCREMENT},
+	{"--", token.DECREMENT},
+
+	{"=", token.ASSIGN},
+	{"+", token.PLUS},
+	{"-", token.MINUS},
+	{"!", token.BANG},
+	{"*", token.ASTERISK},
+	{"/", token.SLASH},
+	{"%", token.PERCENT},
+	{"<", token.LT},
+	{">", token.GT},
+	{"&", token.BIT_AND},
+	{"|", token.BIT_OR},
+	{"^", token.BIT_XOR},
+	{"~", token.BIT_NOT},
+
+	{",", token.COMMA},
+	{";", token.SEMICOLON},
+	{":", token.COLON},
+	{".", token.DOT},
+	{"?", token.QUESTION},
+	{"(", token.LPAREN},
+	{")", token.RPAREN},
+	{"{", token.LBRACE},
+	{"}", token.RBRACE},
+	{"[", token.LBRACKET},
+	{"]", token.RBRACKET},
+}
+
+// operatorsByByte groups operators by their first byte, longest first.
+var operatorsByByte [utf8.RuneSelf][]operator
+
+func init() {
+	for _, op := range operators {
+		operatorsByByte[op.literal[0]] = append(operatorsByByte[op.literal[0]], op)
+	}
+	for _, candidates := range operatorsByByte {
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return len(candidates[a].literal) > len(candidates[b].literal)
+		})
+	}
+}
+
+func (l *Lexer) scanOperator() (token.Token, bool) {
+	if l.ch >= utf8.RuneSelf || len(operatorsByByte[l.ch]) == 0 {
+		return token.Token{}, false
+	}
+	rest := l.input[l.position:]
+	for _, op := range operatorsByByte[l.ch] {
+		if !strings.HasPrefix(rest, op.literal) {
+			continue
+		}
+		if (op.tokenType == token.INCREMENT || op.tokenType == token.DECREMENT) && l.operandAfter(len(op.literal)) {
+			// ++ and -- are only postfix, so before an operand they are
+			// two operators: 1--1 and --x stay 1 - -1 and -(-x).
+			continue
+		}
+		tok := token.Token{Type: op.tokenType, Literal: op.literal}
+		for range op.literal {
+			l.readChar()
+		}
+		return tok, true
+	}
+	return token.Token{}, false
+}
+
+// operandAfter reports whether the first character after the next n bytes,
+// skipping spaces and tabs but not newlines, can start an operand.
+func (l *Lexer) operandAfter(n int) bool {
+	for i := l.position + n; ; i++ {
+		if l.reader != nil {
+			l.fill(i + utf8.UTFMax)
+		}
+		if i >= len(l.input) {
+			return false
+		}
+		if c := l.input[i]; c == ' ' || c == '\t' {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(l.input[i:])
+		return isChar(r) || isNum(r) || strings.ContainsRune(`([!~"'`, r)
+	}
+}
+
+// SetTrivia controls whether NextToken returns whitespace and comments as
+// WHITESPACE and COMMENT tokens instead of skipping them, for tools such as
+// formatters and highlighters that need the full source.
+func (l *Lexer) SetTrivia(on bool) {
+	l.trivia = on
+}
+
+// SetKeywords makes words registered in k lex as keywords, on top of the
+// language's own. It applies to tokens not yet read; nil removes them.
+func (l *Lexer) SetKeywords(k *token.Keywords) {
+	l.keywords = k
+}
+
+// Tokens lexes the rest of the input and returns its tokens, ending with
+// EOF.
+func (l *Lexer) Tokens() []token.Token {
+	var toks []token.Token
+	for {
+		tok := l.NextToken()
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			return toks
+		}
+	}
+}
+
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diagnostics
+}
+
+// illegalToken reports the current character and returns it as an ILLEGAL
+// token. Lexing continues with the next character, so one pass reports
+// every lexical error.
+func (l *Lexer) illegalToken() token.Token {
+	tok := l.newToken(token.ILLEGAL)
+	l.report(diag.IllegalCharacter, diag.Data{
+		"char": strconv.Quote(tok.Literal),
+		"rune": fmt.Sprintf("U+%04X", l.ch),
+	}, l.pos(), l.after())
+	return tok
+}
+
+func (l *Lexer) report(code diag.Code, data diag.Data, start, end diag.Position) {
+	d := diag.New(code, data)
+	d.Range = diag.Range{Start: start, End: end}
+	l.diagnostics = append(l.diagnostics, d)
+}
+
+// pos is the position of the current character.
+func (l *Lexer) pos() diag.Position {
+	return diag.Position{File: l.file, Line: l.line, Column: l.column, Offset: l.base + l.position}
+}
+
+// after is the position just past the current character.
+func (l *Lexer) after() diag.Position {
+	return diag.Position{File: l.file, Line: l.line, Column: l.column + 1, Offset: l.base + l.readPosition}
+}
+
+func (l *Lexer) newToken(tokenType token.TokenType) token.Token {
+	return token.Token{Type: tokenType, Literal: l.input[l.position:l.readPosition]}
 }
 
 func (l *Lexer) readIdentifier() string {
 	start := l.position
-	for isChar(l.ch) {
+	for isChar(l.ch) || isNum(l.ch) {
 		l.readChar()
 	}
 	return l.input[start:l.position]
 }
 
-func (l *Lexer) readInt() string {
-	start := l.position
-	for isNum(l.ch) {
+// readNumber reads an integer or, if the digits are followed by a '.' and
+// at least one more digit, a decimal float. "1." and "1.foo" stay INT so the
+// '.' lexes as a DOT, and "1..2" is two INTs around a RANGE.
+// Integers may carry a 0x, 0o or 0b prefix; the literal keeps it and the
+// parser converts it with the matching base. Underscores between digits, as
+// in 1_000_000, are dropped from the literal; misplaced ones make the whole
+// number ILLEGAL.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	start, startPos := l.position, l.pos()
+	tokenType := token.TokenType(token.INT)
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
+		l.readChar()
+		// Consume every alphanumeric so that "0b102" or "0xZZ" is a single
+		// literal the parser rejects, rather than an INT followed by an IDENT.
+		for isChar(l.ch) || isNum(l.ch) {
+			l.readChar()
+		}
+	} else {
+		l.readDigits()
+		if l.ch == '.' && isNum(l.peekChar()) {
+			tokenType = token.FLOAT
+			l.readChar()
+			l.readDigits()
+		}
+	}
+
+	literal := l.input[start:l.position]
+	if !strings.Contains(literal, "_") {
+		return literal, tokenType
+	}
+	if !validSeparators(literal) {
+		l.report(diag.InvalidNumber, diag.Data{"literal": literal}, startPos, l.pos())
+		return literal, token.ILLEGAL
+	}
+	return strings.ReplaceAll(literal, "_", ""), tokenType
+}
+
+func (l *Lexer) readDigits() {
+	for isNum(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
-	return l.input[start:l.position]
 }
 
-func isNum(ch byte) bool {
+// validSeparators reports whether every underscore in the number literal
+// sits between two digits. The base prefix counts as a digit, so 0x_FF is
+// accepted.
+func validSeparators(literal string) bool {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(literal)-1 || !isAlnum(literal[i-1]) || !isAlnum(literal[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(b byte) bool {
+	return '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
+}
+
+func isNum(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isChar(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+// isChar reports whether ch can start an identifier: an ASCII letter, an
+// underscore or any Unicode letter.
+func isChar(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+	}
+	return unicode.IsLetter(ch)
 }
 
-func (l *Lexer) skipSpaces() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// skipSpaces skips whitespace, // comments, which run to the end of the
+// line, and /* */ comments, which may nest. If a block comment is still open
+// at the end of the input it returns false and an ILLEGAL token holding it.
+func (l *Lexer) skipSpaces() (token.Token, bool) {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+		case l.ch == '/' && l.peekChar() == '*':
+			start := l.position
+			tok := token.Token{Type: token.ILLEGAL, File: l.file, Line: l.line, Column: l.column, Offset: l.base + start}
+			if !l.skipBlockComment() {
+				tok.Literal = l.input[start:]
+				return tok, false
+			}
+		default:
+			return token.Token{}, true
+		}
+	}
+}
+
+// scanTrivia is skipSpaces for a lexer with trivia enabled: it returns a run
+// of whitespace or a single comment as a token, or false if the input does
+// not start with either. An unterminated block comment is an ILLEGAL token.
+func (l *Lexer) scanTrivia() (token.Token, bool) {
+	start := l.position
+	tok := token.Token{File: l.file, Line: l.line, Column: l.column, Offset: l.base + start}
+	switch {
+	case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+		tok.Type = token.WHITESPACE
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			l.readChar()
+		}
+	case l.ch == '/' && l.peekChar() == '/':
+		tok.Type = token.COMMENT
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	case l.ch == '/' && l.peekChar() == '*':
+		tok.Type = token.COMMENT
+		if !l.skipBlockComment() {
+			tok.Type = token.ILLEGAL
+			tok.Literal = l.input[start:]
+			return tok, true
+		}
+	default:
+		return token.Token{}, false
+	}
+	tok.Literal = l.input[start:l.position]
+	return tok, true
+}
+
+func (l *Lexer) skipBlockComment() bool {
+	depth := 0
+	for l.ch != 0 {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return true
+			}
+		}
 		l.readChar()
 	}
+	return false
 }
 
-func (l *Lexer) readString() string {
-	l.readChar()
+// finishString reads the rest of a string literal from the current
+// position. It returns a STRING_SEGMENT, leaving the lexer on the "${", if
+// an interpolation interrupts the string, and a token of type final after
+// consuming the closing quote otherwise.
+// A string still open at the end of the input is reported from its opening
+// quote and becomes an ILLEGAL token holding the rest of the input.
+func (l *Lexer) finishString(final token.TokenType) token.Token {
 	start := l.position
-	for {
+	literal, tokenType := l.readString(final)
+	if l.ch == 0 {
+		l.report(diag.UnterminatedString, diag.Data{"quote": `"`}, l.stringStart, l.pos())
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[start:l.position]}
+	}
+	tok := token.Token{Type: tokenType, Literal: literal}
+	if l.atInterpolation() {
+		l.interpolationNext = true
+	} else {
+		l.readChar()
+	}
+	return tok
+}
+
+// readRawString reads a triple-quoted string. Its content is taken verbatim,
+// without escapes or interpolation, except that a line break directly after
+// the opening quotes is dropped so the text can start on its own line.
+func (l *Lexer) readRawString() (string, token.TokenType) {
+	for i := 0; i < 3; i++ {
+		l.readChar()
+	}
+	if l.ch == '\r' && l.peekChar() == '\n' {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+	}
+	if l.ch == '\n' {
+		l.readChar()
+	}
+
+	start := l.position
+	for l.ch != 0 && !strings.HasPrefix(l.input[l.position:], `"""`) {
+		l.readChar()
+	}
+	literal := l.input[start:l.position]
+	if l.ch == 0 {
+		l.report(diag.UnterminatedString, diag.Data{"quote": `"""`}, l.stringStart, l.pos())
+		return literal, token.ILLEGAL
+	}
+	for i := 0; i < 3; i++ {
+		l.readChar()
+	}
+	return literal, token.STRING
+}
+
+// atInterpolation reports whether the lexer is on a "${".
+func (l *Lexer) atInterpolation() bool {
+	return l.ch == '$' && l.peekChar() == '{'
+}
+
+// readString reads string content up to the closing quote or a "${" and
+// decodes the escapes \n, \t, \r, \", \\, \$ and \uXXXX. Content without
+// escapes is sliced from the input rather than copied. An invalid escape is
+// reported and the whole literal becomes an ILLEGAL token.
+func (l *Lexer) readString(final token.TokenType) (string, token.TokenType) {
+	start := l.position
+	for l.ch != '"' && l.ch != '\\' && l.ch != 0 && !l.atInterpolation() {
+		l.readChar()
+	}
+	if l.ch != '\\' {
+		return l.input[start:l.position], l.stringType(final)
+	}
+
+	var b strings.Builder
+	b.WriteString(l.input[start:l.position])
+	valid := true
+	for l.ch != '"' && l.ch != 0 && !l.atInterpolation() {
+		if l.ch != '\\' {
+			b.WriteRune(l.ch)
+			l.readChar()
+			continue
+		}
+		if !l.readEscape(&b) {
+			valid = false
 		}
 	}
+	if !valid {
+		return l.input[start:l.position], token.ILLEGAL
+	}
+	return b.String(), l.stringType(final)
+}
 
-	return l.input[start:l.position]
+func (l *Lexer) stringType(final token.TokenType) token.TokenType {
+	if l.atInterpolation() {
+		return token.STRING_SEGMENT
+	}
+	return final
+}
+
+// readCharLiteral reads a single-quoted character such as 'a' or '\n',
+// leaving the lexer on the closing quote. Empty, multi-character and
+// unterminated literals become ILLEGAL tokens.
+func (l *Lexer) readCharLiteral() (string, token.TokenType) {
+	start, startPos := l.position, l.pos()
+	l.readChar()
+
+	var b strings.Builder
+	valid, reported := true, false
+	switch l.ch {
+	case '\\':
+		valid = l.readEscape(&b)
+		reported = !valid
+	case '\'', '\n', 0:
+		valid = false
+	default:
+		b.WriteRune(l.ch)
+		l.readChar()
+	}
+	if valid && l.ch == '\'' && utf8.RuneCountInString(b.String()) == 1 {
+		return b.String(), token.CHAR
+	}
+
+	for l.ch != '\'' && l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	end, endPos := l.position, l.pos()
+	if l.ch == '\'' {
+		end, endPos = l.readPosition, l.after()
+	}
+	literal := l.input[start:end]
+	if !reported {
+		l.report(diag.InvalidCharLiteral, diag.Data{"literal": literal}, startPos, endPos)
+	}
+	return literal, token.ILLEGAL
+}
+
+// readEscape decodes the escape sequence starting at the current backslash,
+// leaving the lexer on the character after it.
+func (l *Lexer) readEscape(b *strings.Builder) bool {
+	start, startPos := l.position, l.pos()
+	l.readChar()
+	switch l.ch {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\'':
+		b.WriteByte('\'')
+	case '\\':
+		b.WriteByte('\\')
+	case '$':
+		b.WriteByte('$')
+	case 'u':
+		end := l.readPosition + 4
+		if end <= len(l.input) {
+			if r, err := strconv.ParseUint(l.input[l.readPosition:end], 16, 32); err == nil {
+				b.WriteRune(rune(r))
+				for l.position < end {
+					l.readChar()
+				}
+				return true
+			}
+		}
+		fallthrough
+	default:
+		if l.ch != 0 {
+			l.readChar()
+		}
+		l.report(diag.InvalidEscape, diag.Data{"escape": strconv.Quote(l.input[start:l.position])}, startPos, l.pos())
+		return false
+	}
+	l.readChar()
+	return true
 }
@@ -1,8 +1,14 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"simple-interpreter/diag"
 	"simple-interpreter/token"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unsafe"
 )
 
 func TestNextToken(t *testing.T) {
@@ -12,7 +18,7 @@ func TestNextToken(t *testing.T) {
 	x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5 % 2;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -21,10 +27,18 @@ func TestNextToken(t *testing.T) {
 	return false;
 	}	
 	== != =
+	<= >= < =
+	&& || & | ^ ~ << >> <<=
+	xs |> f ||>
+	obj.field.len()
+	i++; j-- - -k
+	a ? b : c
 	"foobar"
 	"foo bar"
 	[1, 2];
-	{"foo": "bar"}`
+	{"foo": "bar"}
+	sha256(x1)
+	x in arr; index`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -71,6 +85,8 @@ func TestNextToken(t *testing.T) {
 		{token.SLASH, "/"},
 		{token.ASTERISK, "*"},
 		{token.INT, "5"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
 		{token.SEMICOLON, ";"},
 		{token.INT, "5"},
 		{token.LT, "<"},
@@ -98,6 +114,45 @@ func TestNextToken(t *testing.T) {
 		{token.EQ, "=="},
 		{token.NOT_EQ, "!="},
 		{token.ASSIGN, "="},
+		{token.LT_EQ, "<="},
+		{token.GT_EQ, ">="},
+		{token.LT, "<"},
+		{token.ASSIGN, "="},
+		{token.AND, "&&"},
+		{token.OR, "||"},
+		{token.BIT_AND, "&"},
+		{token.BIT_OR, "|"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.SHL, "<<"},
+		{token.SHR, ">>"},
+		{token.SHL, "<<"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "xs"},
+		{token.PIPE, "|>"},
+		{token.IDENT, "f"},
+		{token.OR, "||"},
+		{token.GT, ">"},
+		{token.IDENT, "obj"},
+		{token.DOT, "."},
+		{token.IDENT, "field"},
+		{token.DOT, "."},
+		{token.IDENT, "len"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.IDENT, "i"},
+		{token.INCREMENT, "++"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "j"},
+		{token.DECREMENT, "--"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.IDENT, "k"},
+		{token.IDENT, "a"},
+		{token.QUESTION, "?"},
+		{token.IDENT, "b"},
+		{token.COLON, ":"},
+		{token.IDENT, "c"},
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
 		{token.LBRACKET, "["},
@@ -111,6 +166,15 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "sha256"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x1"},
+		{token.RPAREN, ")"},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "arr"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "index"},
 		{token.EOF, ""},
 	}
 
@@ -129,3 +193,845 @@ func TestNextToken(t *testing.T) {
 	}
 
 }
+
+func FuzzNextToken(f *testing.F) {
+	f.Add(`let five = 5; "foo" [1, 2] {"a": 1} == != !`)
+	f.Add(`"unterminated`)
+	f.Add(`""`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+		for i := 0; i <= len(input); i++ {
+			if l.NextToken().Type == token.EOF {
+				return
+			}
+		}
+		t.Fatalf("lexer did not reach EOF for %q", input)
+	})
+}
+
+const benchmarkProgram = `let fibonacci = fn(x) {
+	if (x < 2) { return x; } else { return fibonacci(x - 1) + fibonacci(x - 2); }
+};
+let config = {"name": "monkey", "sizes": [1, 2, 3], "enabled": true};
+let result = fibonacci(config["sizes"][2]) != 10 == !false;
+`
+
+func benchmarkLexer(b *testing.B, size int) {
+	input := strings.Repeat(benchmarkProgram, size/len(benchmarkProgram)+1)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func BenchmarkLexer1MB(b *testing.B) { benchmarkLexer(b, 1<<20) }
+func BenchmarkLexer8MB(b *testing.B) { benchmarkLexer(b, 8<<20) }
+
+func TestNextTokenAllocations(t *testing.T) {
+	input := strings.Repeat(benchmarkProgram, 10)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	})
+	if allocs > 1 {
+		t.Errorf("lexing allocated %.0f times, want at most 1", allocs)
+	}
+}
+
+func BenchmarkLexerReader(b *testing.B) {
+	input := strings.Repeat(benchmarkProgram, (1<<20)/len(benchmarkProgram)+1)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := NewReader(strings.NewReader(input))
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func TestInternedLiterals(t *testing.T) {
+	input := "let add = fn(a, b) { return a + b >= 0 && true; };"
+	start := uintptr(unsafe.Pointer(unsafe.StringData(input)))
+	end := start + uintptr(len(input))
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.IDENT || tok.Type == token.INT {
+			continue
+		}
+		p := uintptr(unsafe.Pointer(unsafe.StringData(tok.Literal)))
+		if start <= p && p < end {
+			t.Errorf("%s token %q points into the source, want an interned literal", tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLexicalDiagnosticRanges(t *testing.T) {
+	input := "let a = 1 @ 2;\nlet € = \"bad \\q\";\n'ab' /* open"
+	l := New(input)
+	for l.NextToken().Type != token.EOF {
+	}
+
+	expected := []struct {
+		code       diag.Code
+		start, end diag.Position
+		rune       string
+	}{
+		{diag.IllegalCharacter, diag.Position{Line: 1, Column: 11, Offset: 10}, diag.Position{Line: 1, Column: 12, Offset: 11}, "U+0040"},
+		{diag.IllegalCharacter, diag.Position{Line: 2, Column: 5, Offset: 19}, diag.Position{Line: 2, Column: 6, Offset: 22}, "U+20AC"},
+		{diag.InvalidEscape, diag.Position{Line: 2, Column: 14, Offset: 30}, diag.Position{Line: 2, Column: 16, Offset: 32}, ""},
+		{diag.InvalidCharLiteral, diag.Position{Line: 3, Column: 1, Offset: 35}, diag.Position{Line: 3, Column: 5, Offset: 39}, ""},
+		{diag.UnterminatedComment, diag.Position{Line: 3, Column: 6, Offset: 40}, diag.Position{Line: 3, Column: 13, Offset: 47}, ""},
+	}
+
+	diags := l.Diagnostics()
+	if len(diags) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(expected), len(diags), diags)
+	}
+	for i, want := range expected {
+		d := diags[i]
+		if d.Code != want.code || d.Range.Start != want.start || d.Range.End != want.end {
+			t.Errorf("diagnostic %d = %s %+v, want %s %+v-%+v", i, d.Code, d.Range, want.code, want.start, want.end)
+		}
+		if want.rune != "" && d.Data["rune"] != want.rune {
+			t.Errorf("diagnostic %d rune = %v, want %s", i, d.Data["rune"], want.rune)
+		}
+	}
+}
+
+func TestUnterminatedStrings(t *testing.T) {
+	tests := []struct {
+		input   string
+		literal string
+		start   diag.Position
+		quote   string
+	}{
+		{"let s = \"abc\ndef", "abc\ndef", diag.Position{Line: 1, Column: 9, Offset: 8}, `"`},
+		{"x; \"\"\"\nraw", "raw", diag.Position{Line: 1, Column: 4, Offset: 3}, `"""`},
+		{`"outer ${"inner" + 1} tail`, " tail", diag.Position{Line: 1, Column: 1, Offset: 0}, `"`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		var last token.Token
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			last = tok
+		}
+		if last.Type != token.ILLEGAL || last.Literal != tt.literal {
+			t.Errorf("%q: last token = %+v, want ILLEGAL %q", tt.input, last, tt.literal)
+		}
+
+		diags := l.Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got %v", tt.input, diags)
+			continue
+		}
+		d := diags[0]
+		if d.Code != diag.UnterminatedString || d.Range.Start != tt.start || d.Range.End.Offset != len(tt.input) {
+			t.Errorf("%q: diagnostic = %s %+v, want %s from %+v to the end", tt.input, d.Code, d.Range, diag.UnterminatedString, tt.start)
+		}
+		if d.Data["quote"] != tt.quote {
+			t.Errorf("%q: quote = %v, want %s", tt.input, d.Data["quote"], tt.quote)
+		}
+	}
+}
+
+func TestIllegalCharacterDiagnostics(t *testing.T) {
+	l := New("let a = 1 @ 2 # 3;")
+	for l.NextToken().Type != token.EOF {
+	}
+
+	diags := l.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(diags), diags)
+	}
+	for i, want := range []string{`illegal character "@"`, `illegal character "#"`} {
+		if diags[i].Code != diag.IllegalCharacter || diags[i].Message != want {
+			t.Errorf("diagnostic %d = %s %q, want %s %q", i, diags[i].Code, diags[i].Message, diag.IllegalCharacter, want)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"3.14", []token.Token{{Type: token.FLOAT, Literal: "3.14"}}},
+		{"42", []token.Token{{Type: token.INT, Literal: "42"}}},
+		{"0xFF 0o755 0b1010", []token.Token{
+			{Type: token.INT, Literal: "0xFF"},
+			{Type: token.INT, Literal: "0o755"},
+			{Type: token.INT, Literal: "0b1010"},
+		}},
+		{"0b102", []token.Token{{Type: token.INT, Literal: "0b102"}}},
+		{"0.5 + 10", []token.Token{
+			{Type: token.FLOAT, Literal: "0.5"},
+			{Type: token.PLUS, Literal: "+"},
+			{Type: token.INT, Literal: "10"},
+		}},
+		{"1.", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.DOT, Literal: "."},
+		}},
+		{"1.2.3", []token.Token{
+			{Type: token.FLOAT, Literal: "1.2"},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.INT, Literal: "3"},
+		}},
+		{"1.foo", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.IDENT, Literal: "foo"},
+		}},
+		{"1..2 1..=2", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.RANGE, Literal: ".."},
+			{Type: token.INT, Literal: "2"},
+			{Type: token.INT, Literal: "1"},
+			{Type: token.RANGE_EQ, Literal: "..="},
+			{Type: token.INT, Literal: "2"},
+		}},
+		{"f(...xs) ....", []token.Token{
+			{Type: token.IDENT, Literal: "f"},
+			{Type: token.LPAREN, Literal: "("},
+			{Type: token.ELLIPSIS, Literal: "..."},
+			{Type: token.IDENT, Literal: "xs"},
+			{Type: token.RPAREN, Literal: ")"},
+			{Type: token.ELLIPSIS, Literal: "..."},
+			{Type: token.DOT, Literal: "."},
+		}},
+		{"1_000_000 3_141.5_9 0xFF_FF 0b_1010", []token.Token{
+			{Type: token.INT, Literal: "1000000"},
+			{Type: token.FLOAT, Literal: "3141.59"},
+			{Type: token.INT, Literal: "0xFFFF"},
+			{Type: token.INT, Literal: "0b1010"},
+		}},
+		{"1_ 1__0 1_.5 0x_", []token.Token{
+			{Type: token.ILLEGAL, Literal: "1_"},
+			{Type: token.ILLEGAL, Literal: "1__0"},
+			{Type: token.ILLEGAL, Literal: "1_.5"},
+			{Type: token.ILLEGAL, Literal: "0x_"},
+		}},
+		{"_1", []token.Token{{Type: token.IDENT, Literal: "_1"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range append(tt.expected, token.Token{Type: token.EOF}) {
+			if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+				t.Errorf("%q: token %d = %+v, want %+v", tt.input, i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb\tc\r"`, "a\nb\tc\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"été ☺"`, "été ☺"},
+		{`"caf\u00e9 \u263A!"`, "café ☺!"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Errorf("%s: got %s %q, want STRING %q", tt.input, tok.Type, tok.Literal, tt.expected)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s: expected EOF after string, got %+v", tt.input, next)
+		}
+		if len(l.Diagnostics()) != 0 {
+			t.Errorf("%s: unexpected diagnostics %v", tt.input, l.Diagnostics())
+		}
+	}
+}
+
+func TestInvalidStringEscapes(t *testing.T) {
+	tests := []struct {
+		input   string
+		escapes []string
+	}{
+		{`"bad \q escape"`, []string{`"\\q"`}},
+		{`"\u12"`, []string{`"\\u"`}},
+		{`"\x \y"`, []string{`"\\x"`, `"\\y"`}},
+	}
+	for _, tt := range tests {
+		l := New(tt.input + ";")
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("%s: got %s, want ILLEGAL", tt.input, tok.Type)
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Errorf("%s: lexer did not resume after the string, got %+v", tt.input, next)
+		}
+
+		diags := l.Diagnostics()
+		if len(diags) != len(tt.escapes) {
+			t.Fatalf("%s: expected %d diagnostics, got %v", tt.input, len(tt.escapes), diags)
+		}
+		for i, escape := range tt.escapes {
+			want := "invalid escape sequence " + escape + " in string literal"
+			if diags[i].Code != diag.InvalidEscape || diags[i].Message != want {
+				t.Errorf("%s: diagnostic %d = %s %q, want %q", tt.input, i, diags[i].Code, diags[i].Message, want)
+			}
+		}
+	}
+}
+
+func TestLineComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"// only a comment", nil},
+		{"let x = 1; // trailing", []token.Token{
+			{Type: token.LET, Literal: "let"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.ASSIGN, Literal: "="},
+			{Type: token.INT, Literal: "1"},
+			{Type: token.SEMICOLON, Literal: ";"},
+		}},
+		{"// first\n// second\r\nx", []token.Token{{Type: token.IDENT, Literal: "x"}}},
+		{"a // between\n+ b", []token.Token{
+			{Type: token.IDENT, Literal: "a"},
+			{Type: token.PLUS, Literal: "+"},
+			{Type: token.IDENT, Literal: "b"},
+		}},
+		{"4 / 2 //", []token.Token{
+			{Type: token.INT, Literal: "4"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INT, Literal: "2"},
+		}},
+		{`"// not a comment"`, []token.Token{{Type: token.STRING, Literal: "// not a comment"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range append(tt.expected, token.Token{Type: token.EOF}) {
+			if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+				t.Errorf("%q: token %d = %+v, want %+v", tt.input, i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"/* only a comment */", nil},
+		{"a /* one\ntwo */ b", []token.Token{
+			{Type: token.IDENT, Literal: "a"},
+			{Type: token.IDENT, Literal: "b"},
+		}},
+		{"/* outer /* inner */ still outer */ x", []token.Token{{Type: token.IDENT, Literal: "x"}}},
+		{"/**/1/***/", []token.Token{{Type: token.INT, Literal: "1"}}},
+		{"2 */ 3", []token.Token{
+			{Type: token.INT, Literal: "2"},
+			{Type: token.ASTERISK, Literal: "*"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INT, Literal: "3"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range append(tt.expected, token.Token{Type: token.EOF}) {
+			if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+				t.Errorf("%q: token %d = %+v, want %+v", tt.input, i, got, want)
+				break
+			}
+		}
+		if len(l.Diagnostics()) != 0 {
+			t.Errorf("%q: unexpected diagnostics %v", tt.input, l.Diagnostics())
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	for _, input := range []string{"x /* open", "/* a /* b */ still open", "/*/"} {
+		l := New(input)
+		tok := l.NextToken()
+		if tok.Type == token.IDENT {
+			tok = l.NextToken()
+		}
+		if tok.Type != token.ILLEGAL || !strings.HasPrefix(tok.Literal, "/*") {
+			t.Errorf("%q: got %+v, want an ILLEGAL token for the comment", input, tok)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%q: expected EOF after the comment, got %+v", input, next)
+		}
+
+		diags := l.Diagnostics()
+		if len(diags) != 1 || diags[0].Code != diag.UnterminatedComment {
+			t.Errorf("%q: expected one %s diagnostic, got %v", input, diag.UnterminatedComment, diags)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let café = "naïve ☺"; π + 日本語_1; a€b`
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "café"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.STRING, Literal: "naïve ☺"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "π"},
+		{Type: token.PLUS, Literal: "+"},
+		{Type: token.IDENT, Literal: "日本語_1"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.ILLEGAL, Literal: "€"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.EOF},
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+			t.Fatalf("token %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	diags := l.Diagnostics()
+	if len(diags) != 1 || diags[0].Message != `illegal character "€"` {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("x\xffy")
+	for i, want := range []token.Token{
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ILLEGAL, Literal: "\xff"},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.EOF},
+	} {
+		if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+			t.Fatalf("token %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  π + \"é\";\r\n/* a\nb */ y"
+
+	expected := []struct {
+		literal              string
+		line, column, offset int
+	}{
+		{"let", 1, 1, 0},
+		{"x", 1, 5, 4},
+		{"=", 1, 7, 6},
+		{"5", 1, 9, 8},
+		{";", 1, 10, 9},
+		{"π", 2, 3, 13},
+		{"+", 2, 5, 16},
+		{"é", 2, 7, 18},
+		{";", 2, 10, 22},
+		{"y", 4, 6, 35},
+		{"", 4, 7, 36},
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Literal != want.literal || tok.Line != want.line || tok.Column != want.column || tok.Offset != want.offset {
+			t.Errorf("token %d = %q at %d:%d (offset %d), want %q at %d:%d (offset %d)", i,
+				tok.Literal, tok.Line, tok.Column, tok.Offset, want.literal, want.line, want.column, want.offset)
+		}
+	}
+}
+
+func TestTokenEnds(t *testing.T) {
+	input := "abc \"a\\tb\" \"\"\"x\ny\"\"\" ..= 'é'"
+
+	expected := []struct {
+		literal         string
+		line, column    int
+		endLine, endCol int
+	}{
+		{"abc", 1, 1, 1, 4},
+		{"a\tb", 1, 5, 1, 11},
+		{"x\ny", 1, 12, 2, 5},
+		{"..=", 2, 6, 2, 9},
+		{"é", 2, 10, 2, 13},
+		{"", 2, 13, 2, 13},
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		pos, end := tok.Pos(), tok.End()
+		if tok.Literal != want.literal || pos.Line != want.line || pos.Column != want.column || end.Line != want.endLine || end.Column != want.endCol {
+			t.Errorf("token %d = %q at %d:%d-%d:%d, want %q at %d:%d-%d:%d", i, tok.Literal,
+				pos.Line, pos.Column, end.Line, end.Column, want.literal, want.line, want.column, want.endLine, want.endCol)
+		}
+		if end.Offset > len(input) || (tok.Type != token.EOF && end.Offset <= pos.Offset) {
+			t.Errorf("token %d has bad end offset %d", i, end.Offset)
+		}
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	l := NewFile("main.mk", "let x = 1 @ 2;")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.File != "main.mk" {
+			t.Errorf("token %q has file %q, want %q", tok.Literal, tok.File, "main.mk")
+		}
+	}
+
+	diags := l.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diags))
+	}
+	if r := diags[0].Range; r.Start.File != "main.mk" || r.End.File != "main.mk" {
+		t.Errorf("diagnostic range %+v does not name main.mk", r)
+	}
+
+	if tok := New("x").NextToken(); tok.File != "" {
+		t.Errorf("anonymous source gave file %q", tok.File)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	input := "let x = 1; // one\n/* two */ x"
+
+	var got []string
+	for _, tok := range New(input).Tokens() {
+		got = append(got, string(tok.Type)+" "+tok.Literal)
+	}
+	want := []string{"LET let", "IDENT x", "= =", "INT 1", "; ;", "IDENT x", "EOF "}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Tokens() = %q, want %q", got, want)
+	}
+
+	l := New(input)
+	l.SetTrivia(true)
+	toks := l.Tokens()
+	var b strings.Builder
+	got = got[:0]
+	for _, tok := range toks {
+		b.WriteString(tok.Literal)
+		got = append(got, string(tok.Type))
+	}
+	want = []string{"LET", "WHITESPACE", "IDENT", "WHITESPACE", "=", "WHITESPACE", "INT", ";",
+		"WHITESPACE", "COMMENT", "WHITESPACE", "COMMENT", "WHITESPACE", "IDENT", "EOF"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Tokens() with trivia = %q, want %q", got, want)
+	}
+	if b.String() != input {
+		t.Errorf("trivia literals rebuild %q, want %q", b.String(), input)
+	}
+	if c := toks[11]; c.Literal != "/* two */" || c.Line != 2 || c.Column != 1 || c.Offset != 18 {
+		t.Errorf("block comment token = %+v", c)
+	}
+
+	l = New("x /* open")
+	l.SetTrivia(true)
+	toks = l.Tokens()
+	if last := toks[len(toks)-2]; last.Type != token.ILLEGAL || last.Literal != "/* open" {
+		t.Errorf("unterminated comment token = %+v", last)
+	}
+	if len(l.Diagnostics()) != 1 || l.Diagnostics()[0].Code != diag.UnterminatedComment {
+		t.Errorf("diagnostics = %v", l.Diagnostics())
+	}
+}
+
+func TestPeek(t *testing.T) {
+	l := New("(a, b) => a")
+	if tok := l.Peek(5); tok.Type != token.RPAREN {
+		t.Fatalf("Peek(5) = %+v, want )", tok)
+	}
+	if tok := l.Peek(1); tok.Type != token.LPAREN {
+		t.Fatalf("Peek(1) = %+v, want (", tok)
+	}
+	if tok := l.Peek(20); tok.Type != token.EOF {
+		t.Errorf("Peek past the end = %+v, want EOF", tok)
+	}
+
+	var got []string
+	for _, tok := range l.Tokens() {
+		got = append(got, tok.Literal)
+	}
+	if want := "(|a|,|b|)|=|>|a|"; strings.Join(got, "|") != want {
+		t.Errorf("tokens after peeking = %q, want %q", strings.Join(got, "|"), want)
+	}
+}
+
+func TestMarkRollback(t *testing.T) {
+	input := strings.Repeat("x ", 3000) + "\"a ${b} c\" @ y"
+	sources := map[string]func() *Lexer{
+		"string": func() *Lexer { return New(input) },
+		"reader": func() *Lexer { return NewReader(iotest.HalfReader(strings.NewReader(input))) },
+	}
+
+	for name, newLexer := range sources {
+		l := newLexer()
+		for i := 0; i < 999; i++ {
+			l.NextToken()
+		}
+		l.Peek(2)
+
+		cp := l.Mark()
+		first := l.Tokens()
+		if len(l.Diagnostics()) != 1 {
+			t.Fatalf("%s: expected 1 diagnostic, got %v", name, l.Diagnostics())
+		}
+		l.Rollback(cp)
+		if len(l.Diagnostics()) != 0 {
+			t.Errorf("%s: rollback kept diagnostics %v", name, l.Diagnostics())
+		}
+
+		second := l.Tokens()
+		if len(first) != len(second) {
+			t.Fatalf("%s: %d tokens after rollback, want %d", name, len(second), len(first))
+		}
+		for i := range first {
+			if first[i] != second[i] {
+				t.Errorf("%s: token %d = %+v after rollback, want %+v", name, i, second[i], first[i])
+			}
+		}
+
+		l = newLexer()
+		cp = l.Mark()
+		l.NextToken()
+		l.Release(cp)
+		if tok := l.NextToken(); tok.Offset != 2 {
+			t.Errorf("%s: token after Release = %+v, want offset 2", name, tok)
+		}
+	}
+}
+
+func TestOperatorTable(t *testing.T) {
+	for _, op := range operators {
+		l := New(op.literal)
+		if tok := l.NextToken(); tok.Type != op.tokenType || tok.Literal != op.literal {
+			t.Errorf("%q lexed as %s %q", op.literal, tok.Type, tok.Literal)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q: expected EOF, got %+v", op.literal, tok)
+		}
+	}
+
+	// Longest match: "<==" is "<=" then "=", "!==" is "!=" then "=".
+	l := New("<==!==")
+	for _, want := range []token.TokenType{token.LT_EQ, token.ASSIGN, token.NOT_EQ, token.ASSIGN, token.EOF} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Errorf("got %s, want %s", tok.Type, want)
+		}
+	}
+}
+
+const operatorProgram = `a == b != c <= d >= e && f || !g; h = (i + j) * k / l % m - n < o > p; [q, r]{s: t};
+`
+
+func BenchmarkLexerOperators(b *testing.B) {
+	input := strings.Repeat(operatorProgram, (1<<20)/len(operatorProgram)+1)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{`"hello ${name}!"`, []token.Token{
+			{Type: token.STRING_SEGMENT, Literal: "hello "},
+			{Type: token.INTERP_START, Literal: "${"},
+			{Type: token.IDENT, Literal: "name"},
+			{Type: token.INTERP_END, Literal: "}"},
+			{Type: token.STRING_END, Literal: "!"},
+		}},
+		{`"${a}${ b + 1 }"`, []token.Token{
+			{Type: token.STRING_SEGMENT, Literal: ""},
+			{Type: token.INTERP_START, Literal: "${"},
+			{Type: token.IDENT, Literal: "a"},
+			{Type: token.INTERP_END, Literal: "}"},
+			{Type: token.STRING_SEGMENT, Literal: ""},
+			{Type: token.INTERP_START, Literal: "${"},
+			{Type: token.IDENT, Literal: "b"},
+			{Type: token.PLUS, Literal: "+"},
+			{Type: token.INT, Literal: "1"},
+			{Type: token.INTERP_END, Literal: "}"},
+			{Type: token.STRING_END, Literal: ""},
+		}},
+		{`"n: ${ {"k": "in ${x}"}["k"] } \${literal} $5"; y`, []token.Token{
+			{Type: token.STRING_SEGMENT, Literal: "n: "},
+			{Type: token.INTERP_START, Literal: "${"},
+			{Type: token.LBRACE, Literal: "{"},
+			{Type: token.STRING, Literal: "k"},
+			{Type: token.COLON, Literal: ":"},
+			{Type: token.STRING_SEGMENT, Literal: "in "},
+			{Type: token.INTERP_START, Literal: "${"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.INTERP_END, Literal: "}"},
+			{Type: token.STRING_END, Literal: ""},
+			{Type: token.RBRACE, Literal: "}"},
+			{Type: token.LBRACKET, Literal: "["},
+			{Type: token.STRING, Literal: "k"},
+			{Type: token.RBRACKET, Literal: "]"},
+			{Type: token.INTERP_END, Literal: "}"},
+			{Type: token.STRING_END, Literal: " ${literal} $5"},
+			{Type: token.SEMICOLON, Literal: ";"},
+			{Type: token.IDENT, Literal: "y"},
+		}},
+		{`${x}`, []token.Token{
+			{Type: token.ILLEGAL, Literal: "$"},
+			{Type: token.LBRACE, Literal: "{"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.RBRACE, Literal: "}"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range append(tt.expected, token.Token{Type: token.EOF}) {
+			if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+				t.Errorf("%s: token %d = %+v, want %+v", tt.input, i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"\"\"\"\nline one\n  \"quoted\" \\n ${x}\n\"\"\";", []token.Token{
+			{Type: token.STRING, Literal: "line one\n  \"quoted\" \\n ${x}\n"},
+			{Type: token.SEMICOLON, Literal: ";"},
+		}},
+		{`"""inline""" + ""`, []token.Token{
+			{Type: token.STRING, Literal: "inline"},
+			{Type: token.PLUS, Literal: "+"},
+			{Type: token.STRING, Literal: ""},
+		}},
+		{"\"\"\"\r\nwindows\r\n\"\"\"", []token.Token{
+			{Type: token.STRING, Literal: "windows\r\n"},
+		}},
+		{`""""""`, []token.Token{{Type: token.STRING, Literal: ""}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range append(tt.expected, token.Token{Type: token.EOF}) {
+			if got := l.NextToken(); got.Type != want.Type || got.Literal != want.Literal {
+				t.Errorf("%q: token %d = %+v, want %+v", tt.input, i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected token.Token
+	}{
+		{`'a'`, token.Token{Type: token.CHAR, Literal: "a"}},
+		{`'é'`, token.Token{Type: token.CHAR, Literal: "é"}},
+		{`'\n'`, token.Token{Type: token.CHAR, Literal: "\n"}},
+		{`'\''`, token.Token{Type: token.CHAR, Literal: "'"}},
+		{`'"'`, token.Token{Type: token.CHAR, Literal: `"`}},
+		{`'☺'`, token.Token{Type: token.CHAR, Literal: "☺"}},
+		{`''`, token.Token{Type: token.ILLEGAL, Literal: "''"}},
+		{`'ab'`, token.Token{Type: token.ILLEGAL, Literal: "'ab'"}},
+		{`'a`, token.Token{Type: token.ILLEGAL, Literal: "'a"}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input + "\nx")
+		tok := l.NextToken()
+		if tok.Type != tt.expected.Type || tok.Literal != tt.expected.Literal {
+			t.Errorf("%s: got %s %q, want %s %q", tt.input, tok.Type, tok.Literal, tt.expected.Type, tt.expected.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.IDENT {
+			t.Errorf("%s: lexer did not resume after the literal, got %+v", tt.input, next)
+		}
+
+		diags := l.Diagnostics()
+		if tt.expected.Type == token.ILLEGAL {
+			if len(diags) != 1 || diags[0].Code != diag.InvalidCharLiteral {
+				t.Errorf("%s: expected one %s diagnostic, got %v", tt.input, diag.InvalidCharLiteral, diags)
+			}
+		} else if len(diags) != 0 {
+			t.Errorf("%s: unexpected diagnostics %v", tt.input, diags)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	input := strings.Repeat(benchmarkProgram+"let π = \"naïve ${x}\"; /* ☺ */ 'é' // end\n", 200)
+
+	sources := map[string]func() io.Reader{
+		"one byte":  func() io.Reader { return iotest.OneByteReader(strings.NewReader(input)) },
+		"half read": func() io.Reader { return iotest.HalfReader(strings.NewReader(input)) },
+		"whole":     func() io.Reader { return strings.NewReader(input) },
+	}
+	for name, source := range sources {
+		want := New(input)
+		got := NewReader(source())
+		for i := 0; ; i++ {
+			w, g := want.NextToken(), got.NextToken()
+			if w != g {
+				t.Fatalf("%s: token %d = %+v, want %+v", name, i, g, w)
+			}
+			if w.Type == token.EOF {
+				break
+			}
+		}
+		if got.Err() != nil {
+			t.Errorf("%s: unexpected error %v", name, got.Err())
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	l := NewReader(io.MultiReader(strings.NewReader("let x"), iotest.ErrReader(readErr)))
+
+	for _, want := range []token.TokenType{token.LET, token.IDENT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != want {
+			t.Fatalf("got %s, want %s", tok.Type, want)
+		}
+	}
+	if !errors.Is(l.Err(), readErr) {
+		t.Errorf("Err() = %v, want %v", l.Err(), readErr)
+	}
+}
@@ -1,13 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"simple-interpreter/diag"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/interp"
+	"simple-interpreter/jupyter"
+	"simple-interpreter/lexer"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"simple-interpreter/profiler"
 	"simple-interpreter/repl"
+	"simple-interpreter/trace"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			os.Exit(run(os.Args[2:]))
+		case "replay":
+			os.Exit(replay(os.Args[2:]))
+		case "kernel":
+			os.Exit(kernel(os.Args[2:]))
+		}
+	}
+
 	user, err := user.Current()
 
 	if err != nil {
@@ -18,3 +39,166 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	profile := flags.Bool("profile", false, "print a per-function time and allocation report")
+	traceFile := flags.String("trace", "", "record an execution trace to `file`")
+	format := flags.String("diagnostics", "text", "report errors as `text` or json")
+	lang := flags.String("lang", diag.DefaultLanguage, "`language` of error messages")
+	var opts interp.Options
+	flags.BoolVar(&opts.AllowFS, "allow-fs", false, "let the script read and write files")
+	flags.BoolVar(&opts.AllowNet, "allow-net", false, "let the script make network requests")
+	flags.BoolVar(&opts.AllowEnv, "allow-env", false, "let the script read environment variables")
+	flags.BoolVar(&opts.AllowExec, "allow-exec", false, "let the script run other programs")
+	var plugins []string
+	flags.Func("plugin", "load builtins from the Go plugin at `path` (repeatable)", func(path string) error {
+		plugins = append(plugins, path)
+		return nil
+	})
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "usage: run [--allow-fs] [--allow-net] [--allow-env] [--allow-exec] [--profile] [--trace file] [--plugin path] [--diagnostics text|json] [--lang code] <script>")
+		return 2
+	}
+	write := diag.WriteText
+	if *format == "json" {
+		write = diag.WriteJSON
+	}
+	report := func(diags []diag.Diagnostic) {
+		for i, d := range diags {
+			diags[i] = diag.Localize(d, *lang)
+		}
+		if err := write(os.Stderr, diags); err != nil {
+			fmt.Fprintln(os.Stderr, "writing diagnostics:", err)
+		}
+	}
+
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.NewFile(flags.Arg(0), string(src)))
+	program := p.ParseProgram()
+	if err := diag.List(p.Diagnostics()).Err(); err != nil {
+		report(p.Diagnostics())
+		return 1
+	}
+
+	interpreter := interp.New(opts)
+	for _, path := range plugins {
+		if err := interp.LoadPlugin(interpreter, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	e := interpreter.Evaluator()
+	var prof *profiler.Profiler
+	if *profile {
+		prof = profiler.New()
+		prof.Attach(e)
+	}
+
+	var rec *trace.Recorder
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		rec = trace.NewRecorder(f, string(src))
+		rec.Attach(e)
+	}
+
+	result := e.Eval(program, interpreter.Env())
+	if prof != nil {
+		prof.WriteReport(os.Stderr)
+	}
+	if rec != nil && rec.Err() != nil {
+		fmt.Fprintln(os.Stderr, rec.Err())
+		return 1
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		report([]diag.Diagnostic{evaluator.Diagnostic(errObj)})
+		return 1
+	}
+	return 0
+}
+
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	var plugins []string
+	flags.Func("plugin", "load builtins from the Go plugin at `path` (repeatable)", func(path string) error {
+		plugins = append(plugins, path)
+		return nil
+	})
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [--plugin path] <trace>")
+		return 2
+	}
+
+	// Replayed builtins answer from the trace and are never called, so every
+	// capability can be enabled to make all the recorded names available.
+	interpreter := interp.New(interp.Options{AllowFS: true, AllowNet: true, AllowEnv: true, AllowExec: true})
+	for _, path := range plugins {
+		if err := interp.LoadPlugin(interpreter, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	t, err := trace.Read(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	result, err := t.Replay(interpreter.Evaluator())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if result != nil {
+		fmt.Println(result.Inspect())
+	}
+	return 0
+}
+
+// kernel runs as a Jupyter kernel. Register it with a kernel.json whose argv
+// is ["<binary>", "kernel", "{connection_file}"].
+func kernel(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: kernel <connection-file>")
+		return 2
+	}
+
+	info, err := jupyter.ReadConnectionFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	k, err := jupyter.New(info, interp.Options{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := k.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
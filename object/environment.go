@@ -28,3 +28,35 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign rebinds name in the innermost scope that defines it. It reports
+// false, changing nothing, if name is not bound anywhere.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Environment) Clone() *Environment {
+	clone := &Environment{store: make(map[string]Object, len(e.store)), outer: e.outer}
+	for name, val := range e.store {
+		clone.store[name] = val
+	}
+	return clone
+}
+
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+func (e *Environment) Bindings() map[string]Object {
+	bindings := make(map[string]Object, len(e.store))
+	for name, val := range e.store {
+		bindings[name] = val
+	}
+	return bindings
+}
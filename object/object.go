@@ -28,6 +28,7 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	HANDLE_OBJ       = "HANDLE"
 )
 
 type Integer struct {
@@ -48,6 +49,7 @@ type ReturnValue struct {
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -56,6 +58,16 @@ type Null struct{}
 
 type Error struct {
 	Message string
+	Code    string
+	Fix     string
+	Data    map[string]interface{}
+}
+
+// Handle wraps a host resource, such as a socket, that builtins hand to
+// scripts and later receive back.
+type Handle struct {
+	Kind  string
+	Value interface{}
 }
 
 type Builtin struct {
@@ -109,6 +121,9 @@ func (f *Function) Inspect() string {
 	for _, p := range f.Parameters {
 		params = append(params, p.String())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -121,6 +136,9 @@ func (f *Function) Inspect() string {
 }
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 
+func (h *Handle) Inspect() string  { return "<" + h.Kind + ">" }
+func (h *Handle) Type() ObjectType { return HANDLE_OBJ }
+
 func (bf *Builtin) Inspect() string  { return "builtin function" }
 func (bf *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 
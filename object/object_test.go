@@ -18,3 +18,24 @@ func TestStringJas(t *testing.T) {
 	}
 
 }
+
+func TestEnvironmentClone(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("shared", &Integer{Value: 1})
+	env := NewEnclosedEnvironment(outer)
+	env.Set("x", &Integer{Value: 2})
+
+	clone := env.Clone()
+	clone.Set("x", &Integer{Value: 3})
+	clone.Set("y", &Integer{Value: 4})
+
+	if x, _ := env.Get("x"); x.Inspect() != "2" {
+		t.Errorf("clone modified original binding. got=%s", x.Inspect())
+	}
+	if _, ok := env.Get("y"); ok {
+		t.Errorf("clone added binding to original")
+	}
+	if shared, ok := clone.Get("shared"); !ok || shared.Inspect() != "1" {
+		t.Errorf("clone lost access to outer environment")
+	}
+}
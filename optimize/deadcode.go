@@ -0,0 +1,88 @@
+package optimize
+
+import (
+	"simple-interpreter/ast"
+	"sort"
+)
+
+// A Removal is code that EliminateDeadCode dropped because it can never run.
+type Removal struct {
+	// Node is the removed statement or if branch.
+	Node ast.Node
+	// Cause is the return statement it followed, or the constant condition
+	// of the if it was a branch of.
+	Cause ast.Node
+}
+
+func (r Removal) String() string {
+	if _, ok := r.Cause.(*ast.ReturnStatement); ok {
+		return "unreachable code after return"
+	}
+	return "unreachable branch: condition " + r.Cause.String() + " is constant"
+}
+
+// EliminateDeadCode removes the statements that follow a return in the same
+// block and replaces each if with a constant condition by the branch it
+// takes. It returns the new tree and what was removed, in source order; code
+// nested inside a removal is not reported separately. node is not
+// modified.
+func EliminateDeadCode(node ast.Node) (ast.Node, []Removal) {
+	var removed []Removal
+	result := ast.Rewrite(node, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.Program:
+			n.Statements = cutAfterReturn(n.Statements, &removed)
+		case *ast.BlockStatement:
+			n.Statements = cutAfterReturn(n.Statements, &removed)
+		case *ast.IfExpression:
+			truthy, ok := truthiness(n.Condition)
+			if !ok || n.Consequence == nil {
+				return n
+			}
+			if truthy && n.Alternative != nil {
+				removed = append(removed, Removal{Node: n.Alternative, Cause: n.Condition})
+			} else if !truthy {
+				removed = append(removed, Removal{Node: n.Consequence, Cause: n.Condition})
+			}
+			return foldIf(n)
+		}
+		return n
+	})
+	return result, outermost(removed)
+}
+
+func cutAfterReturn(stmts []ast.Statement, removed *[]Removal) []ast.Statement {
+	for i, stmt := range stmts {
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			for _, dead := range stmts[i+1:] {
+				*removed = append(*removed, Removal{Node: dead, Cause: stmt})
+			}
+			return stmts[:i+1]
+		}
+	}
+	return stmts
+}
+
+// outermost sorts removals by position and drops those inside an earlier
+// one. The tree is rewritten bottom up, so dead code within dead code is
+// seen first.
+func outermost(removed []Removal) []Removal {
+	sort.SliceStable(removed, func(i, j int) bool {
+		a, b := removed[i].Node, removed[j].Node
+		if a.Pos().Offset != b.Pos().Offset {
+			return a.Pos().Offset < b.Pos().Offset
+		}
+		return a.End().Offset > b.End().Offset
+	})
+	var result []Removal
+	for _, r := range removed {
+		if len(result) > 0 {
+			last := result[len(result)-1].Node
+			if r.Node.Pos().IsValid() && r.Node.End().Offset <= last.End().Offset {
+				continue
+			}
+		}
+		result = append(result, r)
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+package optimize
+
+import (
+	"fmt"
+	"simple-interpreter/ast"
+	"testing"
+)
+
+func TestEliminateDeadCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		removed  []string
+	}{
+		{
+			"let f = fn() { return 1; 2; 3 };",
+			"let f = fn()return 1;;",
+			[]string{"1:26 unreachable code after return", "1:29 unreachable code after return"},
+		},
+		{
+			"if (true) { a } else { b }",
+			"a",
+			[]string{"1:22 unreachable branch: condition true is constant"},
+		},
+		{
+			"if (false) { a }; c",
+			"c",
+			[]string{"1:12 unreachable branch: condition false is constant"},
+		},
+		{
+			"return x; if (false) { a }",
+			"return x;",
+			[]string{"1:11 unreachable code after return"},
+		},
+		{
+			"if (x) { return 1; } else { y }; z",
+			"ifx return 1;else yz",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		before := program.String()
+		result, removed := EliminateDeadCode(program)
+		if got := result.String(); got != tt.expected {
+			t.Errorf("%q: result wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if program.String() != before {
+			t.Errorf("%q: input was modified", tt.input)
+		}
+
+		var got []string
+		for _, r := range removed {
+			pos := r.Node.Pos()
+			got = append(got, fmt.Sprintf("%d:%d %s", pos.Line, pos.Column, r))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.removed) {
+			t.Errorf("%q: removals wrong.\nwant=%q\ngot =%q", tt.input, tt.removed, got)
+		}
+	}
+}
+
+func TestEliminateDeadCodeIsEquivalent(t *testing.T) {
+	program := parse(t, "let f = fn(x) { if (x > 1) { return x; 0 } return -1; x }; f(5)")
+	result, _ := EliminateDeadCode(program)
+	if !ast.Equal(result, parse(t, "let f = fn(x) { if (x > 1) { return x; } return -1; }; f(5)")) {
+		t.Errorf("unexpected result: %s", result.String())
+	}
+}
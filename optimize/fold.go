@@ -0,0 +1,221 @@
+// Package optimize rewrites programs into simpler ones that evaluate to the
+// same result, as optional passes between parsing and evaluation.
+package optimize
+
+import (
+	"simple-interpreter/ast"
+	"simple-interpreter/token"
+	"strconv"
+)
+
+// Fold evaluates the parts of node that depend only on literals. Integer
+// and boolean operators and string concatenation are replaced with their
+// result, && and || with a constant left operand are reduced to the operand
+// that decides them, and an if with a constant condition is replaced with
+// the branch it takes. Operations that fail at run time, such as division
+// by zero, are left for the evaluator to report. node is not modified.
+func Fold(node ast.Node) ast.Node {
+	return ast.Rewrite(node, fold)
+}
+
+func fold(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.PrefixExpression:
+		if folded := foldPrefix(n); folded != nil {
+			return folded
+		}
+	case *ast.InfixExpression:
+		if folded := foldInfix(n); folded != nil {
+			return folded
+		}
+	case *ast.IfExpression:
+		if folded := foldIf(n); folded != nil {
+			return folded
+		}
+	}
+	return node
+}
+
+func foldPrefix(n *ast.PrefixExpression) ast.Expression {
+	if n.Operator == "!" {
+		if truthy, ok := truthiness(n.Right); ok {
+			return boolean(!truthy, n)
+		}
+		return nil
+	}
+
+	value, ok := integerValue(n.Right)
+	if !ok {
+		return nil
+	}
+	switch n.Operator {
+	case "-":
+		return integer(-value, n)
+	case "~":
+		return integer(^value, n)
+	}
+	return nil
+}
+
+func foldInfix(n *ast.InfixExpression) ast.Expression {
+	if n.Operator == "&&" || n.Operator == "||" {
+		// The result is the operand that decides it, as in the evaluator.
+		truthy, ok := truthiness(n.Left)
+		if !ok {
+			return nil
+		}
+		if truthy == (n.Operator == "||") {
+			return n.Left
+		}
+		return n.Right
+	}
+
+	if left, ok := integerValue(n.Left); ok {
+		if right, ok := integerValue(n.Right); ok {
+			return foldIntegers(n, left, right)
+		}
+		return nil
+	}
+
+	switch left := n.Left.(type) {
+	case *ast.Boolean:
+		right, ok := n.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch n.Operator {
+		case "==":
+			return boolean(left.Value == right.Value, n)
+		case "!=":
+			return boolean(left.Value != right.Value, n)
+		}
+	case *ast.StringLiteral:
+		// Strings only support +; == compares string objects, not their
+		// contents, so it is not folded.
+		right, ok := n.Right.(*ast.StringLiteral)
+		if ok && n.Operator == "+" {
+			value := left.Value + right.Value
+			return &ast.StringLiteral{Token: span(token.STRING, value, n), Value: value}
+		}
+	}
+	return nil
+}
+
+func foldIntegers(n *ast.InfixExpression, left, right int64) ast.Expression {
+	switch n.Operator {
+	case "+":
+		return integer(left+right, n)
+	case "-":
+		return integer(left-right, n)
+	case "*":
+		return integer(left*right, n)
+	case "/":
+		if right != 0 {
+			return integer(left/right, n)
+		}
+	case "%":
+		if right != 0 {
+			return integer(left%right, n)
+		}
+	case "&":
+		return integer(left&right, n)
+	case "|":
+		return integer(left|right, n)
+	case "^":
+		return integer(left^right, n)
+	case "<<":
+		if right >= 0 {
+			return integer(left<<right, n)
+		}
+	case ">>":
+		if right >= 0 {
+			return integer(left>>right, n)
+		}
+	case "==":
+		return boolean(left == right, n)
+	case "!=":
+		return boolean(left != right, n)
+	case "<":
+		return boolean(left < right, n)
+	case ">":
+		return boolean(left > right, n)
+	case "<=":
+		return boolean(left <= right, n)
+	case ">=":
+		return boolean(left >= right, n)
+	}
+	return nil
+}
+
+// foldIf replaces an if whose condition is constant with the block it
+// runs. A block evaluates in the enclosing scope just as the if would, and
+// an empty block evaluates to null like an if without a matching branch.
+// The empty block spans the whole if.
+func foldIf(n *ast.IfExpression) ast.Expression {
+	truthy, ok := truthiness(n.Condition)
+	if !ok || n.Consequence == nil {
+		return nil
+	}
+	if truthy {
+		return n.Consequence
+	}
+	if n.Alternative != nil {
+		return n.Alternative
+	}
+	return &ast.BlockStatement{Token: n.Token, Statements: []ast.Statement{}, Rbrace: span(token.RBRACE, "}", n)}
+}
+
+// truthiness reports whether exp is a literal and, if so, whether the
+// evaluator treats its value as true.
+func truthiness(exp ast.Expression) (truthy bool, ok bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.NullLiteral:
+		return false, true
+	case *ast.IntegerLiteral, *ast.CharLiteral, *ast.StringLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// integerValue returns the value of an integer or character literal, which
+// both evaluate to integers.
+func integerValue(exp ast.Expression) (int64, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return exp.Value, true
+	case *ast.CharLiteral:
+		return int64(exp.Value), true
+	}
+	return 0, false
+}
+
+func integer(value int64, from ast.Node) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: span(token.INT, strconv.FormatInt(value, 10), from), Value: value}
+}
+
+func boolean(value bool, from ast.Node) *ast.Boolean {
+	typ := token.TokenType(token.FALSE)
+	if value {
+		typ = token.TRUE
+	}
+	return &ast.Boolean{Token: span(typ, strconv.FormatBool(value), from), Value: value}
+}
+
+// span makes a token for a literal that replaces from, covering the same
+// source so positions in diagnostics still point at the original code.
+func span(typ token.TokenType, literal string, from ast.Node) token.Token {
+	pos, end := from.Pos(), from.End()
+	return token.Token{
+		Type:      typ,
+		Literal:   literal,
+		File:      pos.File,
+		Line:      pos.Line,
+		Column:    pos.Column,
+		Offset:    pos.Offset,
+		EndLine:   end.Line,
+		EndColumn: end.Column,
+		EndOffset: end.Offset,
+	}
+}
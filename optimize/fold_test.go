@@ -0,0 +1,122 @@
+package optimize
+
+import (
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	program, err := parser.ParseSafe(input)
+	if err != nil {
+		t.Fatalf("%q: %s", input, err)
+	}
+	return program
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 4", "10"},
+		{"x * (2 + 3)", "(x * 5)"},
+		{"-(1 - 3) << 2", "8"},
+		{"~0", "-1"},
+		{"'a' + 1", "98"},
+		{`"foo" + "bar" + "baz"`, "foobarbaz"},
+		{"1 + 2 == 3", "true"},
+		{"!(1 < 2) != false", "false"},
+		{"!0", "false"},
+		{"true && x", "x"},
+		{"false && x", "false"},
+		{"0 || x", "0"},
+		{"x || true", "(x || true)"},
+		{"if (1 > 2) { a } else { b }", "b"},
+		{"if (true) { a }", "a"},
+		{"if (false) { a }", ""},
+		{"if (x) { 1 + 1 } else { 2 + 2 }", "ifx 2else 4"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 % (2 - 2)", "(1 % 0)"},
+		{"1 << -1", "(1 << -1)"},
+		{`"a" == "a"`, "(a == a)"},
+		{"1 == true", "(1 == true)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		before := program.String()
+		folded := Fold(program)
+		if got := folded.String(); got != tt.expected {
+			t.Errorf("Fold(%q) wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if program.String() != before {
+			t.Errorf("Fold(%q) modified its input", tt.input)
+		}
+	}
+}
+
+// TestFoldPreservesResults checks that folding does not change what a
+// program evaluates to, errors included.
+func TestFoldPreservesResults(t *testing.T) {
+	inputs := []string{
+		"2 * 3 + 4 - 10 / 3 % 2",
+		`let s = "a" + "b"; s + "c"`,
+		"let f = fn(x) { if (true) { return x * 2; } 0 }; f(21)",
+		"let f = fn() { if (false) { 1 } }; f()",
+		"let x = 5; if (1 < 2 && x > 1) { x } else { 0 }",
+		"true && 5",
+		"null_name || 1",
+		"1 / (3 - 3)",
+		"-true",
+		"[1 + 1, 'a' - 1][0]",
+	}
+
+	for _, input := range inputs {
+		want := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		got := evaluator.Eval(Fold(parse(t, input)), object.NewEnvironment())
+		if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+			t.Errorf("%q: folded result %s %q, want %s %q", input, got.Type(), got.Inspect(), want.Type(), want.Inspect())
+		}
+	}
+}
+
+// TestFoldFormatsAsSource checks that folded programs still format as source
+// that parses back to the same result, including negative folded literals.
+func TestFoldFormatsAsSource(t *testing.T) {
+	inputs := []string{
+		"let foo = fn(x) { x + 10 }; -(0 - 3).foo()",
+		"let foo = fn(x) { x + 10 }; (0 - 3).foo()",
+		"-(0 - 3)",
+		"[(0 - 3)][0] - (0 - 3)",
+		"let f = fn(x) { x }; f(0 - 3)",
+		"~(0 - 3)",
+	}
+
+	for _, input := range inputs {
+		folded := Fold(parse(t, input))
+		source := ast.Format(folded, ast.FormatOptions{})
+		reparsed, err := parser.ParseSafe(source)
+		if err != nil {
+			t.Errorf("%q: formatted as %q, which fails to parse: %s", input, source, err)
+			continue
+		}
+		want := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		got := evaluator.Eval(reparsed, object.NewEnvironment())
+		if got.Inspect() != want.Inspect() {
+			t.Errorf("%q: formatted as %q, which gives %s, want %s", input, source, got.Inspect(), want.Inspect())
+		}
+	}
+}
+
+func TestFoldKeepsPositions(t *testing.T) {
+	program := parse(t, "let x =\n  1 + 2 * 3;")
+	let := Fold(program).(*ast.Program).Statements[0].(*ast.LetStatement)
+	pos, end := let.Value.Pos(), let.Value.End()
+	if pos.Line != 2 || pos.Column != 3 || end.Line != 2 || end.Column != 12 {
+		t.Errorf("folded literal spans %d:%d-%d:%d, want 2:3-2:12", pos.Line, pos.Column, end.Line, end.Column)
+	}
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"simple-interpreter/diag"
+	"simple-interpreter/lexer"
+	"simple-interpreter/token"
+)
+
+// FeatureSet toggles optional syntax so experiments can ship behind flags
+// while the core language stays stable.
+type FeatureSet uint
+
+const (
+	// FeatureOptionalSemicolons lets statements end without a semicolon.
+	// Without it, every statement needs one unless it closes a block or is
+	// the last in its block or program.
+	FeatureOptionalSemicolons FeatureSet = 1 << iota
+
+	// FeatureMacros and FeatureClasses reserve flags for macro and class
+	// syntax, which are not implemented yet. A parser given either reports
+	// UnsupportedFeature instead of silently parsing without it.
+	FeatureMacros
+	FeatureClasses
+)
+
+// implementedFeatures are the features the parser can actually enable.
+const implementedFeatures = FeatureOptionalSemicolons
+
+var featureNames = map[FeatureSet]string{
+	FeatureOptionalSemicolons: "optional semicolons",
+	FeatureMacros:             "macros",
+	FeatureClasses:            "classes",
+}
+
+// LanguageVersion is the newest version understood by FeaturesFor.
+const LanguageVersion = 1
+
+var versionFeatures = map[int]FeatureSet{
+	1: FeatureOptionalSemicolons,
+}
+
+// FeaturesFor returns the features enabled by default in a language version,
+// so embedders can pin scripts to the syntax they were written against.
+func FeaturesFor(version int) (FeatureSet, error) {
+	features, ok := versionFeatures[version]
+	if !ok {
+		return 0, fmt.Errorf("unknown language version %d", version)
+	}
+	return features, nil
+}
+
+func (s FeatureSet) Has(f FeatureSet) bool {
+	return s&f == f
+}
+
+// Options tunes a parser created by NewWithOptions. The zero Options gives
+// the strictest parser: every feature off, so semicolons are required.
+type Options struct {
+	Features FeatureSet
+
+	// AllowKeywordIdentifiers lets keywords name variables, parameters and
+	// members, as in "let in = 1" or "point.for". In expressions this only
+	// applies to keywords that cannot start one, such as in.
+	AllowKeywordIdentifiers bool
+
+	// Keywords adds keywords on top of the language's own for this parser's
+	// lexer only. Nil means the language's keywords alone.
+	Keywords *token.Keywords
+
+	// MaxDepth limits how deeply expressions may nest, so input such as
+	// thousands of open parentheses fails with an error instead of using up
+	// the stack. Zero means DefaultMaxDepth.
+	MaxDepth int
+}
+
+const DefaultMaxDepth = 1000
+
+func (o Options) maxDepth() int {
+	if o.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return o.MaxDepth
+}
+
+func DefaultOptions() Options {
+	features, _ := FeaturesFor(LanguageVersion)
+	return Options{Features: features}
+}
+
+func NewWithOptions(l *lexer.Lexer, opts Options) *Parser {
+	l.SetKeywords(opts.Keywords)
+	p := New(l)
+	p.opts = opts
+	for f := FeatureSet(1); f != 0 && f <= opts.Features; f <<= 1 {
+		if opts.Features.Has(f) && !implementedFeatures.Has(f) {
+			name, ok := featureNames[f]
+			if !ok {
+				name = fmt.Sprintf("%#x", uint(f))
+			}
+			p.report(p.curToken, diag.UnsupportedFeature, diag.Data{"feature": name})
+		}
+	}
+	return p
+}
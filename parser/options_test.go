@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"simple-interpreter/lexer"
+	"simple-interpreter/token"
+	"strings"
+	"testing"
+)
+
+func TestStrictSemicolons(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"let x = 5; x;", true},
+		{"let x = 5", true},
+		{"let f = fn(x) { x }; f(1)", true},
+		{"if (true) { 1 } let y = 2;", true},
+		{"let x = 5 let y = 6;", false},
+		{"return 1 2;", false},
+		{"fn(x) { let y = x return y }", false},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{})
+		p.ParseProgram()
+		if got := len(p.Errors()) == 0; got != tt.ok {
+			t.Errorf("%q: ok=%t, want %t (errors: %v)", tt.input, got, tt.ok, p.Errors())
+		}
+
+		p = New(lexer.New(tt.input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestKeywordIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let in = 1; in + 1;", "let in = 1;(in + 1)"},
+		{"let f = fn(for, let) { return for }", "let f = fn(for, let)return for;;"},
+		{"let [if, else] = pair;", "let [if, else] = pair;"},
+		{"point.return", "(point.return)"},
+		{"if (true) { 1 }", "iftrue 1"},
+	}
+
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.AllowKeywordIdentifiers = true
+		p := NewWithOptions(lexer.New(tt.input), opts)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+
+		p = New(lexer.New(tt.input))
+		p.ParseProgram()
+		if tt.input != "if (true) { 1 }" && len(p.Errors()) == 0 {
+			t.Errorf("%q: expected errors with default options", tt.input)
+		}
+	}
+}
+
+func TestOptionKeywords(t *testing.T) {
+	keywords := &token.Keywords{}
+	if err := keywords.Register("unless", "UNLESS"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	input := "let unless = 1; unless;"
+
+	opts := DefaultOptions()
+	opts.Keywords = keywords
+	p := NewWithOptions(lexer.New(input), opts)
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("%q: expected errors with unless as a keyword", input)
+	}
+
+	opts.AllowKeywordIdentifiers = true
+	p = NewWithOptions(lexer.New(input), opts)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if got := program.String(); got != "let unless = 1;unless" {
+		t.Errorf("%q: got=%q", input, got)
+	}
+
+	// Other parsers do not see the keyword.
+	p = New(lexer.New(input))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+}
+
+func TestMaxDepth(t *testing.T) {
+	deep := []string{
+		strings.Repeat("(", 10000) + "1" + strings.Repeat(")", 10000),
+		strings.Repeat("[", 10000),
+		strings.Repeat("- ", 10000) + "x",
+		strings.Repeat("fn() { ", 5000),
+	}
+	for _, input := range deep {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != "expression too deeply nested" {
+			t.Errorf("%.10q...: errors = %q", input, errors)
+		}
+	}
+
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"((1))", true},
+		{"(((1)))", false},
+		{"[[1]]", true},
+		{"1 + 2 * 3 - 4", true},
+	}
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New(tt.input), Options{MaxDepth: 3})
+		p.ParseProgram()
+		if got := len(p.Errors()) == 0; got != tt.ok {
+			t.Errorf("%q: ok=%t, want %t (errors: %v)", tt.input, got, tt.ok, p.Errors())
+		}
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	tests := []struct {
+		features FeatureSet
+		expected []string
+	}{
+		{FeatureOptionalSemicolons, nil},
+		{FeatureMacros, []string{"syntax feature macros is not supported"}},
+		{FeatureOptionalSemicolons | FeatureClasses, []string{"syntax feature classes is not supported"}},
+		{FeatureMacros | FeatureClasses, []string{"syntax feature macros is not supported", "syntax feature classes is not supported"}},
+		{1 << 10, []string{"syntax feature 0x400 is not supported"}},
+	}
+
+	for _, tt := range tests {
+		p := NewWithOptions(lexer.New("let x = 1;"), Options{Features: tt.features})
+		p.ParseProgram()
+		if got := p.Errors(); strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("features %b: errors = %q, want %q", tt.features, got, tt.expected)
+		}
+	}
+}
+
+func TestFeaturesFor(t *testing.T) {
+	features, err := FeaturesFor(LanguageVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !features.Has(FeatureOptionalSemicolons) {
+		t.Errorf("version %d should allow optional semicolons", LanguageVersion)
+	}
+	if DefaultOptions().Features != features {
+		t.Errorf("default features %b, want %b", DefaultOptions().Features, features)
+	}
+
+	if _, err := FeaturesFor(LanguageVersion + 1); err == nil {
+		t.Error("expected error for unknown language version")
+	}
+}
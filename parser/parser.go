@@ -1,19 +1,27 @@
 package parser
 
 import (
-	"fmt"
 	"simple-interpreter/ast"
+	"simple-interpreter/diag"
 	"simple-interpreter/lexer"
 	"simple-interpreter/token"
 	"strconv"
+	"unicode/utf8"
 )
 
 type Parser struct {
-	l *lexer.Lexer
+	l     *lexer.Lexer
+	arena *ast.Arena
+	opts  Options
 
-	curToken  token.Token
-	peekToken token.Token
-	errors    []string
+	curToken    token.Token
+	peekToken   token.Token
+	diagnostics []diag.Diagnostic
+
+	// depth counts the expressions being parsed, and abandoned is set once
+	// it passes the limit and the rest of the input has been skipped.
+	depth     int
+	abandoned bool
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -22,30 +30,57 @@ type Parser struct {
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	OR
+	AND
 	EQUALS
 	LESSGREATER
+	RANGE
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
 	INDEX
+	POSTFIX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:    ASSIGN,
+	token.OR:        OR,
+	token.AND:       AND,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.RANGE:     RANGE,
+	token.RANGE_EQ:  RANGE,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.BIT_OR:    SUM,
+	token.BIT_XOR:   SUM,
+	token.SLASH:     PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.BIT_AND:   PRODUCT,
+	token.SHL:       PRODUCT,
+	token.SHR:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+	token.DOT:       INDEX,
+	token.INCREMENT: POSTFIX,
+	token.DECREMENT: POSTFIX,
+}
+
+// rightAssociative lists the infix operators that group right to left;
+// all others group left to right.
+var rightAssociative = map[token.TokenType]bool{
+	token.ASSIGN: true,
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, opts: DefaultOptions()}
 	p.NextToken()
 	p.NextToken()
 
@@ -54,6 +89,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
@@ -61,31 +97,137 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_SEGMENT, p.parseInterpolatedString)
+	p.registerPrefix(token.CHAR, p.parseCharLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
-	p.registerInfix(token.GT, p.parseInfixExpression)
-	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT, p.parseComparison)
+	p.registerInfix(token.LT, p.parseComparison)
+	p.registerInfix(token.GT_EQ, p.parseComparison)
+	p.registerInfix(token.LT_EQ, p.parseComparison)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.RANGE, p.parseRangeExpression)
+	p.registerInfix(token.RANGE_EQ, p.parseRangeExpression)
+	p.registerInfix(token.INCREMENT, p.parsePostfixExpression)
+	p.registerInfix(token.DECREMENT, p.parsePostfixExpression)
 
 	return p
 }
 
+func ParseSafe(input string) (program *ast.Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			program = nil
+			err = diag.New(diag.Internal, diag.Data{"component": "parser", "detail": r})
+		}
+	}()
+
+	p := New(lexer.New(input))
+	program = p.ParseProgram()
+	if err := diag.List(p.Diagnostics()).Err(); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// ParseExpressionString parses src as a single expression, for callers such
+// as calculators and template engines that have no use for statements. Like
+// ParseSafe, it returns the diagnostics as an error.
+func ParseExpressionString(src string) (exp ast.Expression, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			exp = nil
+			err = diag.New(diag.Internal, diag.Data{"component": "parser", "detail": r})
+		}
+	}()
+
+	p := New(lexer.New(src))
+	exp = p.ParseExpression()
+	if err := diag.List(p.Diagnostics()).Err(); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+func (p *Parser) UseArena(a *ast.Arena) {
+	p.arena = a
+}
+
+// Diagnostics returns the lexical and syntax problems found so far. When the
+// lexer holds the whole source, each one carries an excerpt of the offending
+// line.
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	diags := append(append([]diag.Diagnostic(nil), p.l.Diagnostics()...), p.diagnostics...)
+	if src, ok := p.l.Source(); ok {
+		for i := range diags {
+			diags[i].Excerpt = diag.Excerpt(src, diags[i].Range)
+		}
+	}
+	return diags
+}
+
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := []string{}
+	for _, d := range p.Diagnostics() {
+		if d.Severity == diag.Error {
+			errors = append(errors, d.Message)
+		}
+	}
+	return errors
+}
+
+// abandon reports that the input nests too deeply and skips the rest of it.
+// Later errors are not reported, as they would only come from the parsing
+// functions unwinding at the end of the input.
+func (p *Parser) abandon() {
+	p.report(p.curToken, diag.TooDeeplyNested, diag.Data{"limit": p.opts.maxDepth()})
+	p.abandoned = true
+	for !p.curTokenIs(token.EOF) {
+		p.NextToken()
+	}
+}
+
+// report records a diagnostic spanning tok. The token's literal is kept in
+// the data as "found" so tools can show what was there.
+func (p *Parser) report(tok token.Token, code diag.Code, data diag.Data) {
+	if p.abandoned {
+		return
+	}
+	if data == nil {
+		data = diag.Data{}
+	}
+	data["found"] = tok.Literal
+	d := diag.New(code, data)
+	end := tok.End()
+	d.Range.Start = diag.Position{File: tok.File, Line: tok.Line, Column: tok.Column, Offset: tok.Offset}
+	d.Range.End = diag.Position{File: end.File, Line: end.Line, Column: end.Column, Offset: end.Offset}
+	p.diagnostics = append(p.diagnostics, d)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	data := diag.Data{"expected": t, "got": p.peekToken.Type}
+	if len(t) == 1 {
+		data["insert"] = t
+	}
+	p.report(p.peekToken, diag.UnexpectedToken, data)
 }
 
 func (p *Parser) NextToken() {
@@ -106,68 +248,238 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// ParseExpression parses the rest of the input as one expression and
+// reports an error if any tokens are left after it.
+func (p *Parser) ParseExpression() ast.Expression {
+	exp := p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.EOF) {
+		p.peekError(token.EOF)
+	}
+	return exp
+}
+
+// ParseStatement parses the statement starting at the current token. A
+// statement that fails to parse is returned as an *ast.BadStatement.
 func (p *Parser) ParseStatement() ast.Statement {
+	start := p.curToken
 	switch p.curToken.Type {
 	case token.LET:
+		if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+			if stmt := p.parseDestructuringLet(); stmt != nil {
+				return stmt
+			}
+			return p.badStatement(start)
+		}
 		return p.ParseLetStatment()
 	case token.RETURN:
 		return p.ParseReturnStatement()
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+		return p.badStatement(start)
 	default:
 		return p.ParseExpressionStatement()
 	}
 }
 
-func (p *Parser) ParseLetStatment() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+// ParseLetStatment parses a let statement, returning a *ast.LetStatement for
+// a single binding and a *ast.LetGroup for "let x = 1, y = 2;".
+func (p *Parser) ParseLetStatment() ast.Statement {
+	start := p.curToken
+	stmt := p.parseLetBinding()
+	if stmt == nil {
+		return p.badStatement(start)
+	}
+	p.endStatement()
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	return stmt
+}
+
+// parseLetBinding parses "let name = value", or several comma-separated
+// bindings, without the statement terminator, leaving the parser on the
+// last token of the last value.
+func (p *Parser) parseLetBinding() ast.Statement {
+	letToken := p.curToken
+	var bindings []*ast.LetStatement
+
+	for {
+		stmt := p.arena.LetStatement(ast.LetStatement{Token: letToken})
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = p.arena.Identifier(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.parseTypeAnnotation(&stmt.Name.Type) {
+			return nil
+		}
+
+		if !p.expectPeek(token.ASSIGN) {
+			return nil
+		}
+		p.NextToken()
+		stmt.Value = p.parseExpression(LOWEST)
+		bindings = append(bindings, stmt)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.NextToken()
+	}
+
+	if len(bindings) == 1 {
+		return bindings[0]
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.LetGroup{Token: letToken, Bindings: bindings}
+}
 
-	if !p.expectPeek(token.ASSIGN) {
+// parseDestructuringLet parses "let [a, b] = value;" and "let {x, y} = value;".
+func (p *Parser) parseDestructuringLet() *ast.DestructuringLet {
+	stmt := &ast.DestructuringLet{Token: p.curToken}
+	p.NextToken()
+	stmt.Pattern = p.curToken
+
+	closing := token.TokenType(token.RBRACKET)
+	if p.curTokenIs(token.LBRACE) {
+		closing = token.RBRACE
+	}
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, p.arena.Identifier(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}))
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.NextToken()
+	}
+	if !p.expectPeek(closing) || !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
+	p.checkDuplicates(stmt.Names)
 	p.NextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	p.endStatement()
 
+	return stmt
+}
+
+// parseForStatement parses "for (init; condition; post) { body }", where
+// each clause may be left empty.
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.NextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		if p.curTokenIs(token.LET) {
+			init := p.parseLetBinding()
+			if init == nil {
+				return nil
+			}
+			stmt.Init = init
+		} else {
+			init := p.arena.ExpressionStatement(ast.ExpressionStatement{Token: p.curToken})
+			init.Expression = p.parseExpression(LOWEST)
+			stmt.Init = init
+		}
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	p.NextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	p.NextToken()
+	if !p.curTokenIs(token.RPAREN) {
+		stmt.Post = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.NextToken()
 	}
-
 	return stmt
 }
 
 func (p *Parser) ParseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.curToken}
+	stmt := p.arena.ReturnStatement(ast.ReturnStatement{Token: p.curToken})
 
 	p.NextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
-		p.NextToken()
-	}
+	p.endStatement()
 
 	return stmt
 }
 
 func (p *Parser) ParseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt := p.arena.ExpressionStatement(ast.ExpressionStatement{Token: p.curToken})
 
 	stmt.Expression = p.parseExpression(LOWEST)
+	p.endStatement()
+
+	return stmt
+}
+
+// badStatement and badExpression cover the tokens from start to the current
+// one, where parsing gave up.
+func (p *Parser) badStatement(start token.Token) ast.Statement {
+	return &ast.BadStatement{From: start, To: p.curToken}
+}
 
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{From: start, To: p.curToken}
+}
+
+func (p *Parser) endStatement() {
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.NextToken()
+		return
 	}
-	return stmt
+	if p.opts.Features.Has(FeatureOptionalSemicolons) {
+		return
+	}
+	if p.curTokenIs(token.RBRACE) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		return
+	}
+	p.peekError(token.SEMICOLON)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	start := p.curToken
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > p.opts.maxDepth() {
+		p.abandon()
+		return p.badExpression(start)
+	}
+
 	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil && p.keywordAsIdent(&p.curToken) {
+		prefix = p.parseIdentifier
+	}
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return p.badExpression(start)
 	}
 	leftExp := prefix()
+	if leftExp == nil {
+		return p.badExpression(start)
+	}
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -175,22 +487,23 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 			return leftExp
 		}
 		p.NextToken()
-		leftExp = infix(leftExp)
+		if leftExp = infix(leftExp); leftExp == nil {
+			return p.badExpression(start)
+		}
 	}
 	return leftExp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return p.arena.Identifier(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	il := &ast.IntegerLiteral{Token: p.curToken}
+	il := p.arena.IntegerLiteral(ast.IntegerLiteral{Token: p.curToken})
 	val, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.report(p.curToken, diag.InvalidInteger, diag.Data{"literal": strconv.Quote(p.curToken.Literal)})
 		return nil
 	}
 	il.Value = val
@@ -198,10 +511,10 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	prefix := &ast.PrefixExpression{
+	prefix := p.arena.PrefixExpression(ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
-	}
+	})
 
 	p.NextToken()
 	prefix.Right = p.parseExpression(PREFIX)
@@ -210,18 +523,53 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	infix := &ast.InfixExpression{
+	infix := p.arena.InfixExpression(ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
-		Left:     left}
-	precedence := p.curPrecedence()
+		Left:     left})
+	precedence := p.rightPrecedence()
 	p.NextToken()
 	infix.Right = p.parseExpression(precedence)
 	return infix
 }
 
+func (p *Parser) parseRangeExpression(from ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{Token: p.curToken, From: from, Inclusive: p.curTokenIs(token.RANGE_EQ)}
+	precedence := p.curPrecedence()
+	p.NextToken()
+	exp.To = p.parseExpression(precedence)
+	return exp
+}
+
+// parseComparison parses a relational operator and any that directly
+// follow it, so 0 < x < 10 becomes a ComparisonChain rather than comparing
+// the Boolean 0 < x with 10. A parenthesized comparison is not extended.
+func (p *Parser) parseComparison(left ast.Expression) ast.Expression {
+	infix := p.parseInfixExpression(left).(*ast.InfixExpression)
+	if !isComparison(p.peekToken.Type) {
+		return infix
+	}
+
+	chain := &ast.ComparisonChain{
+		Token:     infix.Token,
+		Operands:  []ast.Expression{infix.Left, infix.Right},
+		Operators: []string{infix.Operator},
+	}
+	for isComparison(p.peekToken.Type) {
+		p.NextToken()
+		chain.Operators = append(chain.Operators, p.curToken.Literal)
+		p.NextToken()
+		chain.Operands = append(chain.Operands, p.parseExpression(LESSGREATER))
+	}
+	return chain
+}
+
+func isComparison(t token.TokenType) bool {
+	return t == token.LT || t == token.GT || t == token.LT_EQ || t == token.GT_EQ
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
-	b := &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+	b := p.arena.Boolean(ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)})
 	return b
 }
 
@@ -272,7 +620,10 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	function.Parameters = p.parseFunctionParameters()
+	function.Parameters, function.Rest = p.parseFunctionParameters()
+	if !p.parseTypeAnnotation(&function.ReturnType) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -281,33 +632,90 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return function
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameter list and returns the plain
+// parameters and the ...rest parameter, if any, which must come last. The
+// list may end with a trailing comma.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, *ast.Identifier) {
 	identifiers := []*ast.Identifier{}
+	var rest *ast.Identifier
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.NextToken()
-		return identifiers
+		return identifiers, nil
 	}
-	p.NextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
 
-	for p.peekTokenIs(token.COMMA) {
+	for {
 		p.NextToken()
+		isRest := p.curTokenIs(token.ELLIPSIS)
+		if isRest {
+			p.NextToken()
+		}
+		if !p.curTokenIs(token.IDENT) && !p.keywordAsIdent(&p.curToken) {
+			p.report(p.curToken, diag.UnexpectedToken, diag.Data{"expected": token.IDENT, "got": p.curToken.Type})
+			return nil, nil
+		}
+		ident := p.arena.Identifier(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.parseTypeAnnotation(&ident.Type) {
+			return nil, nil
+		}
+		switch {
+		case rest != nil:
+			p.report(rest.Token, diag.RestNotLast, diag.Data{"name": rest.Value})
+			return nil, nil
+		case isRest:
+			rest = ident
+		default:
+			identifiers = append(identifiers, ident)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.NextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
+	}
+	names := identifiers
+	if rest != nil {
+		names = append(append([]*ast.Identifier{}, identifiers...), rest)
+	}
+	p.checkDuplicates(names)
+
+	return identifiers, rest
+}
+
+// parseTypeAnnotation parses an optional ": type" after the current token
+// into *typ. It reports false if a colon is not followed by a type name.
+func (p *Parser) parseTypeAnnotation(typ **ast.TypeAnnotation) bool {
+	if !p.peekTokenIs(token.COLON) {
+		return true
 	}
+	p.NextToken()
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+	*typ = &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+	return true
+}
 
-	return identifiers
+// checkDuplicates reports every name that repeats an earlier one in names.
+func (p *Parser) checkDuplicates(names []*ast.Identifier) {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name.Value] {
+			p.report(name.Token, diag.DuplicateName, diag.Data{"name": name.Value})
+		}
+		seen[name.Value] = true
+	}
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{Token: p.curToken}
+	block := p.arena.BlockStatement(ast.BlockStatement{Token: p.curToken})
 	block.Statements = []ast.Statement{}
 
 	p.NextToken()
@@ -319,46 +727,119 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.NextToken()
 	}
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken
+	}
 	return block
 }
 
-func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseCallArguments()
+// parseAssignExpression parses "name = value". Assignment is right
+// associative, so a = b = 1 assigns right to left.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken}
+	name, ok := target.(*ast.Identifier)
+	if !ok {
+		p.report(p.curToken, diag.InvalidAssign, diag.Data{"target": target.String()})
+	}
+	exp.Name = name
+
+	precedence := p.rightPrecedence()
+	p.NextToken()
+	exp.Value = p.parseExpression(precedence)
+	if !ok {
+		return nil
+	}
 	return exp
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
-	if p.peekTokenIs(token.RPAREN) {
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
-	p.NextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	exp.Property = p.arena.Identifier(ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	return exp
+}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.NextToken()
-		p.NextToken()
-		args = append(args, p.parseExpression(LOWEST))
+func (p *Parser) parsePostfixExpression(target ast.Expression) ast.Expression {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+		return &ast.PostfixExpression{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
 	}
+	p.report(p.curToken, diag.InvalidAssign, diag.Data{"target": target.String()})
+	return nil
+}
 
-	if !p.expectPeek(token.RPAREN) {
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := p.arena.CallExpression(ast.CallExpression{Token: p.curToken, Function: function})
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if exp.Arguments == nil {
 		return nil
 	}
-	return args
+	exp.Rparen = p.curToken
+	return exp
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return p.arena.StringLiteral(ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+}
+
+// parseInterpolatedString parses the segments and embedded expressions of a
+// string containing ${...}, starting at its first STRING_SEGMENT. Empty
+// segments are dropped.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	for {
+		str.Parts = p.appendSegment(str.Parts)
+		if !p.expectPeek(token.INTERP_START) {
+			return nil
+		}
+		p.NextToken()
+		part := p.parseExpression(LOWEST)
+		if part == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, part)
+		if !p.expectPeek(token.INTERP_END) {
+			return nil
+		}
+		if p.peekTokenIs(token.STRING_END) {
+			p.NextToken()
+			str.Parts = p.appendSegment(str.Parts)
+			str.Close = p.curToken
+			return str
+		}
+		if !p.expectPeek(token.STRING_SEGMENT) {
+			return nil
+		}
+	}
+}
+
+func (p *Parser) appendSegment(parts []ast.Expression) []ast.Expression {
+	if p.curToken.Literal == "" {
+		return parts
+	}
+	return append(parts, p.parseStringLiteral())
+}
+
+func (p *Parser) parseCharLiteral() ast.Expression {
+	r, _ := utf8.DecodeRuneInString(p.curToken.Literal)
+	return &ast.CharLiteral{Token: p.curToken, Value: r}
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
-	array := &ast.ArrayLiteral{Token: p.curToken}
+	array := p.arena.ArrayLiteral(ast.ArrayLiteral{Token: p.curToken})
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.Rbracket = p.curToken
 
 	return array
 }
 
+// parseExpressionList parses comma-separated expressions up to end, allowing
+// a trailing comma after the last one.
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -369,8 +850,11 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	p.NextToken()
 	list = append(list, p.parseExpression(LOWEST))
 
-	for !p.peekTokenIs(end) {
+	for p.peekTokenIs(token.COMMA) {
 		p.NextToken()
+		if p.peekTokenIs(end) {
+			break
+		}
 		p.NextToken()
 		list = append(list, p.parseExpression(LOWEST))
 	}
@@ -381,13 +865,14 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	indexExp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	indexExp := p.arena.IndexExpression(ast.IndexExpression{Token: p.curToken, Left: left})
 	p.NextToken()
 	indexExp.Index = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	indexExp.Rbracket = p.curToken
 
 	return indexExp
 }
@@ -397,28 +882,64 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.EOF) {
+			break
+		}
 		p.NextToken()
 		key := p.parseExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
+			p.skipPast(token.LBRACE, token.RBRACE)
 			return nil
 		}
 		p.NextToken()
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
-		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			// Name both ways the pair could have ended, and suggest the
+			// comma unless the hash simply runs into the end of the input.
+			data := diag.Data{"expected": ", or }", "got": p.peekToken.Type, "insert": ","}
+			if p.peekTokenIs(token.EOF) {
+				data["insert"] = "}"
+			}
+			p.report(p.peekToken, diag.UnexpectedToken, data)
+			p.skipPast(token.LBRACE, token.RBRACE)
 			return nil
 		}
+		p.NextToken()
 	}
 
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 	return hash
 }
 
+// skipPast recovers from an error inside a bracketed construct by advancing
+// to the close token that balances the already consumed open token, so the
+// rest of the construct does not produce further errors.
+func (p *Parser) skipPast(open, close token.TokenType) {
+	depth := 1
+	for !p.peekTokenIs(token.EOF) {
+		p.NextToken()
+		switch p.curToken.Type {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -428,7 +949,7 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
-	if p.peekTokenIs(t) {
+	if p.peekTokenIs(t) || t == token.IDENT && p.keywordAsIdent(&p.peekToken) {
 		p.NextToken()
 		return true
 	} else {
@@ -437,6 +958,19 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// keywordAsIdent retypes tok as an IDENT if it is a keyword and the options
+// allow keywords as identifiers, and reports whether it did.
+func (p *Parser) keywordAsIdent(tok *token.Token) bool {
+	if !p.opts.AllowKeywordIdentifiers || tok.Type == token.IDENT {
+		return false
+	}
+	if tokenType, _ := p.opts.Keywords.Lookup(tok.Literal); tokenType != tok.Type {
+		return false
+	}
+	tok.Type = token.IDENT
+	return true
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -451,8 +985,11 @@ type (
 )
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		// The lexer has already reported the character.
+		return
+	}
+	p.report(p.curToken, diag.NoPrefixParse, diag.Data{"token": t})
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -462,6 +999,16 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// rightPrecedence is the precedence at which to parse the right operand of
+// the current infix operator. A right-associative operator parses it one
+// level lower, so the operand can take in another use of the operator.
+func (p *Parser) rightPrecedence() int {
+	if rightAssociative[p.curToken.Type] {
+		return p.curPrecedence() - 1
+	}
+	return p.curPrecedence()
+}
+
 func (p *Parser) curPrecedence() int {
 	if prec, ok := precedences[p.curToken.Type]; ok {
 		return prec
@@ -3,7 +3,10 @@ package parser
 import (
 	"fmt"
 	"simple-interpreter/ast"
+	"simple-interpreter/diag"
 	"simple-interpreter/lexer"
+	"simple-interpreter/token"
+	"strings"
 	"testing"
 )
 
@@ -42,6 +45,89 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetGroups(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1, y = x + 1;", "let x = 1, y = (x + 1);"},
+		{"let a = f(1, 2), b = [3, 4], c = 5", "let a = f(1, 2), b = [3, 4], c = 5;"},
+		{"for (let i = 0, j = 3; i < j; i++) {}", "for (let i = 0, j = 3; (i < j); (i++)) "},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		if got := program.Statements[0].String(); got != tt.expected {
+			t.Errorf("%q: String() = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	p := New(lexer.New("let x = 1, y = 2;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	group, ok := program.Statements[0].(*ast.LetGroup)
+	if !ok || len(group.Bindings) != 2 {
+		t.Fatalf("statement is not a LetGroup with 2 bindings. got=%#v", program.Statements[0])
+	}
+	testLetStatement(t, group.Bindings[0], "x")
+	testLetStatement(t, group.Bindings[1], "y")
+
+	p = New(lexer.New("let x = 1, 2;"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected next token to be IDENT, got INT instead" {
+		t.Errorf("errors = %q", errors)
+	}
+}
+
+func TestDestructuringLet(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedNames []string
+		expected      string
+	}{
+		{"let [a, b] = pair;", []string{"a", "b"}, "let [a, b] = pair;"},
+		{"let {x, y} = point", []string{"x", "y"}, "let {x, y} = point;"},
+		{"let [only] = [1 + 2];", []string{"only"}, "let [only] = [(1 + 2)];"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.DestructuringLet)
+		if !ok {
+			t.Fatalf("%q: statement is not *ast.DestructuringLet. got=%T", tt.input, program.Statements[0])
+		}
+		if len(stmt.Names) != len(tt.expectedNames) {
+			t.Fatalf("%q: got %d names, want %d", tt.input, len(stmt.Names), len(tt.expectedNames))
+		}
+		for i, name := range tt.expectedNames {
+			testIdentifier(t, stmt.Names[i], name)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("%q: String() = %q, want %q", tt.input, stmt.String(), tt.expected)
+		}
+	}
+
+	for input, expected := range map[string]string{
+		"let [] = x;":     "expected next token to be IDENT, got ] instead",
+		"let [a, b} = x;": "expected next token to be ], got } instead",
+		"let {a} x;":      "expected next token to be =, got IDENT instead",
+	} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) == 0 || errors[0] != expected {
+			t.Errorf("%q: errors = %q, want first %q", input, errors, expected)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, stmt ast.Statement, name string) bool {
 	if stmt.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", stmt.TokenLiteral())
@@ -68,11 +154,12 @@ func testLetStatement(t *testing.T, stmt ast.Statement, name string) bool {
 }
 
 func checkParserErrors(t *testing.T, p *Parser) {
-	if len(p.errors) == 0 {
+	errors := p.Errors()
+	if len(errors) == 0 {
 		return
 	}
-	t.Errorf("parser had %d errors", len(p.errors))
-	for _, msg := range p.errors {
+	t.Errorf("parser had %d errors", len(errors))
+	for _, msg := range errors {
 		t.Errorf("parser error: %q", msg)
 	}
 	t.FailNow()
@@ -107,6 +194,195 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestReturnStatementValues(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+		expected      string
+	}{
+		{"return 5;", 5, "return 5;"},
+		{"return true;", true, "return true;"},
+		{"return y;", "y", "return y;"},
+		{"return add(1, 2);", nil, "return add(1, 2);"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ReturnStatement. got=%T", program.Statements[0])
+		}
+		if tt.expectedValue != nil && !testLiteralExpression(t, stmt.ReturnValue, tt.expectedValue) {
+			return
+		}
+		if got := stmt.String(); got != tt.expected {
+			t.Errorf("stmt.String() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input     string
+		init      string
+		condition string
+		post      string
+		body      string
+	}{
+		{"for (let i = 0; i < 10; i = i + 1) { puts(i); }", "let i = 0;", "(i < 10)", "i = (i + 1)", "puts(i)"},
+		{"for (;;) { x }", "", "", "", "x"},
+		{"for (; i < 3;) { i = i + 1 }", "", "(i < 3)", "", "i = (i + 1)"},
+		{"for (i = 0; ; i = i * 2) {}", "i = 0", "", "i = (i * 2)", ""},
+		{"for (let i = 0; i; ) { }", "let i = 0;", "i", "", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("%q: stmt is not *ast.ForStatement. got=%T", tt.input, program.Statements[0])
+		}
+
+		clauses := []struct {
+			name     string
+			node     ast.Node
+			expected string
+		}{
+			{"init", stmt.Init, tt.init},
+			{"condition", stmt.Condition, tt.condition},
+			{"post", stmt.Post, tt.post},
+			{"body", stmt.Body, tt.body},
+		}
+		for _, c := range clauses {
+			got := ""
+			if c.node != nil {
+				got = c.node.String()
+			}
+			if got != c.expected {
+				t.Errorf("%q: %s = %q, want %q", tt.input, c.name, got, c.expected)
+			}
+		}
+	}
+}
+
+func TestForStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for let i = 0; i; i {}", "expected next token to be (, got LET instead"},
+		{"for (let i = 0 i < 3; i) {}", "expected next token to be ;, got IDENT instead"},
+		{"for (;; i = i + 1 {}", "expected next token to be ), got { instead"},
+		{"for (;;) x", "expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: errors = %q, want first %q", tt.input, errors, tt.expected)
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	p := New(lexer.New("a = b = 1 + 2;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if got := program.String(); got != "a = b = (1 + 2)" {
+		t.Errorf("program.String() = %q", got)
+	}
+
+	p = New(lexer.New("f(x) = 2; y"))
+	program = p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Code != diag.InvalidAssign || diags[0].Message != "cannot assign to f(x)" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+
+	// Diagnostic data must stay encodable, even for targets such as hash
+	// literals whose fields are not.
+	p = New(lexer.New("{1: 2} = 3;"))
+	p.ParseProgram()
+	var out strings.Builder
+	if err := diag.WriteJSON(&out, p.Diagnostics()); err != nil {
+		t.Errorf("WriteJSON failed: %v", err)
+	}
+}
+
+func TestPostfixExpressionTargets(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"i++", true},
+		{"counts[key]--", true},
+		{"for (let i = 0; i < 3; i++) {}", true},
+		{"f()++", false},
+		{"5--", false},
+		{"{1: 2}++", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diags := p.Diagnostics()
+		if tt.valid && len(diags) != 0 {
+			t.Errorf("%q: unexpected diagnostics %v", tt.input, diags)
+		}
+		if !tt.valid && (len(diags) != 1 || diags[0].Code != diag.InvalidAssign) {
+			t.Errorf("%q: diagnostics = %v, want one %s", tt.input, diags, diag.InvalidAssign)
+		}
+		if err := diag.WriteJSON(&strings.Builder{}, diags); err != nil {
+			t.Errorf("%q: WriteJSON failed: %v", tt.input, err)
+		}
+	}
+}
+
+func TestDoubleMinusBeforeOperand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"--x", "(-(-x))"},
+		{"-- x", "(-(-x))"},
+		{"1--1", "(1 - (-1))"},
+		{"a * b--c", "((a * b) - (-c))"},
+		{"x--(1)", "(x - (-1))"},
+		{"x--", "(x--)"},
+		{"x-- - 1", "((x--) - 1)"},
+		{"f(i--, j)", "f((i--), j)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: got=%q, want=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestMemberExpressionErrors(t *testing.T) {
+	p := New(lexer.New("a.1"))
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "expected next token to be IDENT, got INT instead" {
+		t.Errorf("errors = %q", errors)
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar"
 
@@ -166,6 +442,45 @@ func TestIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerBasePrefixes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0XfF", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		intLit, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if intLit.Value != tt.expected {
+			t.Errorf("%s: value = %d, want %d", tt.input, intLit.Value, tt.expected)
+		}
+		if intLit.TokenLiteral() != tt.input {
+			t.Errorf("%s: literal = %s, want the original spelling", tt.input, intLit.TokenLiteral())
+		}
+	}
+
+	for _, input := range []string{"0b102", "0xZZ", "0o8"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) != 1 || diags[0].Code != diag.InvalidInteger {
+			t.Errorf("%s: expected one %s diagnostic, got %v", input, diag.InvalidInteger, diags)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -315,6 +630,58 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"0..n + 1",
+			"(0..(n + 1))",
+		},
+		{
+			"a..=b * 2 == c",
+			"((a..=(b * 2)) == c)",
+		},
+		{
+			"len(1..xs[0])",
+			"len((1..(xs[0])))",
+		},
+		{
+			"a | b & c << 2 ^ ~d",
+			"((a | ((b & c) << 2)) ^ (~d))",
+		},
+		{
+			"a || b && c == d || !e",
+			"((a || (b && (c == d))) || (!e))",
+		},
+		{
+			"x = a && b",
+			"x = (a && b)",
+		},
+		{
+			"0 < x < 10",
+			"((0 < x) && (x < 10))",
+		},
+		{
+			"a <= b + 1 > c == d",
+			"(((a <= (b + 1)) && ((b + 1) > c)) == d)",
+		},
+		{
+			"(a < b) < c",
+			"((a < b) < c)",
+		},
+		{
+			"-i++ + a[0]--",
+			"((-(i++)) + ((a[0])--))",
+		},
+		{
+			"-a.b.c(1) * d.e[2]",
+			"((-((a.b).c)(1)) * ((d.e)[2]))",
+		},
+		{
+			"xs.push(1).len()",
+			"((xs.push)(1).len)()",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -639,6 +1006,178 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+		expectedString string
+	}{
+		{"fn(...xs) {};", []string{}, "xs", "fn(...xs)"},
+		{"fn(x, y, ...rest) { rest };", []string{"x", "y"}, "rest", "fn(x, y, ...rest)rest"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("%q: length parameters wrong. want %d, got=%d", tt.input, len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+		testLiteralExpression(t, function.Rest, tt.expectedRest)
+		if function.String() != tt.expectedString {
+			t.Errorf("%q: String() = %q, want %q", tt.input, function.String(), tt.expectedString)
+		}
+	}
+}
+
+func TestRestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...xs, y) {}", "rest parameter xs must be the last parameter"},
+		{"fn(...xs, ...ys) {}", "rest parameter xs must be the last parameter"},
+		{"fn(x, ...) {}", "expected next token to be IDENT, got ) instead"},
+		{"fn(x,,) {}", "expected next token to be IDENT, got , instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: errors = %q, want first %q", tt.input, errors, tt.expected)
+		}
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	tests := []struct {
+		input  string
+		errors []string
+	}{
+		{"fn(a, a) {}", []string{"a is bound more than once"}},
+		{"fn(a, b, ...a) {}", []string{"a is bound more than once"}},
+		{"fn(a, b, a, b) {}", []string{"a is bound more than once", "b is bound more than once"}},
+		{"let [x, y, x] = pair;", []string{"x is bound more than once"}},
+		{"let {k, k} = h;", []string{"k is bound more than once"}},
+		{"fn(a, b) { fn(a) { a } }", nil},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		if errors := p.Errors(); strings.Join(errors, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%q: errors = %q, want %q", tt.input, errors, tt.errors)
+		}
+	}
+
+	p := New(lexer.New("fn(a,\n   a) {}"))
+	p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) != 1 || diags[0].Code != diag.DuplicateName || diags[0].Range.Start.Line != 2 || diags[0].Range.Start.Column != 4 {
+		t.Errorf("expected a DuplicateName diagnostic at 2:4, got %v", diags)
+	}
+}
+
+func TestTrailingCommas(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "[1, 2]"},
+		{"[\n  1,\n  2,\n]", "[1, 2]"},
+		{"add(1, 2,)", "add(1, 2)"},
+		{"fn(a, b,) { a }", "fn(a, b)a"},
+		{"fn(a, ...rest,) { a }", "fn(a, ...rest)a"},
+		{`{"a": 1,}["a"]`, "({a:1}[a])"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{"[,]", "f(,)", "fn(,) {}", "[1,,]"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected a parse error", input)
+		}
+	}
+}
+
+func TestAssociativity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a = b = c", "a = b = c"},
+		{"a = b = 1 + 2", "a = b = (1 + 2)"},
+		{"a = b || c", "a = (b || c)"},
+		{"a - b - c", "((a - b) - c)"},
+		{"a ^ b ^ c", "((a ^ b) ^ c)"},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			if got := program.String(); got != tt.expected {
+				t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+			}
+		}
+	}
+	check()
+
+	p := New(lexer.New("a = b = c"))
+	program := p.ParseProgram()
+	outer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+	if inner, ok := outer.Value.(*ast.AssignExpression); !ok || inner.Name.Value != "b" {
+		t.Errorf("a = b = c should assign b first, got %#v", outer.Value)
+	}
+
+	rightAssociative[token.BIT_XOR] = true
+	defer delete(rightAssociative, token.BIT_XOR)
+	tests = []struct {
+		input    string
+		expected string
+	}{
+		{"a ^ b ^ c", "(a ^ (b ^ c))"},
+		{"a ^ b + c ^ d", "(a ^ ((b + c) ^ d))"},
+		{"a * b ^ c | d", "((a * b) ^ (c | d))"},
+	}
+	check()
+}
+
+func TestChainedCallAndIndexParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"matrix[0][1]", "((matrix[0])[1])"},
+		{"getFn()(3)", "getFn()(3)"},
+		{"arr[i](x)[j]", "((arr[i])(x)[j])"},
+		{"f(1)(2)(3)", "f(1)(2)(3)"},
+		{"-a[0](1)", "(-(a[0])(1))"},
+		{"a[0](1) * b()[2]", "((a[0])(1) * (b()[2]))"},
+		{"fn(x) { x }(1)[0]", "(fn(x)x(1)[0])"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -670,6 +1209,29 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestCallExpressionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add();", "add()"},
+		{"add(1, 2 * 3);", "add(1, (2 * 3))"},
+		{"f(g(), h(x))();", "f(g(), h(x))()"},
+		{"fn(x) { x }(5) + 1;", "(fn(x)x(5) + 1)"},
+		{"a + b(c) * d;", "(a + (b(c) * d))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q parsed as %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world";`
 
@@ -689,6 +1251,85 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `let s = "cost ${5}"; "${a}${b + 1}!";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program has %d statements, want 2", len(program.Statements))
+	}
+	let := program.Statements[0].(*ast.LetStatement)
+	str, ok := let.Value.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("let value not *ast.InterpolatedString. got=%T", let.Value)
+	}
+	if len(str.Parts) != 2 {
+		t.Fatalf("str.Parts has %d parts, want 2", len(str.Parts))
+	}
+	if segment, ok := str.Parts[0].(*ast.StringLiteral); !ok || segment.Value != "cost " {
+		t.Errorf("first part not the segment %q. got=%s", "cost ", str.Parts[0])
+	}
+	testIntegerLiteral(t, str.Parts[1], 5)
+
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	if got := stmt.Expression.String(); got != `"${a}${(b + 1)}!"` {
+		t.Errorf("String() wrong. got=%q", got)
+	}
+	if err := ast.Check(program); err != nil {
+		t.Errorf("parsed program fails ast.Check:\n%s", err)
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []string{
+		`"a ${} b"`,
+		`"a ${1 2} b"`,
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected parse errors", input)
+		}
+	}
+}
+
+func TestCharLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rune
+		str      string
+	}{
+		{`'a'`, 'a', `'a'`},
+		{`'\n'`, '\n', `'\n'`},
+		{`'\''`, '\'', `'\''`},
+		{`'π'`, 'π', `'π'`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		char, ok := stmt.Expression.(*ast.CharLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.CharLiteral. got=%T", stmt.Expression)
+		}
+		if char.Value != tt.expected {
+			t.Errorf("%s: value = %q, want %q", tt.input, char.Value, tt.expected)
+		}
+		if char.String() != tt.str {
+			t.Errorf("%s: String() = %s, want %s", tt.input, char.String(), tt.str)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -852,3 +1493,504 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		fix      string
+	}{
+		{`{"a" 1}`, "expected next token to be :, got INT instead", "insert `:`"},
+		{`{"a": 1 "b": 2}`, "expected next token to be , or }, got STRING instead", "insert `,`"},
+		{`{"a": 1`, "expected next token to be , or }, got EOF instead", "insert `}`"},
+		{`{"a": 1,`, "expected next token to be }, got EOF instead", "insert `}`"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got %v", tt.input, diags)
+			continue
+		}
+		if diags[0].Message != tt.expected || diags[0].Fix != tt.fix {
+			t.Errorf("%q: diagnostic = %q (fix %q), want %q (fix %q)", tt.input, diags[0].Message, diags[0].Fix, tt.expected, tt.fix)
+		}
+	}
+}
+
+func TestHashLiteralKeysAndTrailingComma(t *testing.T) {
+	p := New(lexer.New(`{"a": 1, f(): 2, 1 + 1: [3],}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	for key := range hash.Pairs {
+		switch key := key.(type) {
+		case *ast.StringLiteral, *ast.CallExpression, *ast.InfixExpression:
+		default:
+			t.Errorf("unexpected key %T (%s)", key, key)
+		}
+	}
+}
+
+func TestHashLiteralKeepsSourceOrder(t *testing.T) {
+	p := New(lexer.New(`{"b": 1, f(): 2, "a": 3, f(): 4}`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	var values []string
+	for _, key := range hash.OrderedKeys() {
+		values = append(values, hash.Pairs[key].String())
+	}
+	if strings.Join(values, " ") != "1 2 3 4" {
+		t.Errorf("keys out of source order. values=%v", values)
+	}
+	if got := hash.String(); got != "{b:1, f():2, a:3, f():4}" {
+		t.Errorf("hash.String() wrong. got=%q", got)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	input := `let add = fn(a, b, ...rest) { if (a < b) { return a + b * 2; } else { a - -b } };
+let [x, y] = [1, 2]; let s = "say \"hi\"\n\${x}", t = s;
+let u = "cost ${ x + 1 } \"${s}\"${y}";
+for (let i = 0; i < 10; i++) { x = y = (1 + 2) * 3; }
+let h = {"one": 1, "two": [1, 2, 3]};
+-a[0] + (-a)[0] + f(1)(2).len() + !(a == b) + (1..=3)[0] + (0 < x <= 10);`
+	expected := `let add = fn(a, b, ...rest) {
+	if (a < b) {
+		return a + b * 2;
+	} else {
+		a - -b;
+	};
+};
+let [x, y] = [1, 2];
+let s = "say \"hi\"\n\${x}", t = s;
+let u = "cost ${x + 1} \"${s}\"${y}";
+for (let i = 0; i < 10; i++) {
+	x = y = (1 + 2) * 3;
+}
+let h = {"one": 1, "two": [1, 2, 3]};
+-a[0] + (-a)[0] + f(1)(2).len() + !(a == b) + (1..=3)[0] + (0 < x <= 10);
+`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if err := ast.Check(program); err != nil {
+		t.Errorf("parsed program fails ast.Check:\n%s", err)
+	}
+	got := ast.Format(program, ast.FormatOptions{})
+	if got != expected {
+		t.Errorf("Format wrong.\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+
+	p = New(lexer.New(got))
+	reparsed := p.ParseProgram()
+	checkParserErrors(t, p)
+	if !ast.Equal(reparsed, program) {
+		t.Errorf("reparsed program differs.\nwant=%q\ngot =%q", program.String(), reparsed.String())
+	}
+}
+
+func TestFormatParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(a + b) + c", "a + b + c"},
+		{"a + (b + c)", "a + (b + c)"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"(a * b) + (c * d)", "a * b + c * d"},
+		{"(a + b) * (c + d)", "(a + b) * (c + d)"},
+		{"a || (b && c)", "a || b && c"},
+		{"(a || b) && c", "(a || b) && c"},
+		{"(a < b) == (c < d)", "a < b == c < d"},
+		{"(a < b) < c", "(a < b) < c"},
+		{"a < (b < c)", "a < (b < c)"},
+		{"a < b < c", "a < b < c"},
+		{"(1 + 2)..(3 * 4)", "1 + 2..3 * 4"},
+		{"-(a + b)", "-(a + b)"},
+		{"(-a)[0] + -(a[0])", "(-a)[0] + -a[0]"},
+		{"!(a == b)", "!(a == b)"},
+		{"(f(x))(y)", "f(x)(y)"},
+		{"x = (y = 1 + 2)", "x = y = 1 + 2"},
+		{"(a & b) | (c << 1)", "a & b | c << 1"},
+	}
+
+	for _, tt := range tests {
+		program, err := ParseSafe(tt.input)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.input, err)
+		}
+		got := strings.TrimSuffix(ast.Format(program, ast.FormatOptions{}), ";\n")
+		if got != tt.expected {
+			t.Errorf("Format(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+		reparsed, err := ParseSafe(got)
+		if err != nil {
+			t.Fatalf("%q: reparse: %s", got, err)
+		}
+		if !ast.Equal(reparsed, program) {
+			t.Errorf("%q: reparsed %q differs: %s vs %s", tt.input, got, reparsed.String(), program.String())
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1, [2, "x\ty"][0]) * -3;
+for (let i = 0; i < 3; i++) { {"k": i}.k }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	var spans []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Program, *ast.Identifier, *ast.IntegerLiteral:
+		default:
+			if n != nil {
+				spans = append(spans, input[n.Pos().Offset:n.End().Offset])
+			}
+		}
+		return true
+	})
+
+	expected := []string{
+		"let add = fn(a, b) {\n  a + b\n}",
+		"fn(a, b) {\n  a + b\n}",
+		"{\n  a + b\n}",
+		"a + b",
+		"a + b",
+		`add(1, [2, "x\ty"][0]) * -3`,
+		`add(1, [2, "x\ty"][0]) * -3`,
+		`add(1, [2, "x\ty"][0])`,
+		`[2, "x\ty"][0]`,
+		`[2, "x\ty"]`,
+		`"x\ty"`,
+		"-3",
+		`for (let i = 0; i < 3; i++) { {"k": i}.k }`,
+		"let i = 0",
+		"i < 3",
+		"i++",
+		`{ {"k": i}.k }`,
+		`{"k": i}.k`,
+		`{"k": i}.k`,
+		`{"k": i}`,
+		`"k"`,
+	}
+	if strings.Join(spans, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong node spans.\nwant=%q\ngot =%q", expected, spans)
+	}
+
+	if pos := program.Statements[1].Pos(); pos.Line != 4 || pos.Column != 1 {
+		t.Errorf("second statement at %d:%d, want 4:1", pos.Line, pos.Column)
+	}
+	if end := program.End(); end.Line != 5 || end.Offset != len(input) {
+		t.Errorf("program ends at line %d offset %d, want line 5 offset %d", end.Line, end.Offset, len(input))
+	}
+}
+
+func TestParseExpressionString(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{"1 + 2 * 3", "(1 + (2 * 3))", ""},
+		{"  max(a, b)[0]  ", "(max(a, b)[0])", ""},
+		{"x = y = 1", "x = y = 1", ""},
+		{"1 + 2;", "", "expected next token to be EOF, got ; instead"},
+		{"1 2", "", "expected next token to be EOF, got INT instead"},
+		{"let x = 1", "", "no prefix parse function for LET found"},
+		{"", "", "no prefix parse function for EOF found"},
+		{"(1 + ", "", "no prefix parse function for EOF found"},
+	}
+
+	for _, tt := range tests {
+		exp, err := ParseExpressionString(tt.input)
+		if tt.expectedError != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expectedError) {
+				t.Errorf("ParseExpressionString(%q) wrong error. expected=%q, got=%v", tt.input, tt.expectedError, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseExpressionString(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if exp.String() != tt.expected {
+			t.Errorf("ParseExpressionString(%q) = %q, want %q", tt.input, exp.String(), tt.expected)
+		}
+	}
+}
+
+func TestParseSafe(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x = 5;", ""},
+		{"[1, 2", "expected next token to be ], got EOF instead"},
+		{"[1 2]", "expected next token to be ], got INT instead"},
+		{"fn(1) {}", "expected next token to be IDENT, got INT instead"},
+		{"let = 5;", "expected next token to be IDENT, got = instead"},
+	}
+
+	for _, tt := range tests {
+		program, err := ParseSafe(tt.input)
+		if tt.expectedError == "" {
+			if err != nil {
+				t.Errorf("ParseSafe(%q) returned error: %s", tt.input, err)
+			}
+			if program == nil {
+				t.Errorf("ParseSafe(%q) returned nil program", tt.input)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("ParseSafe(%q) expected error %q, got none", tt.input, tt.expectedError)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expectedError) {
+			t.Errorf("ParseSafe(%q) wrong error. expected=%q, got=%q",
+				tt.input, tt.expectedError, err.Error())
+		}
+	}
+}
+
+func FuzzParseProgram(f *testing.F) {
+	seeds := []string{
+		"let x = 5; return x;",
+		"fn(x, y) { if (x < y) { x } else { y } }(1, 2)",
+		`{"a": [1, 2][0], true: fn() {}}`,
+		"[1, 2",
+		"let",
+		"((((",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			_ = program.String()
+		}
+	})
+}
+
+func TestParsingWithArena(t *testing.T) {
+	input := `
+	let add = fn(a, b) { return a + b; };
+	let result = add(-1, [1, 2 * 3][1]);
+	if (result > 5) { "big" } else { false }
+	`
+
+	plain := New(lexer.New(input))
+	expected := plain.ParseProgram()
+	checkParserErrors(t, plain)
+
+	arena := ast.NewArena()
+	p := New(lexer.New(input))
+	p.UseArena(arena)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != expected.String() {
+		t.Errorf("arena parse differs. want=%q, got=%q", expected.String(), program.String())
+	}
+}
+
+func benchmarkParseProgram(b *testing.B, useArena bool) {
+	input := strings.Repeat(`let add = fn(a, b) { return a + b * 2; }; add(add(1, 2), [1, 2, 3][0]);`, 1000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := New(lexer.New(input))
+		if useArena {
+			p.UseArena(ast.NewArena())
+		}
+		p.ParseProgram()
+	}
+}
+
+func BenchmarkParseProgram(b *testing.B)          { benchmarkParseProgram(b, false) }
+func BenchmarkParseProgramWithArena(b *testing.B) { benchmarkParseProgram(b, true) }
+
+func TestParserDiagnostics(t *testing.T) {
+	p := New(lexer.New("let x = (1 + 2; let y = @;"))
+	p.ParseProgram()
+
+	diags := p.Diagnostics()
+	codes := []diag.Code{diag.IllegalCharacter, diag.UnexpectedToken}
+	if len(diags) != len(codes) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(codes), len(diags), diags)
+	}
+	for i, code := range codes {
+		if diags[i].Code != code {
+			t.Errorf("diagnostic %d code = %s, want %s", i, diags[i].Code, code)
+		}
+	}
+	if diags[1].Fix != "insert `)`" {
+		t.Errorf("unexpected fix %q", diags[1].Fix)
+	}
+	if len(p.Errors()) != len(diags) {
+		t.Errorf("Errors() returned %d messages, want %d", len(p.Errors()), len(diags))
+	}
+}
+
+func TestParserDiagnosticPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"let x = (1 + 2;", 1, 15},
+		{"let a = 1;\nlet = 2;", 2, 5},
+		{"let a = 1;\n  let b = 0b2;", 2, 11},
+		{"\n\n  ]", 3, 3},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%q: expected a diagnostic", tt.input)
+			continue
+		}
+		start := diags[0].Range.Start
+		if start.Line != tt.line || start.Column != tt.column {
+			t.Errorf("%q: diagnostic at %d:%d, want %d:%d (%s)", tt.input, start.Line, start.Column, tt.line, tt.column, diags[0])
+		}
+	}
+
+	p := New(lexer.NewFile("lib.mk", "let = 2;"))
+	p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) == 0 || diags[0].Range.Start.File != "lib.mk" {
+		t.Errorf("expected a diagnostic in lib.mk, got %v", diags)
+	}
+}
+
+func TestDiagnosticExcerpts(t *testing.T) {
+	p := New(lexer.New("let a = 1;\nlet b 2;"))
+	p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		t.Fatal("expected a diagnostic")
+	}
+	d := diags[0]
+	if d.Range.End.Line != 2 || d.Range.End.Column != 8 {
+		t.Errorf("diagnostic ends at %d:%d, want 2:8", d.Range.End.Line, d.Range.End.Column)
+	}
+	if d.Data["found"] != "2" {
+		t.Errorf("found = %v, want 2", d.Data["found"])
+	}
+	if expected := "let b 2;\n      ^"; d.Excerpt != expected {
+		t.Errorf("excerpt wrong.\nwant=%q\ngot =%q", expected, d.Excerpt)
+	}
+
+	p = New(lexer.NewReader(strings.NewReader("let b 2;")))
+	p.ParseProgram()
+	if diags := p.Diagnostics(); len(diags) == 0 || diags[0].Excerpt != "" {
+		t.Errorf("expected a diagnostic without excerpt from a reader, got %v", diags)
+	}
+}
+
+func TestBadNodes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		bad      string
+	}{
+		{"let x = 1 + ;", "let x = (1 + <bad expression>);", "1:13-1:14"},
+		{"let = 5;", "<bad statement><bad expression>5", "1:1-1:4"},
+		{"f(1, (2 + 3);", "<bad expression>", "1:1-1:13"},
+		{"for (;;) x", "<bad statement>x", "1:1-1:9"},
+		{"[1, *2]", "<bad expression>2<bad expression>", "1:1-1:6"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", tt.input)
+		}
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: program.String() wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+
+		var spans []string
+		ast.Inspect(program, func(node ast.Node) bool {
+			switch node.(type) {
+			case *ast.BadExpression, *ast.BadStatement:
+				pos, end := node.Pos(), node.End()
+				spans = append(spans, fmt.Sprintf("%d:%d-%d:%d", pos.Line, pos.Column, end.Line, end.Column))
+			}
+			return true
+		})
+		if len(spans) == 0 || spans[0] != tt.bad {
+			t.Errorf("%q: bad node spans wrong. want first=%s, got=%v", tt.input, tt.bad, spans)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	input := `let x: int = 5; let f = fn(a: int, b, ...rest: array): string { a };`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if let.Name.Type == nil || let.Name.Type.Name != "int" {
+		t.Errorf("let type wrong. got=%v", let.Name.Type)
+	}
+	fn := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if fn.Parameters[0].Type == nil || fn.Parameters[0].Type.Name != "int" {
+		t.Errorf("parameter a type wrong. got=%v", fn.Parameters[0].Type)
+	}
+	if fn.Parameters[1].Type != nil {
+		t.Errorf("parameter b should have no type. got=%v", fn.Parameters[1].Type)
+	}
+	if fn.Rest.Type == nil || fn.Rest.Type.Name != "array" {
+		t.Errorf("rest type wrong. got=%v", fn.Rest.Type)
+	}
+	if fn.ReturnType == nil || fn.ReturnType.Name != "string" {
+		t.Errorf("return type wrong. got=%v", fn.ReturnType)
+	}
+
+	expected := "let x: int = 5;let f = fn(a: int, b, ...rest: array): stringa;"
+	if got := program.String(); got != expected {
+		t.Errorf("program.String() wrong. want=%q, got=%q", expected, got)
+	}
+	formatted := ast.Format(program, ast.FormatOptions{})
+	reparsed := New(lexer.New(formatted)).ParseProgram()
+	if !ast.Equal(program, reparsed) {
+		t.Errorf("annotations lost formatting. got:\n%s", formatted)
+	}
+
+	for _, input := range []string{"let x: = 5;", "fn(a:) { a }", "fn(a): { a }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%q: expected parser errors", input)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type FunctionStats struct {
+	Name      string
+	Calls     int
+	TotalTime time.Duration
+	SelfTime  time.Duration
+	// AllocBytes and AllocCount are approximate. They count everything the
+	// process allocated while the function ran, including other goroutines
+	// and the interpreter itself, and the heap counters they come from are
+	// sampled at most once per AllocSampleInterval, so short calls often
+	// show none.
+	AllocBytes uint64
+	AllocCount uint64
+}
+
+// AllocSampleInterval is the least time between two reads of the process's
+// allocation counters. Reading them on every call would add to the very
+// timings being measured.
+const AllocSampleInterval = 10 * time.Millisecond
+
+type frame struct {
+	stats      *FunctionStats
+	start      time.Time
+	childTime  time.Duration
+	allocBytes uint64
+	allocCount uint64
+}
+
+type Profiler struct {
+	stats  map[string]*FunctionStats
+	stack  []*frame
+	active map[string]int
+
+	// The allocation counters as of sampledAt.
+	samples   []metrics.Sample
+	sampledAt time.Time
+}
+
+func New() *Profiler {
+	return &Profiler{
+		stats:  make(map[string]*FunctionStats),
+		active: make(map[string]int),
+		samples: []metrics.Sample{
+			{Name: "/gc/heap/allocs:bytes"},
+			{Name: "/gc/heap/allocs:objects"},
+		},
+	}
+}
+
+func (p *Profiler) Attach(e *evaluator.Evaluator) {
+	e.Hooks.OnCall = p.enter
+	e.Hooks.OnReturn = p.exit
+}
+
+func (p *Profiler) enter(call *ast.CallExpression, fn object.Object, args []object.Object) {
+	name := functionName(call, fn)
+	stats, ok := p.stats[name]
+	if !ok {
+		stats = &FunctionStats{Name: name}
+		p.stats[name] = stats
+	}
+	stats.Calls++
+	p.active[name]++
+
+	now := time.Now()
+	bytes, count := p.readAllocs(now)
+	p.stack = append(p.stack, &frame{
+		stats:      stats,
+		start:      now,
+		allocBytes: bytes,
+		allocCount: count,
+	})
+}
+
+func (p *Profiler) exit(call *ast.CallExpression, fn object.Object, result object.Object) {
+	if len(p.stack) == 0 {
+		return
+	}
+	now := time.Now()
+	bytes, count := p.readAllocs(now)
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	elapsed := now.Sub(f.start)
+
+	f.stats.SelfTime += elapsed - f.childTime
+	p.active[f.stats.Name]--
+	// Recursive calls are already covered by the outermost frame of the
+	// same function, so only that frame contributes inclusive totals.
+	if p.active[f.stats.Name] == 0 {
+		f.stats.TotalTime += elapsed
+		f.stats.AllocBytes += bytes - f.allocBytes
+		f.stats.AllocCount += count - f.allocCount
+	}
+
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].childTime += elapsed
+	}
+}
+
+func (p *Profiler) Report() []FunctionStats {
+	report := make([]FunctionStats, 0, len(p.stats))
+	for _, stats := range p.stats {
+		report = append(report, *stats)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].SelfTime != report[j].SelfTime {
+			return report[i].SelfTime > report[j].SelfTime
+		}
+		return report[i].Name < report[j].Name
+	})
+	return report
+}
+
+func (p *Profiler) WriteReport(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "calls\tself\ttotal\tallocs\tbytes\t function")
+	for _, stats := range p.Report() {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t %s\n",
+			stats.Calls, stats.SelfTime, stats.TotalTime,
+			stats.AllocCount, stats.AllocBytes, stats.Name)
+	}
+	w.Flush()
+}
+
+func functionName(call *ast.CallExpression, fn object.Object) string {
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	if fn.Type() == object.BUILTIN_OBJ {
+		return "<builtin>"
+	}
+	return "<anonymous>"
+}
+
+// readAllocs returns the process's total allocated bytes and objects,
+// reading them again only if the last sample is older than
+// AllocSampleInterval. runtime/metrics is used rather than
+// runtime.ReadMemStats, which stops the world.
+func (p *Profiler) readAllocs(now time.Time) (uint64, uint64) {
+	if p.sampledAt.IsZero() || now.Sub(p.sampledAt) >= AllocSampleInterval {
+		metrics.Read(p.samples)
+		p.sampledAt = now
+	}
+	return counter(p.samples[0]), counter(p.samples[1])
+}
+
+func counter(s metrics.Sample) uint64 {
+	if s.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s.Value.Uint64()
+}
@@ -0,0 +1,81 @@
+package profiler
+
+import (
+	"bytes"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/lexer"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfilerCountsCalls(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	let double = fn(x) { x * 2 };
+	double(fib(10));
+	len([1, 2, 3]);
+	fn(x) { x }(1);
+	`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	e := evaluator.New()
+	prof := New()
+	prof.Attach(e)
+	e.Eval(program, object.NewEnvironment())
+
+	expectedCalls := map[string]int{
+		"fib":         177,
+		"double":      1,
+		"len":         1,
+		"<anonymous>": 1,
+	}
+
+	report := prof.Report()
+	if len(report) != len(expectedCalls) {
+		t.Fatalf("report has wrong number of entries. got=%d, want=%d",
+			len(report), len(expectedCalls))
+	}
+	for _, stats := range report {
+		want, ok := expectedCalls[stats.Name]
+		if !ok {
+			t.Errorf("unexpected function in report: %q", stats.Name)
+			continue
+		}
+		if stats.Calls != want {
+			t.Errorf("wrong call count for %q. got=%d, want=%d",
+				stats.Name, stats.Calls, want)
+		}
+		if stats.SelfTime > stats.TotalTime {
+			t.Errorf("self time of %q exceeds total time. self=%s, total=%s",
+				stats.Name, stats.SelfTime, stats.TotalTime)
+		}
+	}
+
+	var out bytes.Buffer
+	prof.WriteReport(&out)
+	if !strings.Contains(out.String(), "fib") {
+		t.Errorf("report does not mention fib. got=%q", out.String())
+	}
+}
+
+var sink []byte
+
+func TestAllocsAreSampled(t *testing.T) {
+	prof := New()
+	start := time.Now()
+	bytes1, _ := prof.readAllocs(start)
+	sink = make([]byte, 1<<20)
+	if bytes2, _ := prof.readAllocs(start.Add(AllocSampleInterval / 2)); bytes2 != bytes1 {
+		t.Errorf("counters read again within the sample interval. before=%d, after=%d", bytes1, bytes2)
+	}
+	if bytes3, _ := prof.readAllocs(start.Add(AllocSampleInterval)); bytes3 < bytes1+1<<20 {
+		t.Errorf("counters not refreshed after the sample interval. before=%d, after=%d", bytes1, bytes3)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"simple-interpreter/diag"
 	"simple-interpreter/evaluator"
 	"simple-interpreter/lexer"
 	"simple-interpreter/object"
 	"simple-interpreter/parser"
+	"strings"
 )
 
 const PROMPT = ">> "
@@ -30,7 +32,7 @@ func Start(in io.Reader, out io.Writer) {
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, p.Diagnostics())
 			continue
 		}
 
@@ -42,8 +44,8 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func printParserErrors(out io.Writer, diags []diag.Diagnostic) {
+	for _, d := range diags {
+		io.WriteString(out, "\t"+strings.ReplaceAll(d.String(), "\n", "\n\t")+"\n")
 	}
 }
@@ -0,0 +1,282 @@
+// Package scope resolves the names in a program to the bindings they refer
+// to, following the scoping rules of the evaluator: functions and for loops
+// open a scope, while the blocks of an if share the scope around them.
+package scope
+
+import (
+	"simple-interpreter/ast"
+	"simple-interpreter/diag"
+	"sort"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Let Kind = iota
+	Parameter
+)
+
+func (k Kind) String() string {
+	if k == Parameter {
+		return "parameter"
+	}
+	return "let"
+}
+
+// A Symbol is one binding of a name. Declaring a name again in the same
+// scope, as let x = 1; let x = 2; does, makes a new Symbol.
+type Symbol struct {
+	Name  string
+	Kind  Kind
+	Decl  *ast.Identifier
+	Scope *Scope
+	Uses  []*ast.Identifier
+}
+
+type Scope struct {
+	// Node is the *ast.Program, *ast.FunctionLiteral, *ast.ForStatement or
+	// *ast.ForInStatement that opened the scope.
+	Node     ast.Node
+	Parent   *Scope
+	Children []*Scope
+	// Symbols are the bindings declared directly in the scope, in order.
+	Symbols []*Symbol
+
+	current map[string]*Symbol
+	// later counts the declarations of each name not yet reached, and
+	// pending holds uses from nested functions waiting for them.
+	later   map[string]int
+	pending map[string][]*ast.Identifier
+}
+
+// Lookup returns the latest binding of name visible from s.
+func (s *Scope) Lookup(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym := s.current[name]; sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+func (s *Scope) isFunction() bool {
+	_, ok := s.Node.(*ast.FunctionLiteral)
+	return ok
+}
+
+// Table is the result of Resolve.
+type Table struct {
+	Global *Scope
+	// Refs maps each identifier that reads or assigns a binding to it.
+	// Names bound nowhere in the program, such as builtins, are absent.
+	Refs map[*ast.Identifier]*Symbol
+	// Diagnostics holds a UseBeforeDefinition error for each use of a name
+	// ahead of its let, and an UnusedBinding warning for each let whose
+	// name is never used, in source order. Names starting with _ are
+	// never reported as unused.
+	Diagnostics []diag.Diagnostic
+}
+
+// Resolve builds the symbol table for program.
+func Resolve(program *ast.Program) *Table {
+	r := &resolver{table: &Table{Refs: make(map[*ast.Identifier]*Symbol)}}
+	r.table.Global = r.open(program, program)
+	for _, stmt := range program.Statements {
+		r.walk(stmt)
+	}
+
+	for _, sym := range r.symbols {
+		if sym.Kind == Let && len(sym.Uses) == 0 && !strings.HasPrefix(sym.Name, "_") {
+			d := r.diagnostic(sym.Decl, diag.UnusedBinding)
+			d.Severity = diag.Warning
+			r.table.Diagnostics = append(r.table.Diagnostics, d)
+		}
+	}
+	sort.SliceStable(r.table.Diagnostics, func(i, j int) bool {
+		return r.table.Diagnostics[i].Range.Start.Offset < r.table.Diagnostics[j].Range.Start.Offset
+	})
+	return r.table
+}
+
+type resolver struct {
+	table   *Table
+	scope   *Scope
+	symbols []*Symbol
+}
+
+func (r *resolver) walk(node ast.Node) {
+	if node != nil {
+		ast.Walk(r, node)
+	}
+}
+
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.Identifier:
+		r.reference(n, false)
+		return nil
+	case *ast.LetStatement:
+		r.walk(n.Value)
+		r.declare(n.Name, Let)
+		return nil
+	case *ast.DestructuringLet:
+		r.walk(n.Value)
+		for _, name := range n.Names {
+			r.declare(name, Let)
+		}
+		return nil
+	case *ast.FunctionLiteral:
+		r.open(n, n.Body)
+		for _, param := range n.Parameters {
+			r.declare(param, Parameter)
+		}
+		if n.Rest != nil {
+			r.declare(n.Rest, Parameter)
+		}
+		if n.Body != nil {
+			r.walk(n.Body)
+		}
+		r.close()
+		return nil
+	case *ast.ForStatement:
+		r.open(n, n.Init, n.Body)
+		r.walk(n.Init)
+		r.walk(n.Condition)
+		r.walk(n.Post)
+		if n.Body != nil {
+			r.walk(n.Body)
+		}
+		r.close()
+		return nil
+	case *ast.ForInStatement:
+		r.walk(n.Iterable)
+		r.open(n, n.Body)
+		if n.Key != nil {
+			r.declare(n.Key, Let)
+		}
+		if n.Value != nil {
+			r.declare(n.Value, Let)
+		}
+		if n.Body != nil {
+			r.walk(n.Body)
+		}
+		r.close()
+		return nil
+	case *ast.MemberExpression:
+		// The property is a hash key, not a variable.
+		r.walk(n.Object)
+		return nil
+	case *ast.CallExpression:
+		// obj.name(args) falls back to calling name(obj, args), so name
+		// is used if it is bound.
+		if member, ok := n.Function.(*ast.MemberExpression); ok {
+			r.walk(member.Object)
+			if member.Property != nil {
+				r.reference(member.Property, true)
+			}
+			for _, arg := range n.Arguments {
+				r.walk(arg)
+			}
+			return nil
+		}
+	}
+	return r
+}
+
+// open starts a scope for node whose declarations are found in body,
+// including those in the blocks of ifs but not in nested scopes.
+func (r *resolver) open(node ast.Node, body ...ast.Node) *Scope {
+	s := &Scope{
+		Node:    node,
+		Parent:  r.scope,
+		current: make(map[string]*Symbol),
+		later:   make(map[string]int),
+		pending: make(map[string][]*ast.Identifier),
+	}
+	if r.scope != nil {
+		r.scope.Children = append(r.scope.Children, s)
+	}
+	r.scope = s
+
+	for _, n := range body {
+		if n == nil || n == ast.Node((*ast.BlockStatement)(nil)) {
+			continue
+		}
+		ast.Inspect(n, func(child ast.Node) bool {
+			switch child := child.(type) {
+			case *ast.LetStatement:
+				s.later[child.Name.Value]++
+			case *ast.DestructuringLet:
+				for _, name := range child.Names {
+					s.later[name.Value]++
+				}
+			case *ast.FunctionLiteral, *ast.ForStatement, *ast.ForInStatement:
+				return false
+			}
+			return true
+		})
+	}
+	return s
+}
+
+func (r *resolver) close() {
+	r.scope = r.scope.Parent
+}
+
+func (r *resolver) declare(name *ast.Identifier, kind Kind) {
+	s := r.scope
+	sym := &Symbol{Name: name.Value, Kind: kind, Decl: name, Scope: s}
+	s.Symbols = append(s.Symbols, sym)
+	s.current[name.Value] = sym
+	r.symbols = append(r.symbols, sym)
+
+	if s.later[name.Value] > 0 {
+		s.later[name.Value]--
+	}
+	for _, use := range s.pending[name.Value] {
+		r.use(sym, use)
+	}
+	delete(s.pending, name.Value)
+}
+
+// reference resolves a use of ident. A function body can use a name its
+// enclosing scope only defines further on, since the body runs later, but
+// any other use ahead of the definition is reported unless optional is set.
+func (r *resolver) reference(ident *ast.Identifier, optional bool) {
+	name := ident.Value
+	crossed := false
+	var definedLater bool
+	for s := r.scope; s != nil; s = s.Parent {
+		if sym := s.current[name]; sym != nil {
+			r.use(sym, ident)
+			return
+		}
+		if s.later[name] > 0 {
+			if crossed {
+				s.pending[name] = append(s.pending[name], ident)
+				return
+			}
+			definedLater = true
+		}
+		if s.isFunction() {
+			crossed = true
+		}
+	}
+	if definedLater && !optional {
+		r.table.Diagnostics = append(r.table.Diagnostics, r.diagnostic(ident, diag.UseBeforeDefinition))
+	}
+}
+
+func (r *resolver) use(sym *Symbol, ident *ast.Identifier) {
+	sym.Uses = append(sym.Uses, ident)
+	r.table.Refs[ident] = sym
+}
+
+func (r *resolver) diagnostic(ident *ast.Identifier, code diag.Code) diag.Diagnostic {
+	d := diag.New(code, diag.Data{"name": ident.Value})
+	pos, end := ident.Pos(), ident.End()
+	d.Range.Start = diag.Position{File: pos.File, Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+	d.Range.End = diag.Position{File: end.File, Line: end.Line, Column: end.Column, Offset: end.Offset}
+	return d
+}
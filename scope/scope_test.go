@@ -0,0 +1,93 @@
+package scope
+
+import (
+	"fmt"
+	"simple-interpreter/ast"
+	"simple-interpreter/diag"
+	"simple-interpreter/parser"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) *Table {
+	t.Helper()
+	program, err := parser.ParseSafe(input)
+	if err != nil {
+		t.Fatalf("%q: %s", input, err)
+	}
+	return Resolve(program)
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"let x = 1;", []string{"1:5 warning[MKY3002]: x is defined but never used"}},
+		{"let _x = 1; let f = fn(unused) { 1 }; f()", nil},
+		{"x + 1; let x = 2; x", []string{"1:1 error[MKY3001]: x is used before it is defined"}},
+		{"let x = x + 1; x", []string{"1:9 error[MKY3001]: x is used before it is defined"}},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f()", nil},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)", nil},
+		{"let f = fn() { y; let y = 1; y }; f()", []string{"1:16 error[MKY3001]: y is used before it is defined"}},
+		{"let y = 0; let f = fn() { y; let y = 1; y }; f()", nil},
+		{"if (true) { let a = 1; } a", nil},
+		{"for (let i = 0; i < 3; i++) { let sq = i * i; puts(sq) }", nil},
+		{"for (let i = 0; i < 3; i++) { } let i = 1;", []string{"1:37 warning[MKY3002]: i is defined but never used"}},
+		{"let [a, b] = [1, 2]; a", []string{"1:9 warning[MKY3002]: b is defined but never used"}},
+		{"let x = 1; x = 2;", nil},
+		{"let len2 = fn(s) { len(s) * 2 }; let p = {}; p.len2()", nil},
+		{"let x = 1; let x = 2; x", []string{"1:5 warning[MKY3002]: x is defined but never used"}},
+	}
+
+	for _, tt := range tests {
+		table := resolve(t, tt.input)
+		var got []string
+		for _, d := range table.Diagnostics {
+			got = append(got, fmt.Sprintf("%d:%d %s[%s]: %s", d.Range.Start.Line, d.Range.Start.Column, d.Severity, d.Code, d.Message))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%q: diagnostics wrong.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	program, err := parser.ParseSafe("let x = 1; let f = fn(x, ...rest) { x + len(rest) }; f(x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := Resolve(program)
+
+	refs := map[string][]string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if sym, ok := table.Refs[ident]; ok {
+				pos := sym.Decl.Pos()
+				refs[ident.Value] = append(refs[ident.Value], fmt.Sprintf("%s@%d", sym.Kind, pos.Column))
+			}
+		}
+		return true
+	})
+	expected := map[string][]string{
+		"x":    {"parameter@23", "let@5"},
+		"rest": {"parameter@29"},
+		"f":    {"let@16"},
+	}
+	if fmt.Sprint(refs) != fmt.Sprint(expected) {
+		t.Errorf("refs wrong.\nwant=%v\ngot =%v", expected, refs)
+	}
+
+	if len(table.Global.Children) != 1 || len(table.Global.Children[0].Symbols) != 2 {
+		t.Fatalf("expected one function scope with two parameters")
+	}
+	if sym := table.Global.Children[0].Lookup("f"); sym == nil || sym.Scope != table.Global {
+		t.Errorf("Lookup from the function scope should find the global f")
+	}
+	if table.Global.Lookup("len") != nil {
+		t.Errorf("builtins should not resolve")
+	}
+	if d := table.Diagnostics; len(d) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diag.List(d))
+	}
+}
@@ -0,0 +1,320 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"sort"
+)
+
+const version = 1
+
+type snapshot struct {
+	Version int    `json:"version"`
+	Root    int    `json:"root"`
+	Envs    []*env `json:"envs"`
+}
+
+type env struct {
+	Outer int               `json:"outer"`
+	Vars  map[string]*value `json:"vars"`
+}
+
+type value struct {
+	Type     object.ObjectType `json:"type"`
+	Data     string            `json:"data,omitempty"`
+	Int      int64             `json:"int,omitempty"`
+	Bool     bool              `json:"bool,omitempty"`
+	Elements []*value          `json:"elements,omitempty"`
+	Pairs    [][2]*value       `json:"pairs,omitempty"`
+	Env      int               `json:"env,omitempty"`
+}
+
+type encoder struct {
+	snap     *snapshot
+	ids      map[*object.Environment]int
+	builtins map[*object.Builtin]string
+}
+
+func Save(w io.Writer, e *object.Environment) error {
+	enc := &encoder{
+		snap:     &snapshot{Version: version},
+		ids:      make(map[*object.Environment]int),
+		builtins: make(map[*object.Builtin]string),
+	}
+	for name, builtin := range evaluator.Builtins(evaluator.CapAll) {
+		enc.builtins[builtin] = name
+	}
+
+	root, err := enc.env(e)
+	if err != nil {
+		return err
+	}
+	enc.snap.Root = root
+
+	return json.NewEncoder(w).Encode(enc.snap)
+}
+
+func SaveFile(path string, e *object.Environment) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := Save(f, e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (enc *encoder) env(e *object.Environment) (int, error) {
+	if e == nil {
+		return -1, nil
+	}
+	if id, ok := enc.ids[e]; ok {
+		return id, nil
+	}
+
+	id := len(enc.snap.Envs)
+	enc.ids[e] = id
+	saved := &env{Vars: make(map[string]*value)}
+	enc.snap.Envs = append(enc.snap.Envs, saved)
+
+	outer, err := enc.env(e.Outer())
+	if err != nil {
+		return 0, err
+	}
+	saved.Outer = outer
+
+	for name, obj := range e.Bindings() {
+		v, err := enc.value(obj)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", name, err)
+		}
+		saved.Vars[name] = v
+	}
+	return id, nil
+}
+
+func (enc *encoder) value(obj object.Object) (*value, error) {
+	v := &value{Type: obj.Type()}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		v.Int = obj.Value
+	case *object.Boolean:
+		v.Bool = obj.Value
+	case *object.String:
+		v.Data = obj.Value
+	case *object.Null:
+	case *object.Array:
+		for _, el := range obj.Elements {
+			ev, err := enc.value(el)
+			if err != nil {
+				return nil, err
+			}
+			v.Elements = append(v.Elements, ev)
+		}
+	case *object.Hash:
+		pairs := make([]object.HashPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+		})
+
+		for _, pair := range pairs {
+			k, err := enc.value(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			val, err := enc.value(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			v.Pairs = append(v.Pairs, [2]*value{k, val})
+		}
+	case *object.Builtin:
+		name, ok := enc.builtins[obj]
+		if !ok {
+			return nil, fmt.Errorf("cannot snapshot unregistered builtin")
+		}
+		v.Data = name
+	case *object.Function:
+		id, err := enc.env(obj.Env)
+		if err != nil {
+			return nil, err
+		}
+		v.Env = id
+		fn := &ast.FunctionLiteral{Parameters: obj.Parameters, Rest: obj.Rest, Body: obj.Body}
+		v.Data = ast.Format(fn, ast.FormatOptions{})
+	default:
+		return nil, fmt.Errorf("cannot snapshot value of type %s", obj.Type())
+	}
+	return v, nil
+}
+
+type decoder struct {
+	snap     *snapshot
+	envs     []*object.Environment
+	builtins map[string]*object.Builtin
+}
+
+// Load restores the environment saved in r. Saved builtin values are looked
+// up in builtins, so a snapshot cannot grant builtins the caller did not.
+func Load(r io.Reader, builtins map[string]*object.Builtin) (*object.Environment, error) {
+	snap := &snapshot{}
+	if err := json.NewDecoder(r).Decode(snap); err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+	if snap.Version != version {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	dec := &decoder{
+		snap:     snap,
+		envs:     make([]*object.Environment, len(snap.Envs)),
+		builtins: builtins,
+	}
+	for id := range snap.Envs {
+		if _, err := dec.env(id, nil); err != nil {
+			return nil, err
+		}
+	}
+	for id, saved := range snap.Envs {
+		for name, v := range saved.Vars {
+			obj, err := dec.value(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			dec.envs[id].Set(name, obj)
+		}
+	}
+
+	if snap.Root < 0 || snap.Root >= len(dec.envs) {
+		return nil, fmt.Errorf("snapshot root environment %d out of range", snap.Root)
+	}
+	return dec.envs[snap.Root], nil
+}
+
+func LoadFile(path string, builtins map[string]*object.Builtin) (*object.Environment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f, builtins)
+}
+
+func (dec *decoder) env(id int, visiting map[int]bool) (*object.Environment, error) {
+	if id == -1 {
+		return nil, nil
+	}
+	if id < 0 || id >= len(dec.envs) {
+		return nil, fmt.Errorf("environment %d out of range", id)
+	}
+	if dec.envs[id] != nil {
+		return dec.envs[id], nil
+	}
+	if visiting == nil {
+		visiting = make(map[int]bool)
+	}
+	if visiting[id] {
+		return nil, fmt.Errorf("environment %d encloses itself", id)
+	}
+	visiting[id] = true
+
+	outer, err := dec.env(dec.snap.Envs[id].Outer, visiting)
+	if err != nil {
+		return nil, err
+	}
+	if outer == nil {
+		dec.envs[id] = object.NewEnvironment()
+	} else {
+		dec.envs[id] = object.NewEnclosedEnvironment(outer)
+	}
+	return dec.envs[id], nil
+}
+
+func (dec *decoder) value(v *value) (object.Object, error) {
+	switch v.Type {
+	case object.INTEGER_OBJ:
+		return &object.Integer{Value: v.Int}, nil
+	case object.BOOLEAN_OBJ:
+		if v.Bool {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case object.STRING_OBJ:
+		return &object.String{Value: v.Data}, nil
+	case object.NULL_OBJ:
+		return evaluator.NULL, nil
+	case object.ARRAY_OBJ:
+		elements := make([]object.Object, len(v.Elements))
+		for i, el := range v.Elements {
+			obj, err := dec.value(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return &object.Array{Elements: elements}, nil
+	case object.HASH_OBJ:
+		pairs := make(map[object.HashKey]object.HashPair, len(v.Pairs))
+		for _, p := range v.Pairs {
+			key, err := dec.value(p[0])
+			if err != nil {
+				return nil, err
+			}
+			val, err := dec.value(p[1])
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case object.BUILTIN_OBJ:
+		builtin, ok := dec.builtins[v.Data]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin %q", v.Data)
+		}
+		return builtin, nil
+	case object.FUNCTION_OBJ:
+		fn, err := parseFunction(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		closure, err := dec.env(v.Env, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Function{Parameters: fn.Parameters, Rest: fn.Rest, Body: fn.Body, Env: closure}, nil
+	default:
+		return nil, fmt.Errorf("cannot restore value of type %s", v.Type)
+	}
+}
+
+func parseFunction(src string) (*ast.FunctionLiteral, error) {
+	program, err := parser.ParseSafe(src)
+	if err != nil {
+		return nil, fmt.Errorf("restoring function: %w", err)
+	}
+	if len(program.Statements) == 1 {
+		if stmt, ok := program.Statements[0].(*ast.ExpressionStatement); ok {
+			if fn, ok := stmt.Expression.(*ast.FunctionLiteral); ok {
+				return fn, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("restoring function: %q is not a function literal", src)
+}
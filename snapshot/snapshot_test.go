@@ -0,0 +1,185 @@
+package snapshot
+
+import (
+	"bytes"
+	"path/filepath"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/lexer"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"testing"
+)
+
+func eval(t *testing.T, input string, env *object.Environment) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return evaluator.Eval(program, env)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	env := object.NewEnvironment()
+	eval(t, `
+	let name = "monkey";
+	let config = {"sizes": [1, 2, 3], "on": true, 1: first([])};
+	let newAdder = fn(x) { fn(y) { x + y } };
+	let addTwo = newAdder(2);
+	let classify = fn(n) {
+		if (n > 10) { return "big"; } else { let half = n / 2; -half; }
+	};
+	let size = len;
+	let sumTo = fn(n) {
+		let total = 0;
+		for (let i = 1; i < n + 1; i = i + 1) { total = total + i; }
+		total
+	};
+	`, env)
+
+	var buf bytes.Buffer
+	if err := Save(&buf, env); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	restored, err := Load(&buf, evaluator.Builtins(evaluator.CapNone))
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`name`, "monkey"},
+		{`config["sizes"][2] + size(config["sizes"])`, "6"},
+		{`config["on"]`, "true"},
+		{`config[1]`, "null"},
+		{`addTwo(40)`, "42"},
+		{`classify(11)`, "big"},
+		{`classify(4)`, "-2"},
+		{`sumTo(10)`, "55"},
+	}
+	for _, tt := range tests {
+		result := eval(t, tt.input, restored)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got=%s, want=%s", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestRestoredFunctionsKeepStrings(t *testing.T) {
+	env := object.NewEnvironment()
+	eval(t, `
+	let x = 1;
+	let quoted = fn() { "say \"hi\"" };
+	let slash = fn() { "a\\b" };
+	let lines = fn() { "one\ntwo" };
+	let literal = fn() { "\${x} is ${x}" };
+	`, env)
+
+	var buf bytes.Buffer
+	if err := Save(&buf, env); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	restored, err := Load(&buf, evaluator.Builtins(evaluator.CapNone))
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quoted()`, `say "hi"`},
+		{`slash()`, `a\b`},
+		{`lines()`, "one\ntwo"},
+		{`literal()`, "${x} is 1"},
+	}
+	for _, tt := range tests {
+		result := eval(t, tt.input, restored)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestSharedClosureEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	eval(t, `
+	let pair = fn(x) { [fn(y) { x + y }, fn(y) { x * y }] };
+	let fns = pair(21);
+	`, env)
+
+	var buf bytes.Buffer
+	if err := Save(&buf, env); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	restored, err := Load(&buf, evaluator.Builtins(evaluator.CapNone))
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+
+	fns, _ := restored.Get("fns")
+	elements := fns.(*object.Array).Elements
+	if elements[0].(*object.Function).Env != elements[1].(*object.Function).Env {
+		t.Errorf("closures that shared an environment were restored separately")
+	}
+	if result := eval(t, "fns[0](1) + fns[1](2)", restored); result.Inspect() != "64" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestSaveFileAndLoadFile(t *testing.T) {
+	env := object.NewEnvironment()
+	eval(t, `let counter = 41;`, env)
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := SaveFile(path, env); err != nil {
+		t.Fatalf("SaveFile returned error: %s", err)
+	}
+	restored, err := LoadFile(path, evaluator.Builtins(evaluator.CapNone))
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %s", err)
+	}
+	if result := eval(t, "counter + 1", restored); result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestLoadOnlyRestoresGrantedBuiltins(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("run", evaluator.Builtins(evaluator.CapExec)["exec"])
+
+	var buf bytes.Buffer
+	if err := Save(&buf, env); err != nil {
+		t.Fatalf("Save returned error: %s", err)
+	}
+	saved := buf.String()
+
+	if _, err := Load(bytes.NewBufferString(saved), evaluator.Builtins(evaluator.CapNone)); err == nil {
+		t.Errorf("Load restored exec without CapExec")
+	}
+	restored, err := Load(bytes.NewBufferString(saved), evaluator.Builtins(evaluator.CapExec))
+	if err != nil {
+		t.Fatalf("Load returned error: %s", err)
+	}
+	if run, _ := restored.Get("run"); run != evaluator.Builtins(evaluator.CapExec)["exec"] {
+		t.Errorf("run restored as %v, want the exec builtin", run)
+	}
+}
+
+func TestLoadRejectsBadSnapshots(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"version": 99, "root": 0, "envs": [{"outer": -1}]}`,
+		`{"version": 1, "root": 3, "envs": [{"outer": -1}]}`,
+		`{"version": 1, "root": 0, "envs": [{"outer": 0}]}`,
+		`{"version": 1, "root": 0, "envs": [{"outer": -1, "vars": {"f": {"type": "FUNCTION", "data": "1 +"}}}]}`,
+	}
+
+	for _, input := range tests {
+		if _, err := Load(bytes.NewBufferString(input), evaluator.Builtins(evaluator.CapNone)); err == nil {
+			t.Errorf("Load(%q) expected error", input)
+		}
+	}
+}
@@ -1,10 +1,27 @@
 package token
 
+import (
+	"fmt"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
 const (
 	//Identifiers/literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
+	CHAR   = "CHAR"
+
+	// An interpolated string "a ${x} b" is lexed as STRING_SEGMENT("a "),
+	// INTERP_START, the tokens of x, INTERP_END and STRING_END(" b"). Strings
+	// with several interpolations repeat the segment-interpolation pattern.
+	STRING_SEGMENT = "STRING_SEGMENT"
+	STRING_END     = "STRING_END"
+	INTERP_START   = "${"
+	INTERP_END     = "INTERP_END"
 
 	//Operators
 	ASSIGN   = "="
@@ -13,11 +30,33 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	EQ       = "=="
 	NOT_EQ   = "!="
 
-	GT = ">"
-	LT = "<"
+	GT    = ">"
+	LT    = "<"
+	GT_EQ = ">="
+	LT_EQ = "<="
+
+	AND = "&&"
+	OR  = "||"
+
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	BIT_NOT = "~"
+	SHL     = "<<"
+	SHR     = ">>"
+
+	PIPE = "|>"
+
+	INCREMENT = "++"
+	DECREMENT = "--"
+
+	ELLIPSIS = "..."
+	RANGE    = ".."
+	RANGE_EQ = "..="
 
 	//Delimiters
 	COMMA     = ","
@@ -29,6 +68,8 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	COLON     = ":"
+	QUESTION  = "?"
+	DOT       = "."
 
 	//Keywords
 	FUNCTION = "FUNCTION"
@@ -38,31 +79,186 @@ const (
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	RETURN   = "RETURN"
+	IN       = "IN"
+	FOR      = "FOR"
 
 	EOF     = "EOF"
 	ILLEGAL = "ILLEGAL"
+
+	// Trivia, only produced by a lexer with trivia enabled.
+	WHITESPACE = "WHITESPACE"
+	COMMENT    = "COMMENT"
 )
 
 type TokenType string
 
+// Token is a lexeme and where it starts in the source. Line and Column are
+// 1-based, with columns counted in runes; Offset is the byte offset. File
+// names the source the token came from, and is empty for anonymous input.
+// EndLine, EndColumn and EndOffset locate the character just past the
+// token; they are zero for tokens that were not produced by the lexer.
 type Token struct {
 	Type    TokenType
 	Literal string
+
+	File   string
+	Line   int
+	Column int
+	Offset int
+
+	EndLine   int
+	EndColumn int
+	EndOffset int
+}
+
+// Position is a location in source text, with fields as in Token.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
 }
 
-var keywords = map[string]TokenType{
-	"let":    LET,
-	"fn":     FUNCTION,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"return": RETURN,
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (t Token) Pos() Position {
+	return Position{File: t.File, Line: t.Line, Column: t.Column, Offset: t.Offset}
+}
+
+// End is the position just past the token. For tokens without a recorded
+// end it assumes the literal was spelled out on one line.
+func (t Token) End() Position {
+	if t.EndLine == 0 {
+		return Position{File: t.File, Line: t.Line, Column: t.Column + utf8.RuneCountInString(t.Literal), Offset: t.Offset + len(t.Literal)}
+	}
+	return Position{File: t.File, Line: t.EndLine, Column: t.EndColumn, Offset: t.EndOffset}
+}
+
+type keyword struct {
+	tokenType TokenType
+	literal   string
+}
+
+// keywords is the language's own keyword table. It is never modified after
+// init, so lexers on different goroutines can share it.
+var keywords = map[string]keyword{}
+
+func init() {
+	for literal, tokenType := range map[string]TokenType{
+		"let":    LET,
+		"fn":     FUNCTION,
+		"if":     IF,
+		"else":   ELSE,
+		"true":   TRUE,
+		"false":  FALSE,
+		"return": RETURN,
+		"in":     IN,
+		"for":    FOR,
+	} {
+		keywords[literal] = keyword{tokenType, literal}
+	}
 }
 
 func LookupIdent(ident string) TokenType {
-	if tok, ok := keywords[ident]; ok {
-		return tok
+	tokenType, _ := LookupKeyword(ident)
+	return tokenType
+}
+
+// LookupKeyword is LookupIdent that also returns the keyword's spelling
+// from the keyword table, or ident itself when it is not a keyword. The
+// lexer keeps the interned string so keyword tokens do not hold on to the
+// source they were read from.
+func LookupKeyword(ident string) (TokenType, string) {
+	if kw, ok := keywords[ident]; ok {
+		return kw.tokenType, kw.literal
+	}
+	return IDENT, ident
+}
+
+// Keywords holds keywords added on top of the language's own, so extensions
+// can add words such as "while" or "null" without editing this package. A
+// set only affects the lexers it is given, so one embedder's keywords do not
+// change how other scripts lex. The zero value has no extra keywords and is
+// safe for concurrent use.
+type Keywords struct {
+	mu    sync.RWMutex
+	extra map[string]keyword
+}
+
+// Register makes literal lex as a keyword of the given type. Registering a
+// keyword again with the same type is a no-op; giving an existing keyword a
+// different type is an error.
+func (k *Keywords) Register(literal string, tokenType TokenType) error {
+	if !isIdentifier(literal) {
+		return fmt.Errorf("keyword %q is not a valid identifier", literal)
+	}
+	if tokenType == "" || tokenType == IDENT {
+		return fmt.Errorf("keyword %q needs a token type other than %s", literal, IDENT)
+	}
+	if kw, ok := keywords[literal]; ok {
+		if kw.tokenType != tokenType {
+			return fmt.Errorf("keyword %q is already registered as %s", literal, kw.tokenType)
+		}
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if kw, ok := k.extra[literal]; ok && kw.tokenType != tokenType {
+		return fmt.Errorf("keyword %q is already registered as %s", literal, kw.tokenType)
+	}
+	if k.extra == nil {
+		k.extra = make(map[string]keyword)
+	}
+	k.extra[literal] = keyword{tokenType, literal}
+	return nil
+}
+
+// Unregister removes a keyword added by Register, so literal lexes as an
+// identifier again. The language's own keywords cannot be removed, and
+// removing a word that is not a keyword is a no-op.
+func (k *Keywords) Unregister(literal string) error {
+	if _, ok := keywords[literal]; ok {
+		return fmt.Errorf("keyword %q is built in and cannot be removed", literal)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.extra, literal)
+	return nil
+}
+
+// Lookup is LookupKeyword that also consults the registered keywords. A nil
+// set has none.
+func (k *Keywords) Lookup(ident string) (TokenType, string) {
+	if k != nil {
+		k.mu.RLock()
+		kw, ok := k.extra[ident]
+		k.mu.RUnlock()
+		if ok {
+			return kw.tokenType, kw.literal
+		}
+	}
+	return LookupKeyword(ident)
+}
+
+// isIdentifier mirrors the lexer's identifier rules: a letter or underscore
+// followed by letters, underscores and ASCII digits.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && '0' <= r && r <= '9':
+		default:
+			return false
+		}
 	}
-	return IDENT
+	return true
 }
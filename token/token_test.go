@@ -0,0 +1,79 @@
+package token
+
+import "testing"
+
+func TestKeywordsRegister(t *testing.T) {
+	var k Keywords
+	if got, _ := k.Lookup("unless"); got != IDENT {
+		t.Fatalf("Lookup(unless) = %s before registering, want %s", got, IDENT)
+	}
+	if err := k.Register("unless", "UNLESS"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if got, literal := k.Lookup("unless"); got != "UNLESS" || literal != "unless" {
+		t.Errorf("Lookup(unless) = %s, %q, want UNLESS, %q", got, literal, "unless")
+	}
+	if err := k.Register("unless", "UNLESS"); err != nil {
+		t.Errorf("registering the same keyword twice: %v", err)
+	}
+	if err := k.Register("let", LET); err != nil {
+		t.Errorf("registering a built-in keyword with its own type: %v", err)
+	}
+
+	errors := []struct {
+		literal   string
+		tokenType TokenType
+	}{
+		{"unless", "WHEN_NOT"},
+		{"let", "BINDING"},
+		{"", "EMPTY"},
+		{"2fast", "FAST"},
+		{"do-while", "DO_WHILE"},
+		{"loop", IDENT},
+		{"loop", ""},
+	}
+	for _, tt := range errors {
+		if err := k.Register(tt.literal, tt.tokenType); err == nil {
+			t.Errorf("Register(%q, %q) succeeded, want an error", tt.literal, tt.tokenType)
+		}
+	}
+	if got, _ := k.Lookup("let"); got != LET {
+		t.Errorf("Lookup(let) = %s, want %s", got, LET)
+	}
+}
+
+func TestKeywordsAreIndependent(t *testing.T) {
+	var a, b Keywords
+	if err := a.Register("unless", "UNLESS"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if got, _ := b.Lookup("unless"); got != IDENT {
+		t.Errorf("another set sees unless as %s, want %s", got, IDENT)
+	}
+	if got := LookupIdent("unless"); got != IDENT {
+		t.Errorf("LookupIdent(unless) = %s, want %s", got, IDENT)
+	}
+	var none *Keywords
+	if got, _ := none.Lookup("fn"); got != FUNCTION {
+		t.Errorf("nil set Lookup(fn) = %s, want %s", got, FUNCTION)
+	}
+}
+
+func TestKeywordsUnregister(t *testing.T) {
+	var k Keywords
+	if err := k.Register("until", "UNTIL"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := k.Unregister("until"); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	if got, _ := k.Lookup("until"); got != IDENT {
+		t.Errorf("Lookup(until) = %s after unregistering, want %s", got, IDENT)
+	}
+	if err := k.Unregister("let"); err == nil {
+		t.Errorf("Unregister(let) succeeded, want an error")
+	}
+	if got, _ := k.Lookup("let"); got != LET {
+		t.Errorf("Lookup(let) = %s, want %s", got, LET)
+	}
+}
@@ -0,0 +1,331 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"simple-interpreter/ast"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	EventNode    = "node"
+	EventBuiltin = "builtin"
+)
+
+type Header struct {
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+}
+
+type Event struct {
+	Kind  string   `json:"kind"`
+	Node  string   `json:"node,omitempty"`
+	Name  string   `json:"name,omitempty"`
+	Args  []*Value `json:"args,omitempty"`
+	Value *Value   `json:"value,omitempty"`
+}
+
+type Value struct {
+	Type     object.ObjectType `json:"type"`
+	Data     string            `json:"data,omitempty"`
+	Elements []*Value          `json:"elements,omitempty"`
+	Pairs    [][2]*Value       `json:"pairs,omitempty"`
+	Inspect  string            `json:"inspect,omitempty"`
+}
+
+type Trace struct {
+	Source string
+	Events []Event
+}
+
+type Recorder struct {
+	enc *json.Encoder
+	err error
+}
+
+func NewRecorder(w io.Writer, source string) *Recorder {
+	r := &Recorder{enc: json.NewEncoder(w)}
+	r.write(Header{Version: 1, Source: source})
+	return r
+}
+
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+func (r *Recorder) Attach(e *evaluator.Evaluator) {
+	e.Hooks.OnExitNode = func(node ast.Node, result object.Object) {
+		r.write(Event{Kind: EventNode, Node: nodeType(node), Value: EncodeValue(result)})
+	}
+
+	wrapped := make(map[string]*object.Builtin, len(e.Builtins))
+	for name, builtin := range e.Builtins {
+		name, fn := name, builtin.Fn
+		wrapped[name] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			result := fn(args...)
+			event := Event{Kind: EventBuiltin, Name: name, Value: EncodeValue(result)}
+			for _, arg := range args {
+				event.Args = append(event.Args, EncodeValue(arg))
+			}
+			r.write(event)
+			return result
+		}}
+	}
+	e.Builtins = wrapped
+}
+
+func (r *Recorder) write(v interface{}) {
+	if r.err == nil {
+		r.err = r.enc.Encode(v)
+	}
+}
+
+func Read(in io.Reader) (*Trace, error) {
+	dec := json.NewDecoder(bufio.NewReader(in))
+
+	var header Header
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("reading trace header: %w", err)
+	}
+	if header.Version != 1 {
+		return nil, fmt.Errorf("unsupported trace version %d", header.Version)
+	}
+
+	t := &Trace{Source: header.Source}
+	for {
+		var event Event
+		err := dec.Decode(&event)
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading trace event %d: %w", len(t.Events), err)
+		}
+		t.Events = append(t.Events, event)
+	}
+}
+
+type DivergenceError struct {
+	Index    int
+	Expected Event
+	Got      Event
+}
+
+func (d *DivergenceError) Error() string {
+	return fmt.Sprintf("replay diverged at event %d: expected %s, got %s",
+		d.Index, describeEvent(d.Expected), describeEvent(d.Got))
+}
+
+// Replay evaluates the trace's source with e, checking every event against
+// the recording. e must have the builtins the recording was made with,
+// including any loaded from plugins; they are not called, but each call is
+// answered with the recorded result once its arguments match. Replay
+// replaces e's hooks and builtins.
+func (t *Trace) Replay(e *evaluator.Evaluator) (object.Object, error) {
+	program, err := parser.ParseSafe(t.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	var divergence *DivergenceError
+
+	expect := func(got Event) *Event {
+		if divergence != nil {
+			return nil
+		}
+		if next >= len(t.Events) {
+			divergence = &DivergenceError{Index: next, Got: got}
+			return nil
+		}
+		expected := t.Events[next]
+		if !sameEvent(expected, got) {
+			divergence = &DivergenceError{Index: next, Expected: expected, Got: got}
+			return nil
+		}
+		next++
+		return &expected
+	}
+
+	e.Hooks.OnExitNode = func(node ast.Node, result object.Object) {
+		expect(Event{Kind: EventNode, Node: nodeType(node), Value: EncodeValue(result)})
+	}
+
+	replayed := make(map[string]*object.Builtin, len(e.Builtins))
+	for name := range e.Builtins {
+		name := name
+		replayed[name] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			event := Event{Kind: EventBuiltin, Name: name}
+			for _, arg := range args {
+				event.Args = append(event.Args, EncodeValue(arg))
+			}
+			recorded := expect(event)
+			if recorded == nil {
+				return &object.Error{Message: "replay diverged"}
+			}
+			result, err := DecodeValue(recorded.Value)
+			if err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			return result
+		}}
+	}
+	e.Builtins = replayed
+
+	result := e.Eval(program, object.NewEnvironment())
+	if divergence != nil {
+		return result, divergence
+	}
+	if next != len(t.Events) {
+		return result, &DivergenceError{Index: next, Expected: t.Events[next]}
+	}
+	return result, nil
+}
+
+func EncodeValue(obj object.Object) *Value {
+	if obj == nil {
+		return nil
+	}
+
+	v := &Value{Type: obj.Type()}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		v.Data = strconv.FormatInt(obj.Value, 10)
+	case *object.Boolean:
+		v.Data = strconv.FormatBool(obj.Value)
+	case *object.String:
+		v.Data = obj.Value
+	case *object.Error:
+		v.Data = obj.Message
+	case *object.Null:
+	case *object.ReturnValue:
+		v.Elements = []*Value{EncodeValue(obj.Value)}
+	case *object.Array:
+		v.Elements = make([]*Value, len(obj.Elements))
+		for i, el := range obj.Elements {
+			v.Elements[i] = EncodeValue(el)
+		}
+	case *object.Hash:
+		for _, pair := range obj.Pairs {
+			v.Pairs = append(v.Pairs, [2]*Value{EncodeValue(pair.Key), EncodeValue(pair.Value)})
+		}
+		sort.Slice(v.Pairs, func(i, j int) bool {
+			return describe(v.Pairs[i][0]) < describe(v.Pairs[j][0])
+		})
+	default:
+		v.Inspect = obj.Inspect()
+	}
+	return v
+}
+
+func DecodeValue(v *Value) (object.Object, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch v.Type {
+	case object.INTEGER_OBJ:
+		i, err := strconv.ParseInt(v.Data, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Integer{Value: i}, nil
+	case object.BOOLEAN_OBJ:
+		if v.Data == "true" {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case object.STRING_OBJ:
+		return &object.String{Value: v.Data}, nil
+	case object.ERROR_OBJ:
+		return &object.Error{Message: v.Data}, nil
+	case object.NULL_OBJ:
+		return evaluator.NULL, nil
+	case object.RETURN_VALUE_OBJ, object.ARRAY_OBJ:
+		elements := make([]object.Object, len(v.Elements))
+		for i, el := range v.Elements {
+			obj, err := DecodeValue(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		if v.Type == object.RETURN_VALUE_OBJ && len(elements) == 1 {
+			return &object.ReturnValue{Value: elements[0]}, nil
+		}
+		return &object.Array{Elements: elements}, nil
+	case object.HASH_OBJ:
+		pairs := make(map[object.HashKey]object.HashPair, len(v.Pairs))
+		for _, p := range v.Pairs {
+			key, err := DecodeValue(p[0])
+			if err != nil {
+				return nil, err
+			}
+			value, err := DecodeValue(p[1])
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+	case object.HANDLE_OBJ:
+		// Builtins that use the handle are replayed too, so it only needs to
+		// stand in for the original resource.
+		return &object.Handle{Kind: strings.Trim(v.Inspect, "<>")}, nil
+	default:
+		return nil, errors.New("cannot replay value of type " + string(v.Type))
+	}
+}
+
+func sameEvent(expected, got Event) bool {
+	if expected.Kind != got.Kind || expected.Node != got.Node || expected.Name != got.Name {
+		return false
+	}
+	if got.Kind == EventBuiltin {
+		// The result of a replayed builtin comes from the recording, so
+		// only its arguments can diverge.
+		return describeArgs(expected.Args) == describeArgs(got.Args)
+	}
+	return describe(expected.Value) == describe(got.Value)
+}
+
+func describeEvent(ev Event) string {
+	s := ev.Kind + " " + ev.Node + ev.Name
+	if ev.Kind == EventBuiltin {
+		s += describeArgs(ev.Args)
+	}
+	return s + describe(ev.Value)
+}
+
+func describeArgs(args []*Value) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		b, _ := json.Marshal(arg)
+		parts[i] = string(b)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func describe(v *Value) string {
+	if v == nil {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return " = " + string(b)
+}
+
+func nodeType(node ast.Node) string {
+	return fmt.Sprintf("%T", node)
+}
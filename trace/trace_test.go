@@ -0,0 +1,209 @@
+package trace
+
+import (
+	"bytes"
+	"simple-interpreter/evaluator"
+	"simple-interpreter/object"
+	"simple-interpreter/parser"
+	"strings"
+	"testing"
+)
+
+const input = `
+let h = {"a": [1, 2], "b": true};
+let add = fn(x, y) { x + y };
+puts(add(1, 2));
+len(h["a"]) + add(3, 4);
+`
+
+func record(t *testing.T, source string) *bytes.Buffer {
+	return recordWith(t, evaluator.New(), source)
+}
+
+// netEvaluator returns an evaluator with the socket builtins, which
+// evaluator.New leaves out.
+func netEvaluator() *evaluator.Evaluator {
+	e := evaluator.New()
+	e.Builtins = evaluator.Builtins(evaluator.CapNet)
+	return e
+}
+
+func recordWith(t *testing.T, e *evaluator.Evaluator, source string) *bytes.Buffer {
+	program, err := parser.ParseSafe(source)
+	if err != nil {
+		t.Fatalf("ParseSafe returned error: %s", err)
+	}
+
+	var out bytes.Buffer
+	rec := NewRecorder(&out, source)
+	rec.Attach(e)
+	e.Eval(program, object.NewEnvironment())
+	if rec.Err() != nil {
+		t.Fatalf("recorder error: %s", rec.Err())
+	}
+	return &out
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	tr, err := Read(record(t, input))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+	if tr.Source != input {
+		t.Errorf("trace source wrong. got=%q", tr.Source)
+	}
+
+	builtinCalls := []string{}
+	for _, event := range tr.Events {
+		if event.Kind == EventBuiltin {
+			builtinCalls = append(builtinCalls, event.Name)
+		}
+	}
+	if strings.Join(builtinCalls, ",") != "puts,len" {
+		t.Errorf("wrong builtin calls recorded. got=%v", builtinCalls)
+	}
+
+	result, err := tr.Replay(evaluator.New())
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if result.Inspect() != "9" {
+		t.Errorf("replay result wrong. got=%s", result.Inspect())
+	}
+}
+
+func TestReplayUsesRecordedBuiltinResults(t *testing.T) {
+	tr, err := Read(record(t, `len("abc")`))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+
+	for i, event := range tr.Events {
+		if event.Kind == EventBuiltin {
+			tr.Events[i].Value = EncodeValue(&object.Integer{Value: 42})
+		}
+	}
+
+	_, err = tr.Replay(evaluator.New())
+	divergence, ok := err.(*DivergenceError)
+	if !ok {
+		t.Fatalf("expected DivergenceError. got=%T (%v)", err, err)
+	}
+	if divergence.Got.Kind != EventNode || !strings.Contains(divergence.Error(), "42") {
+		t.Errorf("divergence should report the replayed value. got=%s", divergence)
+	}
+}
+
+func TestReplayDetectsEditedSource(t *testing.T) {
+	tr, err := Read(record(t, "1 + 2"))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+	tr.Source = "1 + 3"
+
+	if _, err := tr.Replay(evaluator.New()); err == nil {
+		t.Fatalf("expected replay of edited source to diverge")
+	}
+}
+
+func TestReplayComparesBuiltinArguments(t *testing.T) {
+	tr, err := Read(record(t, `len("abc")`))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+
+	index := -1
+	for i, event := range tr.Events {
+		if event.Kind == EventBuiltin {
+			index = i
+			tr.Events[i].Args[0] = EncodeValue(&object.String{Value: "xyz"})
+		}
+	}
+
+	_, err = tr.Replay(evaluator.New())
+	divergence, ok := err.(*DivergenceError)
+	if !ok {
+		t.Fatalf("expected DivergenceError. got=%T (%v)", err, err)
+	}
+	if divergence.Index != index || !strings.Contains(divergence.Error(), "xyz") {
+		t.Errorf("divergence should report the recorded arguments. got=%s", divergence)
+	}
+}
+
+func TestReplayWithExtraBuiltins(t *testing.T) {
+	withDouble := func() *evaluator.Evaluator {
+		e := evaluator.New()
+		builtins := make(map[string]*object.Builtin, len(e.Builtins)+1)
+		for name, b := range e.Builtins {
+			builtins[name] = b
+		}
+		builtins["double"] = &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+		}}
+		e.Builtins = builtins
+		return e
+	}
+
+	tr, err := Read(recordWith(t, withDouble(), `double(21)`))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+	if _, err := tr.Replay(evaluator.New()); err == nil {
+		t.Errorf("expected replay without the double builtin to fail")
+	}
+	result, err := tr.Replay(withDouble())
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("replay result wrong. got=%s", result.Inspect())
+	}
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	tests := []string{
+		`[1, "two", true, [null]]`,
+		`{"a": 1}`,
+		`"text"`,
+	}
+
+	for _, source := range tests {
+		program, err := parser.ParseSafe(strings.Replace(source, "null", "first([])", 1))
+		if err != nil {
+			t.Fatalf("ParseSafe returned error: %s", err)
+		}
+		obj := evaluator.Eval(program, object.NewEnvironment())
+
+		decoded, err := DecodeValue(EncodeValue(obj))
+		if err != nil {
+			t.Fatalf("DecodeValue returned error: %s", err)
+		}
+		if decoded.Inspect() != obj.Inspect() {
+			t.Errorf("round trip changed value. want=%s, got=%s",
+				obj.Inspect(), decoded.Inspect())
+		}
+	}
+}
+
+func TestReplaySocketScript(t *testing.T) {
+	tr, err := Read(recordWith(t, netEvaluator(), `
+let ln = listen("tcp", "127.0.0.1:0");
+let c = connect("tcp", localAddr(ln));
+let s = accept(ln);
+write(c, "ping");
+let got = read(s);
+close(c); close(s); close(ln);
+got
+`))
+	if err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+
+	result, err := tr.Replay(netEvaluator())
+	if err != nil {
+		t.Fatalf("Replay returned error: %s", err)
+	}
+	if result.Inspect() != "ping" {
+		t.Errorf("replay result wrong. got=%s", result.Inspect())
+	}
+}